@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+
+	"github.com/casmelad/bootcamp-gateway/server/auth"
+	pb "github.com/casmelad/bootcamp-gateway/server/proto"
+	mappers "github.com/casmelad/bootcamp-gateway/users/mappers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ApiKeyServer struct {
+	appService *auth.APIKeys
+	pb.ApiKeysServer
+}
+
+func NewApiKeyServer(s *auth.APIKeys) *ApiKeyServer {
+	return &ApiKeyServer{
+		appService: s,
+	}
+}
+
+//Creates a key for a service-to-service caller
+func (s ApiKeyServer) CreateApiKey(ctx context.Context, req *pb.CreateApiKeyRequest) (*pb.CreateApiKeyResponse, error) {
+
+	created, key, err := s.appService.Create(ctx, req.GetName(), req.GetScopes())
+
+	if err != nil {
+		switch err {
+		case auth.ErrInvalidAPIKeyData:
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid data, the name and the scopes cannot be empty")
+		}
+
+		return nil, status.Errorf(codes.Internal, "Internal error")
+	}
+
+	mapped, _ := mappers.ToGrpcApiKey(created)
+
+	return &pb.CreateApiKeyResponse{ApiKey: mapped, Key: key}, nil
+}
+
+//Revokes a key
+func (s ApiKeyServer) RevokeApiKey(ctx context.Context, req *pb.RevokeApiKeyRequest) (*pb.ApiKey, error) {
+
+	revoked, err := s.appService.Revoke(ctx, req.GetId())
+
+	if err != nil {
+		switch err {
+		case auth.ErrAPIKeyNotFound:
+			return nil, status.Errorf(codes.NotFound, "Api key does not exist")
+		}
+
+		return nil, status.Errorf(codes.Internal, "Internal error")
+	}
+
+	mapped, _ := mappers.ToGrpcApiKey(revoked)
+
+	return mapped, nil
+}
+
+//Lists the keys
+func (s ApiKeyServer) ListApiKeys(ctx context.Context, req *pb.ListApiKeysRequest) (*pb.ListApiKeysResponse, error) {
+
+	result, err := s.appService.List(ctx)
+
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Internal error")
+	}
+
+	response := &pb.ListApiKeysResponse{ApiKeys: make([]*pb.ApiKey, 0, len(result))}
+
+	for _, k := range result {
+		mapped, _ := mappers.ToGrpcApiKey(k)
+		response.ApiKeys = append(response.ApiKeys, mapped)
+	}
+
+	return response, nil
+}
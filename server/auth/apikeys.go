@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+)
+
+var (
+	ErrAPIKeyNotFound    error = users.NewDomainError("api key not found")
+	ErrInvalidAPIKey     error = users.NewDomainError("invalid api key")
+	ErrInvalidAPIKeyData error = users.NewDomainError("invalid api key data")
+)
+
+//APIKeyPrefix starts every generated key so they are easy to recognize
+const APIKeyPrefix = "uk_"
+
+//APIKey - a key identifying a service-to-service caller, only its hash is stored
+type APIKey struct {
+	ID   string
+	Name string
+	//Hash - the hex SHA-256 of the key
+	Hash string
+	//Scopes - the roles of the callers of the key
+	Scopes    []string
+	CreatedAt time.Time
+	//RevokedAt - when the key was revoked, zero while it is valid
+	RevokedAt time.Time
+}
+
+//Revoked - reports whether the key was revoked
+func (k APIKey) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+//APIKeyStore - stores the API keys
+type APIKeyStore interface {
+	Add(context.Context, APIKey) error
+	//GetByHash - retrieves the key with the hash, ErrAPIKeyNotFound is returned when there is none
+	GetByHash(context.Context, string) (APIKey, error)
+	List(context.Context) ([]APIKey, error)
+	//Revoke - records the revocation time unless the key was already revoked and returns the key,
+	//ErrAPIKeyNotFound is returned when there is no key with the id
+	Revoke(context.Context, string, time.Time) (APIKey, error)
+}
+
+//MemoryAPIKeyStore keeps the API keys in memory, it is safe for concurrent use
+type MemoryAPIKeyStore struct {
+	mu     sync.RWMutex
+	keys   map[string]APIKey
+	byHash map[string]string
+}
+
+//NewMemoryAPIKeyStore returns an empty MemoryAPIKeyStore type pointer
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: map[string]APIKey{}, byHash: map[string]string{}}
+}
+
+//Add - stores the key
+func (s *MemoryAPIKeyStore) Add(ctx context.Context, k APIKey) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[k.ID] = k
+	s.byHash[k.Hash] = k.ID
+
+	return nil
+}
+
+//GetByHash - retrieves the key with the hash
+func (s *MemoryAPIKeyStore) GetByHash(ctx context.Context, hash string) (APIKey, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.byHash[hash]
+
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+
+	return s.keys[id], nil
+}
+
+//List - retrieves every key ordered by creation time
+func (s *MemoryAPIKeyStore) List(ctx context.Context) ([]APIKey, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]APIKey, 0, len(s.keys))
+
+	for _, k := range s.keys {
+		result = append(result, k)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+//Revoke - records the revocation time of the key
+func (s *MemoryAPIKeyStore) Revoke(ctx context.Context, id string, at time.Time) (APIKey, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+
+	if !k.Revoked() {
+		k.RevokedAt = at
+		s.keys[id] = k
+	}
+
+	return k, nil
+}
+
+//APIKeys - creates, revokes and authenticates the API keys
+type APIKeys struct {
+	store APIKeyStore
+}
+
+//NewAPIKeys - returns an APIKeys type pointer
+func NewAPIKeys(store APIKeyStore) *APIKeys {
+	return &APIKeys{store: store}
+}
+
+//Create - generates a key with the scopes, the key is returned along with the stored APIKey and cannot be
+//retrieved again
+func (s *APIKeys) Create(ctx context.Context, name string, scopes []string) (APIKey, string, error) {
+
+	key := APIKeyPrefix + randomHex(32)
+
+	created, err := s.Seed(ctx, name, key, scopes)
+
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	return created, key, nil
+}
+
+//Seed - stores the given key with the scopes, used to provision the first admin key
+func (s *APIKeys) Seed(ctx context.Context, name string, key string, scopes []string) (APIKey, error) {
+
+	name = strings.TrimSpace(name)
+
+	if name == "" || key == "" {
+		return APIKey{}, ErrInvalidAPIKeyData
+	}
+
+	for _, scope := range scopes {
+		if strings.TrimSpace(scope) == "" {
+			return APIKey{}, ErrInvalidAPIKeyData
+		}
+	}
+
+	k := APIKey{ID: randomHex(8), Name: name, Hash: HashAPIKey(key), Scopes: scopes, CreatedAt: time.Now().UTC()}
+
+	if err := s.store.Add(ctx, k); err != nil {
+		return APIKey{}, users.ErrInternalError
+	}
+
+	return k, nil
+}
+
+//Revoke - revokes the key with the id
+func (s *APIKeys) Revoke(ctx context.Context, id string) (APIKey, error) {
+
+	k, err := s.store.Revoke(ctx, id, time.Now().UTC())
+
+	if err != nil && err != ErrAPIKeyNotFound {
+		return APIKey{}, users.ErrInternalError
+	}
+
+	return k, err
+}
+
+//List - retrieves the keys, including the revoked ones
+func (s *APIKeys) List(ctx context.Context) ([]APIKey, error) {
+
+	keys, err := s.store.List(ctx)
+
+	if err != nil {
+		return nil, users.ErrInternalError
+	}
+
+	return keys, nil
+}
+
+//Authenticate - returns the claims of the callers of the key, the subject is apikey:<id> and the roles its scopes,
+//ErrInvalidAPIKey is returned when the key is unknown or revoked
+func (s *APIKeys) Authenticate(ctx context.Context, key string) (Claims, error) {
+
+	k, err := s.store.GetByHash(ctx, HashAPIKey(key))
+
+	if err == ErrAPIKeyNotFound || (err == nil && k.Revoked()) {
+		return Claims{}, ErrInvalidAPIKey
+	}
+
+	if err != nil {
+		return Claims{}, users.ErrInternalError
+	}
+
+	return Claims{Subject: "apikey:" + k.ID, Roles: k.Scopes}, nil
+}
+
+//HashAPIKey - returns the hex SHA-256 of the key, the keys are random enough for a plain hash to be safe
+func HashAPIKey(key string) string {
+
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+//randomHex returns n random bytes hex encoded
+func randomHex(n int) string {
+
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
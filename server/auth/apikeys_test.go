@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_APIKeys_Create_AuthenticatesWithTheScopes(t *testing.T) {
+	//Arrange
+	ctx := context.Background()
+	store := NewMemoryAPIKeyStore()
+	keys := NewAPIKeys(store)
+	//Act
+	created, key, err := keys.Create(ctx, "batch", []string{"admin"})
+	claims, authErr := keys.Authenticate(ctx, key)
+	//Assert
+	require.NoError(t, err)
+	assert.Nil(t, authErr)
+	assert.True(t, strings.HasPrefix(key, APIKeyPrefix))
+	assert.Equal(t, "apikey:"+created.ID, claims.Identity())
+	assert.Equal(t, []string{"admin"}, claims.Roles)
+	stored, _ := store.List(ctx)
+	require.Len(t, stored, 1)
+	assert.Equal(t, HashAPIKey(key), stored[0].Hash)
+	assert.NotContains(t, stored[0].Hash, key)
+}
+
+func Test_APIKeys_Authenticate_UnknownOrRevoked_ReturnsInvalidAPIKey(t *testing.T) {
+	//Arrange
+	ctx := context.Background()
+	keys := NewAPIKeys(NewMemoryAPIKeyStore())
+	created, key, _ := keys.Create(ctx, "batch", nil)
+	//Act
+	_, errUnknown := keys.Authenticate(ctx, key+"x")
+	revoked, errRevoke := keys.Revoke(ctx, created.ID)
+	again, _ := keys.Revoke(ctx, created.ID)
+	_, errRevoked := keys.Authenticate(ctx, key)
+	//Assert
+	assert.Equal(t, ErrInvalidAPIKey, errUnknown)
+	assert.Nil(t, errRevoke)
+	assert.True(t, revoked.Revoked())
+	assert.Equal(t, revoked.RevokedAt, again.RevokedAt)
+	assert.Equal(t, ErrInvalidAPIKey, errRevoked)
+}
+
+func Test_APIKeys_Revoke_UnknownID_ReturnsNotFound(t *testing.T) {
+	//Arrange
+	keys := NewAPIKeys(NewMemoryAPIKeyStore())
+	//Act
+	_, err := keys.Revoke(context.Background(), "abc")
+	//Assert
+	assert.Equal(t, ErrAPIKeyNotFound, err)
+}
+
+func Test_APIKeys_Seed_InvalidData(t *testing.T) {
+	//Arrange
+	ctx := context.Background()
+	keys := NewAPIKeys(NewMemoryAPIKeyStore())
+	//Act
+	_, errName := keys.Seed(ctx, " ", "key", nil)
+	_, errKey := keys.Seed(ctx, "admin", "", nil)
+	_, errScope := keys.Seed(ctx, "admin", "key", []string{""})
+	_, err := keys.Seed(ctx, "admin", "key", []string{"admin"})
+	claims, _ := keys.Authenticate(ctx, "key")
+	//Assert
+	assert.Equal(t, ErrInvalidAPIKeyData, errName)
+	assert.Equal(t, ErrInvalidAPIKeyData, errKey)
+	assert.Equal(t, ErrInvalidAPIKeyData, errScope)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"admin"}, claims.Roles)
+}
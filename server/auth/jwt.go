@@ -0,0 +1,348 @@
+//Package auth validates the JWT bearer tokens identifying the callers of the services
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+)
+
+var (
+	ErrInvalidToken  error = users.NewDomainError("invalid token")
+	ErrExpiredToken  error = users.NewDomainError("token is expired")
+	ErrUnknownKey    error = users.NewDomainError("token signed with an unknown key")
+	ErrInvalidClaims error = users.NewDomainError("token claims are not accepted")
+)
+
+//DefaultLeeway is the clock skew tolerated when checking the expiration and not before times
+const DefaultLeeway = 30 * time.Second
+
+//Claims - the claims of a validated token
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+	Email     string   `json:"email"`
+	Roles     []string `json:"roles"`
+}
+
+//Audience - the aud claim, a single string or an array of strings
+type Audience []string
+
+//UnmarshalJSON - accepts both forms of the aud claim
+func (a *Audience) UnmarshalJSON(data []byte) error {
+
+	var single string
+
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var many []string
+
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+
+	*a = many
+
+	return nil
+}
+
+//Contains - reports whether the audience includes the value
+func (a Audience) Contains(value string) bool {
+
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Identity - the identity recorded as the actor of the changes, the email when the token has one
+func (c Claims) Identity() string {
+
+	if c.Email != "" {
+		return c.Email
+	}
+
+	return c.Subject
+}
+
+type claimsKey struct{}
+
+//WithClaims - returns a copy of the context carrying the claims of the caller
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+//ClaimsFromContext - returns the claims of the caller, false when the caller was not authenticated
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
+}
+
+//Option - configures optional behavior of a Validator
+type Option func(*Validator)
+
+//WithHMACSecret - accepts HS256 tokens signed with the secret
+func WithHMACSecret(secret []byte) Option {
+	return func(v *Validator) {
+		v.secret = secret
+	}
+}
+
+//WithRSAKeys - accepts RS256 tokens signed with one of the keys, indexed by key id
+func WithRSAKeys(keys map[string]*rsa.PublicKey) Option {
+	return func(v *Validator) {
+		v.keys = keys
+	}
+}
+
+//WithIssuer - only accepts the tokens issued by the issuer
+func WithIssuer(issuer string) Option {
+	return func(v *Validator) {
+		v.issuer = issuer
+	}
+}
+
+//WithAudience - only accepts the tokens meant for the audience
+func WithAudience(audience string) Option {
+	return func(v *Validator) {
+		v.audience = audience
+	}
+}
+
+//WithLeeway - tolerates the clock skew instead of DefaultLeeway
+func WithLeeway(leeway time.Duration) Option {
+	return func(v *Validator) {
+		v.leeway = leeway
+	}
+}
+
+//Validator checks the signature and the claims of the tokens
+type Validator struct {
+	secret   []byte
+	keys     map[string]*rsa.PublicKey
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+//NewValidator returns a Validator type pointer, a token is only accepted when its algorithm was configured
+//with WithHMACSecret or WithRSAKeys
+func NewValidator(opts ...Option) *Validator {
+
+	v := &Validator{leeway: DefaultLeeway, now: time.Now}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+//Validate - verifies the compact serialized token and returns its claims
+func (v *Validator) Validate(token string) (Claims, error) {
+
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var h header
+
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	if err := v.verify(h, parts[0]+"."+parts[1], signature); err != nil {
+		return Claims{}, err
+	}
+
+	var claims Claims
+
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	return claims, v.check(claims)
+}
+
+//verify checks the signature with the key of the algorithm in the header
+//UnverifiedClaims - decodes the claims of the token without verifying its signature nor its claims, they are only
+//good to tell the callers apart where a forged token gains nothing, as the rate limits do, never to authorize them
+func UnverifiedClaims(token string) (Claims, error) {
+
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var claims Claims
+
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func (v *Validator) verify(h header, signed string, signature []byte) error {
+
+	switch h.Algorithm {
+	case "HS256":
+		if len(v.secret) == 0 {
+			return ErrUnknownKey
+		}
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return ErrInvalidToken
+		}
+		return nil
+	case "RS256":
+		key := v.rsaKey(h.KeyID)
+		if key == nil {
+			return ErrUnknownKey
+		}
+		digest := sha256.Sum256([]byte(signed))
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return ErrInvalidToken
+		}
+		return nil
+	}
+
+	return ErrInvalidToken
+}
+
+//rsaKey returns the key with the id, the only key when the token has no id
+func (v *Validator) rsaKey(keyID string) *rsa.PublicKey {
+
+	if keyID == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key
+		}
+	}
+
+	return v.keys[keyID]
+}
+
+//check validates the time window, the issuer and the audience of the claims
+func (v *Validator) check(claims Claims) error {
+
+	now := v.now()
+
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(v.leeway)) {
+		return ErrExpiredToken
+	}
+
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0).Add(-v.leeway)) {
+		return ErrInvalidClaims
+	}
+
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return ErrInvalidClaims
+	}
+
+	if v.audience != "" && !claims.Audience.Contains(v.audience) {
+		return ErrInvalidClaims
+	}
+
+	if claims.Subject == "" {
+		return ErrInvalidClaims
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, value interface{}) error {
+
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
+
+type jwks struct {
+	Keys []struct {
+		KeyType   string `json:"kty"`
+		KeyID     string `json:"kid"`
+		Algorithm string `json:"alg"`
+		Use       string `json:"use"`
+		N         string `json:"n"`
+		E         string `json:"e"`
+	} `json:"keys"`
+}
+
+//LoadJWKS - reads the RSA signing keys of a JSON Web Key Set file indexed by key id, the keys of other
+//types or uses are skipped
+func LoadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var set jwks
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+
+	for _, k := range set.Keys {
+		if k.KeyType != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Algorithm != "" && k.Algorithm != "RS256") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		keys[k.KeyID] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC)
+
+func encodeSegment(t *testing.T, value interface{}) string {
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func hs256Token(t *testing.T, secret string, claims map[string]interface{}) string {
+	signed := encodeSegment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func rs256Token(t *testing.T, key *rsa.PrivateKey, keyID string, claims map[string]interface{}) string {
+	signed := encodeSegment(t, map[string]string{"alg": "RS256", "kid": keyID}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func testClaims() map[string]interface{} {
+	return map[string]interface{}{"sub": "42", "email": "test@gmail.com", "iss": "https://issuer.example.com",
+		"aud": []string{"users", "other"}, "exp": testNow.Add(time.Hour).Unix(), "roles": []string{"admin"}}
+}
+
+func newTestValidator(opts ...Option) *Validator {
+	v := NewValidator(opts...)
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+func Test_Validate_HS256_ReturnsClaims(t *testing.T) {
+	//Arrange
+	validator := newTestValidator(WithHMACSecret([]byte("s3cr3t")), WithIssuer("https://issuer.example.com"), WithAudience("users"))
+	token := hs256Token(t, "s3cr3t", testClaims())
+	//Act
+	claims, err := validator.Validate(token)
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "42", claims.Subject)
+	assert.Equal(t, "test@gmail.com", claims.Identity())
+	assert.Equal(t, Audience{"users", "other"}, claims.Audience)
+	assert.Equal(t, []string{"admin"}, claims.Roles)
+}
+
+func Test_Validate_HS256_WrongSecret_ReturnsInvalidToken(t *testing.T) {
+	//Arrange
+	validator := newTestValidator(WithHMACSecret([]byte("s3cr3t")))
+	token := hs256Token(t, "other", testClaims())
+	//Act
+	_, err := validator.Validate(token)
+	//Assert
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func Test_Validate_RejectsTheClaims(t *testing.T) {
+	tests := map[string]struct {
+		change   func(map[string]interface{})
+		expected error
+	}{
+		"expired":         {func(c map[string]interface{}) { c["exp"] = testNow.Add(-time.Minute).Unix() }, ErrExpiredToken},
+		"without exp":     {func(c map[string]interface{}) { delete(c, "exp") }, ErrExpiredToken},
+		"not yet valid":   {func(c map[string]interface{}) { c["nbf"] = testNow.Add(time.Minute).Unix() }, ErrInvalidClaims},
+		"other issuer":    {func(c map[string]interface{}) { c["iss"] = "https://other.example.com" }, ErrInvalidClaims},
+		"other audience":  {func(c map[string]interface{}) { c["aud"] = "other" }, ErrInvalidClaims},
+		"without subject": {func(c map[string]interface{}) { delete(c, "sub") }, ErrInvalidClaims},
+		"expired in skew": {func(c map[string]interface{}) { c["exp"] = testNow.Add(-10 * time.Second).Unix() }, nil},
+		"single audience": {func(c map[string]interface{}) { c["aud"] = "users" }, nil},
+		"valid in skew":   {func(c map[string]interface{}) { c["nbf"] = testNow.Add(10 * time.Second).Unix() }, nil},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			//Arrange
+			validator := newTestValidator(WithHMACSecret([]byte("s3cr3t")), WithIssuer("https://issuer.example.com"), WithAudience("users"))
+			claims := testClaims()
+			tt.change(claims)
+			//Act
+			_, err := validator.Validate(hs256Token(t, "s3cr3t", claims))
+			//Assert
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func Test_Validate_RejectsMalformedTokens(t *testing.T) {
+	//Arrange
+	validator := newTestValidator(WithHMACSecret([]byte("s3cr3t")))
+	token := hs256Token(t, "s3cr3t", testClaims())
+	unsigned := encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, testClaims()) + "."
+	//Act
+	_, errParts := validator.Validate("abc.def")
+	_, errSignature := validator.Validate(token + "x")
+	_, errNone := validator.Validate(unsigned)
+	//Assert
+	assert.Equal(t, ErrInvalidToken, errParts)
+	assert.Equal(t, ErrInvalidToken, errSignature)
+	assert.Equal(t, ErrInvalidToken, errNone)
+}
+
+func Test_Validate_AlgorithmNotConfigured_ReturnsUnknownKey(t *testing.T) {
+	//Arrange
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	validator := newTestValidator(WithRSAKeys(map[string]*rsa.PublicKey{"k1": &key.PublicKey}))
+	//Act
+	_, err = validator.Validate(hs256Token(t, "", testClaims()))
+	//Assert
+	assert.Equal(t, ErrUnknownKey, err)
+}
+
+func Test_Validate_RS256_WithJWKS(t *testing.T) {
+	//Arrange
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	set := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"k1","use":"sig","alg":"RS256","n":%q,"e":%q},{"kty":"EC","kid":"k2"}]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()), base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+	require.NoError(t, ioutil.WriteFile(path, []byte(set), 0644))
+	keys, err := LoadJWKS(path)
+	require.NoError(t, err)
+	validator := newTestValidator(WithRSAKeys(keys))
+	//Act
+	claims, err := validator.Validate(rs256Token(t, key, "k1", testClaims()))
+	_, errWithoutKid := validator.Validate(rs256Token(t, key, "", testClaims()))
+	_, errUnknownKid := validator.Validate(rs256Token(t, key, "k3", testClaims()))
+	_, errOtherKey := validator.Validate(rs256Token(t, other, "k1", testClaims()))
+	//Assert
+	assert.Nil(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, "42", claims.Subject)
+	assert.Nil(t, errWithoutKid)
+	assert.Equal(t, ErrUnknownKey, errUnknownKid)
+	assert.Equal(t, ErrInvalidToken, errOtherKey)
+}
+
+func Test_UnverifiedClaims_DoesNotVerifyTheToken(t *testing.T) {
+	//Arrange
+	forged := hs256Token(t, "not-the-secret", testClaims())
+	//Act
+	claims, err := UnverifiedClaims(forged)
+	_, errMalformed := UnverifiedClaims("not.a-token")
+	//Assert
+	require.NoError(t, err)
+	assert.Equal(t, "42", claims.Subject)
+	assert.Equal(t, ErrInvalidToken, errMalformed)
+}
+
+func Test_ClaimsFromContext(t *testing.T) {
+	//Arrange
+	ctx := WithClaims(context.Background(), Claims{Subject: "42"})
+	//Act
+	claims, ok := ClaimsFromContext(ctx)
+	_, anonymous := ClaimsFromContext(context.Background())
+	//Assert
+	assert.True(t, ok)
+	assert.Equal(t, "42", claims.Identity())
+	assert.False(t, anonymous)
+}
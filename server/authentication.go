@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/casmelad/bootcamp-gateway/server/auth"
+	domain "github.com/casmelad/bootcamp-gateway/users"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	//AuthorizationHeader is the metadata key carrying the bearer token of the caller
+	AuthorizationHeader = "authorization"
+	//APIKeyHeader is the metadata key carrying the API key of a service-to-service caller
+	APIKeyHeader = "x-api-key"
+)
+
+//UnaryAuthInterceptor rejects the calls without a valid API key or bearer token with codes.Unauthenticated,
+//the claims of the caller are stored in the context and its identity recorded as the actor of the changes,
+//a nil validator or keys only accepts the other credential
+func UnaryAuthInterceptor(validator *auth.Validator, keys *auth.APIKeys) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		ctx, err := authenticate(ctx, validator, keys)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+//StreamAuthInterceptor is the streaming counterpart of UnaryAuthInterceptor
+func StreamAuthInterceptor(validator *auth.Validator, keys *auth.APIKeys) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		ctx, err := authenticate(ss.Context(), validator, keys)
+
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+//authenticate validates the API key or else the bearer token of the incoming metadata
+func authenticate(ctx context.Context, validator *auth.Validator, keys *auth.APIKeys) (context.Context, error) {
+
+	claims, err := callerClaims(ctx, validator, keys)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = auth.WithClaims(ctx, claims)
+
+	return domain.WithActor(ctx, claims.Identity()), nil
+}
+
+//callerClaims returns the claims of the API key when the caller sends one, of the bearer token otherwise
+func callerClaims(ctx context.Context, validator *auth.Validator, keys *auth.APIKeys) (auth.Claims, error) {
+
+	if key, ok := apiKey(ctx); ok && keys != nil {
+		claims, err := keys.Authenticate(ctx, key)
+
+		switch err {
+		case nil:
+			return claims, nil
+		case auth.ErrInvalidAPIKey:
+			return auth.Claims{}, status.Errorf(codes.Unauthenticated, "Invalid api key")
+		}
+
+		return auth.Claims{}, status.Errorf(codes.Internal, "Internal error")
+	}
+
+	token, ok := bearerToken(ctx)
+
+	if !ok || validator == nil {
+		return auth.Claims{}, status.Errorf(codes.Unauthenticated, "Missing bearer token or api key")
+	}
+
+	claims, err := validator.Validate(token)
+
+	if err != nil {
+		return auth.Claims{}, status.Errorf(codes.Unauthenticated, "Invalid bearer token: %v", err)
+	}
+
+	return claims, nil
+}
+
+//apiKey returns the API key of the metadata
+func apiKey(ctx context.Context) (string, bool) {
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(APIKeyHeader)
+
+	if len(values) == 0 || values[0] == "" {
+		return "", false
+	}
+
+	return values[0], true
+}
+
+//bearerToken returns the token of the authorization metadata, the gateway forwards the Authorization header as is
+func bearerToken(ctx context.Context) (string, bool) {
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(AuthorizationHeader)
+
+	if len(values) == 0 {
+		return "", false
+	}
+
+	const prefix = "bearer "
+
+	if len(values[0]) <= len(prefix) || !strings.EqualFold(values[0][:len(prefix)], prefix) {
+		return "", false
+	}
+
+	return strings.TrimSpace(values[0][len(prefix):]), true
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/server/auth"
+	domain "github.com/casmelad/bootcamp-gateway/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testSecret = "s3cr3t"
+
+//hs256Token signs the claims with the secret
+func hs256Token(t *testing.T, secret string, claims map[string]interface{}) string {
+
+	encode := func(value interface{}) string {
+		data, err := json.Marshal(value)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	signed := encode(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encode(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+//callerToken returns a token of the caller valid for an hour
+func callerToken(t *testing.T, email string, roles ...string) string {
+	return hs256Token(t, testSecret, map[string]interface{}{"sub": "42", "email": email, "roles": roles,
+		"exp": time.Now().Add(time.Hour).Unix()})
+}
+
+//withAuthorization returns an incoming context carrying the authorization metadata
+func withAuthorization(value string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationHeader, value))
+}
+
+//recordingHandler is a unary handler keeping the context it was called with
+type recordingHandler struct {
+	ctx context.Context
+}
+
+func (h *recordingHandler) handle(ctx context.Context, req interface{}) (interface{}, error) {
+	h.ctx = ctx
+	return "ok", nil
+}
+
+func Test_UnaryAuthInterceptor_InvalidCredentials_ReturnsUnauthenticated(t *testing.T) {
+
+	validator := auth.NewValidator(auth.WithHMACSecret([]byte(testSecret)))
+	interceptor := UnaryAuthInterceptor(validator, nil)
+	info := &grpc.UnaryServerInfo{FullMethod: "/users.Users/GetUser"}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"no metadata", context.Background()},
+		{"missing token", metadata.NewIncomingContext(context.Background(), metadata.Pairs())},
+		{"not a bearer token", withAuthorization("Basic dXNlcjpwYXNz")},
+		{"expired token", withAuthorization("Bearer " + hs256Token(t, testSecret,
+			map[string]interface{}{"sub": "42", "exp": time.Now().Add(-time.Hour).Unix()}))},
+		{"wrongly signed token", withAuthorization("Bearer " + hs256Token(t, "another",
+			map[string]interface{}{"sub": "42", "exp": time.Now().Add(time.Hour).Unix()}))},
+		{"malformed token", withAuthorization("Bearer abc")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//Arrange
+			handler := &recordingHandler{}
+			//Act
+			resp, err := interceptor(tt.ctx, nil, info, handler.handle)
+			//Assert
+			assert.Nil(t, resp)
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+			assert.Nil(t, handler.ctx)
+		})
+	}
+}
+
+func Test_UnaryAuthInterceptor_ValidToken_StoresClaimsAndActor(t *testing.T) {
+	//Arrange
+	validator := auth.NewValidator(auth.WithHMACSecret([]byte(testSecret)))
+	interceptor := UnaryAuthInterceptor(validator, nil)
+	handler := &recordingHandler{}
+	ctx := withAuthorization("Bearer " + callerToken(t, "test@gmail.com", "admin"))
+	//Act
+	resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/users.Users/GetUser"}, handler.handle)
+	//Assert
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+	claims, ok := auth.ClaimsFromContext(handler.ctx)
+	require.True(t, ok)
+	assert.Equal(t, "42", claims.Subject)
+	assert.Equal(t, "test@gmail.com", claims.Email)
+	assert.Equal(t, []string{"admin"}, claims.Roles)
+	assert.Equal(t, "test@gmail.com", domain.ActorFromContext(handler.ctx))
+}
+
+func Test_UnaryAuthInterceptor_APIKey_StoresClaimsAndActor(t *testing.T) {
+	//Arrange
+	keys := auth.NewAPIKeys(auth.NewMemoryAPIKeyStore())
+	key, secret, err := keys.Create(context.Background(), "reports", []string{"reader"})
+	require.NoError(t, err)
+	interceptor := UnaryAuthInterceptor(nil, keys)
+	handler := &recordingHandler{}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyHeader, secret))
+	invalid := metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyHeader, "unknown"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/users.Users/GetUser"}
+	//Act
+	_, err = interceptor(ctx, nil, info, handler.handle)
+	_, errInvalid := interceptor(invalid, nil, info, (&recordingHandler{}).handle)
+	//Assert
+	require.NoError(t, err)
+	claims, ok := auth.ClaimsFromContext(handler.ctx)
+	require.True(t, ok)
+	assert.Equal(t, []string{"reader"}, claims.Roles)
+	assert.Equal(t, "apikey:"+key.ID, claims.Subject)
+	assert.Equal(t, "apikey:"+key.ID, domain.ActorFromContext(handler.ctx))
+	assert.Equal(t, codes.Unauthenticated, status.Code(errInvalid))
+}
+
+func Test_StreamAuthInterceptor_PassesTheAuthenticatedContext(t *testing.T) {
+	//Arrange
+	validator := auth.NewValidator(auth.WithHMACSecret([]byte(testSecret)))
+	interceptor := StreamAuthInterceptor(validator, nil)
+	info := &grpc.StreamServerInfo{FullMethod: "/users.Users/WatchUsers"}
+	var streamCtx context.Context
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		streamCtx = ss.Context()
+		return nil
+	}
+	valid := &contextStream{ctx: withAuthorization("Bearer " + callerToken(t, "test@gmail.com"))}
+	missing := &contextStream{ctx: context.Background()}
+	//Act
+	err := interceptor(nil, valid, info, handler)
+	errMissing := interceptor(nil, missing, info, handler)
+	//Assert
+	require.NoError(t, err)
+	assert.Equal(t, "test@gmail.com", domain.ActorFromContext(streamCtx))
+	assert.Equal(t, codes.Unauthenticated, status.Code(errMissing))
+}
+
+func Test_Gateway_ForwardsTheAuthorizationHeader(t *testing.T) {
+	//Arrange
+	service := newTestService()
+	validator := auth.NewValidator(auth.WithHMACSecret([]byte(testSecret)))
+	gateway := newTestGateway(t, service, grpc.UnaryInterceptor(UnaryAuthInterceptor(validator, nil)))
+	body := `{"email":"test@gmail.com","name":"John","last_name":"Doe"}`
+	//Act
+	missing := doRequest(t, gateway, http.MethodPost, "/api/v1/users", body)
+	invalid := doRequest(t, gateway, http.MethodPost, "/api/v1/users", body, "Authorization", "Bearer abc")
+	created := doRequest(t, gateway, http.MethodPost, "/api/v1/users", body,
+		"Authorization", "Bearer "+callerToken(t, "admin@gmail.com", "admin"))
+	//Assert
+	assert.Equal(t, http.StatusUnauthorized, missing.StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, invalid.StatusCode)
+	assert.Equal(t, http.StatusOK, created.StatusCode)
+	usr, err := service.GetByEmail(context.Background(), "test@gmail.com")
+	require.NoError(t, err)
+	assert.Equal(t, "admin@gmail.com", usr.CreatedBy)
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/casmelad/bootcamp-gateway/server/auth"
+	"github.com/casmelad/bootcamp-gateway/server/authz"
+	pb "github.com/casmelad/bootcamp-gateway/server/proto"
+	domain "github.com/casmelad/bootcamp-gateway/users"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//UserLookup retrieves a user by id, used to find the owner of the user targeted by a call
+type UserLookup func(ctx context.Context, userID int) (domain.User, error)
+
+//UnaryAuthzInterceptor rejects with codes.PermissionDenied the calls the policy does not allow, the ownership
+//rules compare the email of the caller with the email of the user targeted by the request,
+//it must run after UnaryAuthInterceptor
+func UnaryAuthzInterceptor(policy *authz.Policy, lookup UserLookup) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		claims, ok := auth.ClaimsFromContext(ctx)
+
+		if !ok {
+			return nil, status.Errorf(codes.Unauthenticated, "Missing bearer token or api key")
+		}
+
+		switch policy.Decide(info.FullMethod, claims) {
+		case authz.Allow:
+			return handler(ctx, req)
+		case authz.AllowOwner:
+			owner, err := targetEmail(ctx, req, lookup)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "Internal error")
+			}
+			if owner != "" && strings.EqualFold(owner, claims.Email) {
+				return handler(ctx, req)
+			}
+		}
+
+		return nil, status.Errorf(codes.PermissionDenied, "Not allowed to call %s", info.FullMethod)
+	}
+}
+
+//StreamAuthzInterceptor is the streaming counterpart of UnaryAuthzInterceptor, the request of a stream is not
+//known when it starts so the ownership rules never allow a stream
+func StreamAuthzInterceptor(policy *authz.Policy) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		claims, ok := auth.ClaimsFromContext(ss.Context())
+
+		if !ok {
+			return status.Errorf(codes.Unauthenticated, "Missing bearer token or api key")
+		}
+
+		if policy.Decide(info.FullMethod, claims) != authz.Allow {
+			return status.Errorf(codes.PermissionDenied, "Not allowed to call %s", info.FullMethod)
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+//targetEmail returns the email of the user targeted by the request, empty when the request does not target a user
+func targetEmail(ctx context.Context, req interface{}, lookup UserLookup) (string, error) {
+
+	var userID int32
+
+	switch r := req.(type) {
+	case *pb.GetUserRequest:
+		return r.GetEmail(), nil
+	case *pb.CreateRequest:
+		return r.GetEmail(), nil
+	case *pb.UpdateRequest:
+		userID = r.GetUser().GetId()
+	case *pb.DeleteRequest:
+		userID = r.GetId()
+	case *pb.UndeleteRequest:
+		userID = r.GetId()
+	case *pb.PurgeRequest:
+		userID = r.GetId()
+	case *pb.ListAuditEventsRequest:
+		userID = r.GetUserId()
+	}
+
+	if userID <= 0 {
+		return "", nil
+	}
+
+	usr, err := lookup(ctx, int(userID))
+
+	if err != nil {
+		return "", err
+	}
+
+	return usr.Email, nil
+}
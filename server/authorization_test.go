@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/casmelad/bootcamp-gateway/server/auth"
+	"github.com/casmelad/bootcamp-gateway/server/authz"
+	pb "github.com/casmelad/bootcamp-gateway/server/proto"
+	domain "github.com/casmelad/bootcamp-gateway/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//testLookup finds the users 1, owned by owner@gmail.com, and 2, owned by other@gmail.com
+func testLookup(ctx context.Context, userID int) (domain.User, error) {
+
+	switch userID {
+	case 1:
+		return domain.User{ID: 1, Email: "owner@gmail.com"}, nil
+	case 2:
+		return domain.User{ID: 2, Email: "other@gmail.com"}, nil
+	}
+
+	return domain.User{}, domain.ErrNotFound
+}
+
+func Test_UnaryAuthzInterceptor_OwnAndOtherRecords(t *testing.T) {
+
+	interceptor := UnaryAuthzInterceptor(authz.DefaultPolicy(), testLookup)
+	admin := auth.Claims{Subject: "1", Email: "owner@gmail.com", Roles: []string{authz.AdminRole}}
+	user := auth.Claims{Subject: "1", Email: "Owner@Gmail.com"}
+
+	tests := []struct {
+		name     string
+		claims   auth.Claims
+		method   string
+		req      interface{}
+		expected codes.Code
+	}{
+		{"admin gets own user", admin, "/users.Users/GetUser", &pb.GetUserRequest{Email: "owner@gmail.com"}, codes.OK},
+		{"admin gets other user", admin, "/users.Users/GetUser", &pb.GetUserRequest{Email: "other@gmail.com"}, codes.OK},
+		{"admin updates other user", admin, "/users.Users/Update", &pb.UpdateRequest{User: &pb.User{Id: 2}}, codes.OK},
+		{"admin deletes own user", admin, "/users.Users/Delete", &pb.DeleteRequest{Id: 1}, codes.OK},
+		{"admin deletes other user", admin, "/users.Users/Delete", &pb.DeleteRequest{Id: 2}, codes.OK},
+		{"admin lists users", admin, "/users.Users/ListUsers", &pb.GetAllUsersRequest{}, codes.OK},
+		{"user gets own user", user, "/users.Users/GetUser", &pb.GetUserRequest{Email: "owner@gmail.com"}, codes.OK},
+		{"user gets other user", user, "/users.Users/GetUser", &pb.GetUserRequest{Email: "other@gmail.com"}, codes.PermissionDenied},
+		{"user creates own user", user, "/users.Users/Create", &pb.CreateRequest{Email: "owner@gmail.com"}, codes.OK},
+		{"user creates other user", user, "/users.Users/Create", &pb.CreateRequest{Email: "other@gmail.com"}, codes.PermissionDenied},
+		{"user updates own user", user, "/users.Users/Update", &pb.UpdateRequest{User: &pb.User{Id: 1}}, codes.OK},
+		{"user updates other user", user, "/users.Users/Update", &pb.UpdateRequest{User: &pb.User{Id: 2}}, codes.PermissionDenied},
+		{"user updates missing user", user, "/users.Users/Update", &pb.UpdateRequest{User: &pb.User{}}, codes.PermissionDenied},
+		{"user deletes own user", user, "/users.Users/Delete", &pb.DeleteRequest{Id: 1}, codes.PermissionDenied},
+		{"user deletes other user", user, "/users.Users/Delete", &pb.DeleteRequest{Id: 2}, codes.PermissionDenied},
+		{"user lists users", user, "/users.Users/ListUsers", &pb.GetAllUsersRequest{}, codes.PermissionDenied},
+		{"user without email gets own user", auth.Claims{Subject: "1"}, "/users.Users/GetUser",
+			&pb.GetUserRequest{Email: "owner@gmail.com"}, codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//Arrange
+			handler := &recordingHandler{}
+			ctx := auth.WithClaims(context.Background(), tt.claims)
+			//Act
+			_, err := interceptor(ctx, tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler.handle)
+			//Assert
+			assert.Equal(t, tt.expected, status.Code(err))
+			assert.Equal(t, tt.expected == codes.OK, handler.ctx != nil)
+		})
+	}
+}
+
+func Test_UnaryAuthzInterceptor_NotAuthenticated_ReturnsUnauthenticated(t *testing.T) {
+	//Arrange
+	interceptor := UnaryAuthzInterceptor(authz.DefaultPolicy(), testLookup)
+	handler := &recordingHandler{}
+	//Act
+	_, err := interceptor(context.Background(), &pb.GetUserRequest{Email: "owner@gmail.com"},
+		&grpc.UnaryServerInfo{FullMethod: "/users.Users/GetUser"}, handler.handle)
+	//Assert
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Nil(t, handler.ctx)
+}
+
+func Test_UnaryAuthzInterceptor_LookupFails_ReturnsInternal(t *testing.T) {
+	//Arrange
+	lookup := func(ctx context.Context, userID int) (domain.User, error) {
+		return domain.User{}, errors.New("unreachable")
+	}
+	interceptor := UnaryAuthzInterceptor(authz.DefaultPolicy(), lookup)
+	ctx := auth.WithClaims(context.Background(), auth.Claims{Email: "owner@gmail.com"})
+	//Act
+	_, err := interceptor(ctx, &pb.UpdateRequest{User: &pb.User{Id: 1}},
+		&grpc.UnaryServerInfo{FullMethod: "/users.Users/Update"}, (&recordingHandler{}).handle)
+	//Assert
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func Test_StreamAuthzInterceptor_OnlyAllowsTheRoles(t *testing.T) {
+	//Arrange
+	interceptor := StreamAuthzInterceptor(authz.DefaultPolicy())
+	info := &grpc.StreamServerInfo{FullMethod: "/users.Users/WatchUsers"}
+	handler := func(srv interface{}, ss grpc.ServerStream) error { return nil }
+	admin := &contextStream{ctx: auth.WithClaims(context.Background(), auth.Claims{Email: "owner@gmail.com", Roles: []string{authz.AdminRole}})}
+	user := &contextStream{ctx: auth.WithClaims(context.Background(), auth.Claims{Email: "owner@gmail.com"})}
+	//Act
+	errAdmin := interceptor(nil, admin, info, handler)
+	errUser := interceptor(nil, user, info, handler)
+	errMissing := interceptor(nil, &contextStream{ctx: context.Background()}, info, handler)
+	//Assert
+	require.NoError(t, errAdmin)
+	assert.Equal(t, codes.PermissionDenied, status.Code(errUser))
+	assert.Equal(t, codes.Unauthenticated, status.Code(errMissing))
+}
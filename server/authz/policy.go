@@ -0,0 +1,137 @@
+//Package authz decides which authenticated callers may invoke every RPC
+package authz
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/casmelad/bootcamp-gateway/server/auth"
+)
+
+//AdminRole is the role allowed to call every RPC in the DefaultPolicy
+const AdminRole = "admin"
+
+//Decision - the outcome of evaluating the policy for a call
+type Decision int
+
+const (
+	//Deny - the caller is not allowed
+	Deny Decision = iota
+	//Allow - the caller is allowed
+	Allow
+	//AllowOwner - the caller is only allowed when it owns the user targeted by the call
+	AllowOwner
+)
+
+//Rule - who may call a method
+type Rule struct {
+	//Roles - the callers with any of the roles are allowed
+	Roles []string `json:"roles"`
+	//Owner - the caller whose email is the email of the targeted user is allowed
+	Owner bool `json:"owner"`
+	//Authenticated - every authenticated caller is allowed
+	Authenticated bool `json:"authenticated"`
+}
+
+//Policy - the rules of the methods, keyed by full method name (/users.Users/GetUser) or by service
+//(/users.Webhooks/*), the methods without a rule follow Default and are denied when there is none
+type Policy struct {
+	Methods map[string]Rule `json:"methods"`
+	Default *Rule           `json:"default"`
+}
+
+//DefaultPolicy - admins may call everything, the rest of the callers may only create, read and update
+//their own user
+func DefaultPolicy() *Policy {
+
+	admin := Rule{Roles: []string{AdminRole}}
+	adminOrOwner := Rule{Roles: []string{AdminRole}, Owner: true}
+
+	return &Policy{
+		Methods: map[string]Rule{
+			"/users.Users/GetUser": adminOrOwner,
+			"/users.Users/Create":  adminOrOwner,
+			"/users.Users/Update":  adminOrOwner,
+		},
+		Default: &admin,
+	}
+}
+
+//LoadPolicy - reads a JSON policy file
+func LoadPolicy(path string) (*Policy, error) {
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var p Policy
+
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+
+	for method := range p.Methods {
+		if !strings.HasPrefix(method, "/") || strings.Count(method, "/") != 2 {
+			return nil, fmt.Errorf("policy method %q is not /<service>/<method> or /<service>/*", method)
+		}
+	}
+
+	return &p, nil
+}
+
+//Decide - evaluates the rule of the method for the caller
+func (p *Policy) Decide(method string, claims auth.Claims) Decision {
+
+	rule, ok := p.rule(method)
+
+	if !ok {
+		return Deny
+	}
+
+	if rule.Authenticated || hasAnyRole(claims.Roles, rule.Roles) {
+		return Allow
+	}
+
+	if rule.Owner && claims.Email != "" {
+		return AllowOwner
+	}
+
+	return Deny
+}
+
+//rule returns the rule of the method, the rule of its service or the default one
+func (p *Policy) rule(method string) (Rule, bool) {
+
+	if rule, ok := p.Methods[method]; ok {
+		return rule, true
+	}
+
+	if i := strings.LastIndex(method, "/"); i > 0 {
+		if rule, ok := p.Methods[method[:i]+"/*"]; ok {
+			return rule, true
+		}
+	}
+
+	if p.Default != nil {
+		return *p.Default, true
+	}
+
+	return Rule{}, false
+}
+
+func hasAnyRole(have, want []string) bool {
+
+	for _, w := range want {
+		for _, h := range have {
+			if h == w {
+				return true
+			}
+		}
+	}
+
+	return false
+}
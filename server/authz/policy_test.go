@@ -0,0 +1,73 @@
+package authz
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/casmelad/bootcamp-gateway/server/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DefaultPolicy_Decide(t *testing.T) {
+	admin := auth.Claims{Subject: "1", Email: "admin@gmail.com", Roles: []string{AdminRole}}
+	caller := auth.Claims{Subject: "2", Email: "test@gmail.com", Roles: []string{"reader"}}
+	anonymous := auth.Claims{Subject: "3"}
+
+	tests := []struct {
+		method   string
+		claims   auth.Claims
+		expected Decision
+	}{
+		{"/users.Users/GetUser", admin, Allow},
+		{"/users.Users/GetUser", caller, AllowOwner},
+		{"/users.Users/GetUser", anonymous, Deny},
+		{"/users.Users/Update", caller, AllowOwner},
+		{"/users.Users/Delete", admin, Allow},
+		{"/users.Users/Delete", caller, Deny},
+		{"/users.Users/GetAllUsers", admin, Allow},
+		{"/users.Users/GetAllUsers", caller, Deny},
+		{"/users.Webhooks/RegisterWebhook", caller, Deny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.claims.Subject, func(t *testing.T) {
+			//Act
+			result := DefaultPolicy().Decide(tt.method, tt.claims)
+			//Assert
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func Test_LoadPolicy_Decide(t *testing.T) {
+	//Arrange
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"methods":{
+		"/users.Users/ListUsers":{"authenticated":true},
+		"/users.Webhooks/*":{"roles":["integrations"]},
+		"/users.Webhooks/ListWebhooks":{"roles":["auditor","integrations"]}}}`), 0644))
+	caller := auth.Claims{Subject: "2", Email: "test@gmail.com"}
+	auditor := auth.Claims{Subject: "3", Roles: []string{"auditor"}}
+	//Act
+	policy, err := LoadPolicy(path)
+	//Assert
+	require.NoError(t, err)
+	assert.Equal(t, Allow, policy.Decide("/users.Users/ListUsers", caller))
+	assert.Equal(t, Deny, policy.Decide("/users.Users/GetUser", caller))
+	assert.Equal(t, Allow, policy.Decide("/users.Webhooks/ListWebhooks", auditor))
+	assert.Equal(t, Deny, policy.Decide("/users.Webhooks/DeleteWebhook", auditor))
+	assert.Equal(t, Allow, policy.Decide("/users.Webhooks/DeleteWebhook", auth.Claims{Roles: []string{"integrations"}}))
+}
+
+func Test_LoadPolicy_InvalidMethod_ReturnsError(t *testing.T) {
+	//Arrange
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"methods":{"GetUser":{"owner":true}}}`), 0644))
+	//Act
+	policy, err := LoadPolicy(path)
+	//Assert
+	assert.Nil(t, policy)
+	assert.NotNil(t, err)
+}
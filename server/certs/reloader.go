@@ -0,0 +1,282 @@
+//Package certs serves the TLS certificates of the listeners and reloads them when their files change
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+)
+
+//DefaultReloadInterval is how often the files are checked for changes
+const DefaultReloadInterval = 10 * time.Second
+
+//Reloader holds the certificate, its key and the CA bundle loaded from files, it is safe for concurrent use
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+	pool *x509.CertPool
+	//stamps identify the version of the files loaded
+	stamps []fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+//NewReloader loads the certificate and key pair and the CA bundle, caFile is optional and required to verify
+//the peers with ServerConfig or ClientConfig
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+//Reload reads the files again, the previous certificate is kept when they cannot be loaded
+func (r *Reloader) Reload() error {
+
+	stamps := r.stat()
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+
+	if err != nil {
+		return err
+	}
+
+	var pool *x509.CertPool
+
+	if r.caFile != "" {
+		pem, err := ioutil.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("no CA certificate found in " + r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	r.pool = pool
+	r.stamps = stamps
+
+	return nil
+}
+
+//Run reloads the files every interval when they changed until the context is done, the reloads and their
+//failures are reported to the logger
+func (r *Reloader) Run(ctx context.Context, interval time.Duration, logger users.Logger) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				logger.Error("reloading the TLS certificate failed", "cert_file", r.certFile, "error", err)
+				continue
+			}
+			logger.Info("reloaded the TLS certificate", "cert_file", r.certFile)
+		}
+	}
+}
+
+//ServerConfig returns the configuration of a listener serving the current certificate, the clients must
+//present a certificate signed by the CA bundle when requireClientCert is set
+func (r *Reloader) ServerConfig(requireClientCert bool) (*tls.Config, error) {
+
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.getCertificate,
+	}
+
+	if requireClientCert {
+		if r.caFile == "" {
+			return nil, errors.New("verifying the client certificates requires a CA bundle")
+		}
+		//the chain is verified against the current bundle instead of a fixed ClientCAs pool so a new bundle
+		//is used without restarting the listener
+		cfg.ClientAuth = tls.RequireAnyClientCert
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return r.verify(rawCerts, "", x509.ExtKeyUsageClientAuth)
+		}
+	}
+
+	return cfg, nil
+}
+
+//ClientConfig returns the configuration of a connection to serverName presenting the current certificate,
+//the server certificate is verified against the CA bundle or the system roots when there is no bundle
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+
+	cfg := &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		ServerName:           serverName,
+		GetClientCertificate: r.getClientCertificate,
+	}
+
+	if r.caFile != "" {
+		//the default verification is replaced by the one against the current bundle
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			raw := make([][]byte, 0, len(cs.PeerCertificates))
+			for _, c := range cs.PeerCertificates {
+				raw = append(raw, c.Raw)
+			}
+			return r.verify(raw, serverName, x509.ExtKeyUsageServerAuth)
+		}
+	}
+
+	return cfg
+}
+
+//CheckUsage returns an error when the extended key usages of the current certificate do not allow the usage,
+//a certificate presented by a client needs x509.ExtKeyUsageClientAuth for instance
+func (r *Reloader) CheckUsage(usage x509.ExtKeyUsage) error {
+
+	r.mu.RLock()
+	raw := r.cert.Certificate[0]
+	r.mu.RUnlock()
+
+	cert, err := x509.ParseCertificate(raw)
+
+	if err != nil {
+		return err
+	}
+
+	if len(cert.ExtKeyUsage) == 0 {
+		return nil
+	}
+
+	for _, u := range cert.ExtKeyUsage {
+		if u == usage || u == x509.ExtKeyUsageAny {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("the certificate of %s does not allow the %s extended key usage", r.certFile, usageName(usage))
+}
+
+func usageName(usage x509.ExtKeyUsage) string {
+
+	switch usage {
+	case x509.ExtKeyUsageServerAuth:
+		return "serverAuth"
+	case x509.ExtKeyUsageClientAuth:
+		return "clientAuth"
+	default:
+		return fmt.Sprintf("%d", usage)
+	}
+}
+
+func (r *Reloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+func (r *Reloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+//verify checks the peer chain against the current CA bundle
+func (r *Reloader) verify(rawCerts [][]byte, dnsName string, usage x509.ExtKeyUsage) error {
+
+	if len(rawCerts) == 0 {
+		return errors.New("no peer certificate")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+
+	for _, raw := range rawCerts {
+		c, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, c)
+	}
+
+	intermediates := x509.NewCertPool()
+
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	r.mu.RLock()
+	pool := r.pool
+	r.mu.RUnlock()
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       dnsName,
+		Roots:         pool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	})
+
+	return err
+}
+
+//changed reports whether any of the files changed since they were loaded
+func (r *Reloader) changed() bool {
+
+	stamps := r.stat()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for i := range stamps {
+		if stamps[i] != r.stamps[i] {
+			return true
+		}
+	}
+
+	return false
+}
+
+//stat returns the stamps of the files, zero for the missing ones
+func (r *Reloader) stat() []fileStamp {
+
+	stamps := make([]fileStamp, 0, 3)
+
+	for _, name := range []string{r.certFile, r.keyFile, r.caFile} {
+		var stamp fileStamp
+		if info, err := os.Stat(name); name != "" && err == nil {
+			stamp = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+		stamps = append(stamps, stamp)
+	}
+
+	return stamps
+}
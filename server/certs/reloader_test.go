@@ -0,0 +1,264 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//authority is a test CA issuing certificates valid for localhost as server and client
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T) *authority {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "test CA"},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour), IsCA: true,
+		KeyUsage: x509.KeyUsageCertSign, BasicConstraintsValid: true}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+//issue writes a certificate for the common name and its key to dir and returns their paths, the certificate
+//allows the usages, server and client authentication when none is given
+func (a *authority) issue(t *testing.T, dir string, commonName string, usages ...x509.ExtKeyUsage) (string, string) {
+
+	if len(usages) == 0 {
+		usages = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{SerialNumber: big.NewInt(time.Now().UnixNano()), Subject: pkix.Name{CommonName: commonName},
+		DNSNames: []string{"localhost"}, NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+		KeyUsage: x509.KeyUsageDigitalSignature, ExtKeyUsage: usages}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, commonName+".crt")
+	keyFile := filepath.Join(dir, commonName+".key")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	return certFile, keyFile
+}
+
+func (a *authority) write(t *testing.T, dir string) string {
+	path := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(path, a.pem, 0644))
+	return path
+}
+
+//handshake connects a client with the configuration to a listener with the server configuration
+func handshake(t *testing.T, serverConfig, clientConfig *tls.Config) (string, error) {
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer conn.Close()
+		serverErr <- conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), clientConfig)
+
+	if err != nil {
+		<-serverErr
+		return "", err
+	}
+
+	defer conn.Close()
+
+	if err := <-serverErr; err != nil {
+		return "", err
+	}
+
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func Test_Reloader_MutualTLS(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	ca := newAuthority(t)
+	caFile := ca.write(t, dir)
+	serverCert, serverKey := ca.issue(t, dir, "server")
+	clientCert, clientKey := ca.issue(t, dir, "client")
+	strangerCert, strangerKey := newAuthority(t).issue(t, dir, "stranger")
+	server, err := NewReloader(serverCert, serverKey, caFile)
+	require.NoError(t, err)
+	client, err := NewReloader(clientCert, clientKey, caFile)
+	require.NoError(t, err)
+	stranger, err := NewReloader(strangerCert, strangerKey, caFile)
+	require.NoError(t, err)
+	serverConfig, err := server.ServerConfig(true)
+	require.NoError(t, err)
+	//Act
+	peer, err := handshake(t, serverConfig, client.ClientConfig("localhost"))
+	_, errStranger := handshake(t, serverConfig, stranger.ClientConfig("localhost"))
+	_, errServerName := handshake(t, serverConfig, client.ClientConfig("other.example.com"))
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "server", peer)
+	assert.NotNil(t, errStranger)
+	assert.NotNil(t, errServerName)
+}
+
+func Test_Reloader_ServerAuthOnlyCertificate_IsNotAClientCertificate(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	ca := newAuthority(t)
+	caFile := ca.write(t, dir)
+	serverCert, serverKey := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+	server, err := NewReloader(serverCert, serverKey, caFile)
+	require.NoError(t, err)
+	client, err := NewReloader(clientCert, clientKey, caFile)
+	require.NoError(t, err)
+	serverConfig, err := server.ServerConfig(true)
+	require.NoError(t, err)
+	//Act
+	_, errReused := handshake(t, serverConfig, server.ClientConfig("localhost"))
+	peer, errClient := handshake(t, serverConfig, client.ClientConfig("localhost"))
+	//Assert
+	assert.NotNil(t, errReused)
+	assert.NotNil(t, server.CheckUsage(x509.ExtKeyUsageClientAuth))
+	assert.Nil(t, server.CheckUsage(x509.ExtKeyUsageServerAuth))
+	assert.Nil(t, errClient)
+	assert.Equal(t, "server", peer)
+	assert.Nil(t, client.CheckUsage(x509.ExtKeyUsageClientAuth))
+}
+
+func Test_Reloader_ServerConfig_ClientAuthWithoutCA_ReturnsError(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	cert, key := newAuthority(t).issue(t, dir, "server")
+	reloader, err := NewReloader(cert, key, "")
+	require.NoError(t, err)
+	//Act
+	cfg, err := reloader.ServerConfig(true)
+	//Assert
+	assert.Nil(t, cfg)
+	assert.NotNil(t, err)
+}
+
+func Test_Reloader_Reload_ServesTheNewCertificate(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	ca := newAuthority(t)
+	caFile := ca.write(t, dir)
+	serverCert, serverKey := ca.issue(t, dir, "server")
+	server, err := NewReloader(serverCert, serverKey, caFile)
+	require.NoError(t, err)
+	client, err := NewReloader(serverCert, serverKey, caFile)
+	require.NoError(t, err)
+	serverConfig, _ := server.ServerConfig(false)
+	renewedCert, renewedKey := ca.issue(t, dir, "renewed")
+	unchanged := server.changed()
+	//Act
+	certPEM, _ := ioutil.ReadFile(renewedCert)
+	keyPEM, _ := ioutil.ReadFile(renewedKey)
+	require.NoError(t, ioutil.WriteFile(serverKey, keyPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(serverCert, certPEM, 0644))
+	changed := server.changed()
+	require.NoError(t, server.Reload())
+	peer, err := handshake(t, serverConfig, client.ClientConfig("localhost"))
+	//Assert
+	assert.False(t, unchanged)
+	assert.True(t, changed)
+	assert.Nil(t, err)
+	assert.Equal(t, "renewed", peer)
+}
+
+func Test_Reloader_Reload_InvalidFiles_KeepsTheCertificate(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	ca := newAuthority(t)
+	caFile := ca.write(t, dir)
+	serverCert, serverKey := ca.issue(t, dir, "server")
+	server, err := NewReloader(serverCert, serverKey, caFile)
+	require.NoError(t, err)
+	client, _ := NewReloader(serverCert, serverKey, caFile)
+	serverConfig, _ := server.ServerConfig(false)
+	//Act
+	require.NoError(t, ioutil.WriteFile(serverCert, []byte("not a certificate"), 0644))
+	reloadErr := server.Reload()
+	peer, err := handshake(t, serverConfig, client.ClientConfig("localhost"))
+	//Assert
+	assert.NotNil(t, reloadErr)
+	assert.Nil(t, err)
+	assert.Equal(t, "server", peer)
+}
+
+//channelLogger sends the level and message of every entry written to it, the entries are dropped when it is full
+type channelLogger chan string
+
+func (l channelLogger) send(entry string) {
+	select {
+	case l <- entry:
+	default:
+	}
+}
+
+func (l channelLogger) Info(msg string, keyvals ...interface{}) { l.send("info: " + msg) }
+
+func (l channelLogger) Warn(msg string, keyvals ...interface{}) { l.send("warn: " + msg) }
+
+func (l channelLogger) Error(msg string, keyvals ...interface{}) { l.send("error: " + msg) }
+
+func Test_Reloader_Run_LogsTheReloads(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	ca := newAuthority(t)
+	serverCert, serverKey := ca.issue(t, dir, "server")
+	renewedCert, renewedKey := ca.issue(t, dir, "renewed")
+	reloader, err := NewReloader(serverCert, serverKey, "")
+	require.NoError(t, err)
+	logger := make(channelLogger, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Run(ctx, time.Millisecond, logger)
+	//Act
+	require.NoError(t, ioutil.WriteFile(serverCert, []byte("not a certificate"), 0644))
+	failed := <-logger
+	certPEM, _ := ioutil.ReadFile(renewedCert)
+	keyPEM, _ := ioutil.ReadFile(renewedKey)
+	require.NoError(t, ioutil.WriteFile(serverKey, keyPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(serverCert, certPEM, 0644))
+	last := failed
+	for last != "info: reloaded the TLS certificate" {
+		last = <-logger
+	}
+	//Assert
+	assert.Equal(t, "error: reloading the TLS certificate failed", failed)
+}
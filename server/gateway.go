@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/casmelad/bootcamp-gateway/server/ratelimit"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//GatewayOptions returns the options the HTTP gateway needs to translate the user
+//versions between the If-Match and ETag headers and the gRPC metadata, and to stream
+//the changes of the users as Server-Sent Events
+func GatewayOptions() []runtime.ServeMuxOption {
+	return []runtime.ServeMuxOption{
+		runtime.WithMarshalerOption(EventStreamContentType, newEventStreamMarshaler()),
+		runtime.WithIncomingHeaderMatcher(incomingHeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithErrorHandler(errorHandler),
+	}
+}
+
+//incomingHeaderMatcher forwards If-Match, Last-Event-ID, X-Request-Id and X-Api-Key besides the headers forwarded by default,
+//the Authorization header is always forwarded by the gateway as the authorization metadata so it is not
+//duplicated with the default prefix
+func incomingHeaderMatcher(key string) (string, bool) {
+
+	if strings.EqualFold(key, AuthorizationHeader) {
+		return "", false
+	}
+
+	if strings.EqualFold(key, IfMatchHeader) {
+		return IfMatchHeader, true
+	}
+
+	if strings.EqualFold(key, LastEventIDHeader) {
+		return LastEventIDHeader, true
+	}
+
+	if strings.EqualFold(key, RequestIDHeader) {
+		return RequestIDHeader, true
+	}
+
+	if strings.EqualFold(key, APIKeyHeader) {
+		return APIKeyHeader, true
+	}
+
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+//outgoingHeaderMatcher sends the user version as a plain ETag header, the request id as X-Request-Id and the
+//rate limit quota as the X-RateLimit- and Retry-After headers, the rest of the metadata keeps the default
+//Grpc-Metadata- prefix
+func outgoingHeaderMatcher(key string) (string, bool) {
+
+	if key == ETagHeader {
+		return "ETag", true
+	}
+
+	if key == RequestIDHeader {
+		return "X-Request-Id", true
+	}
+
+	switch key {
+	case RateLimitLimitHeader:
+		return ratelimit.LimitHeader, true
+	case RateLimitRemainingHeader:
+		return ratelimit.RemainingHeader, true
+	case RetryAfterHeader:
+		return ratelimit.RetryAfterHeader, true
+	}
+
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+//errorHandler answers version mismatches with 412 Precondition Failed instead of 409 Conflict
+func errorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+
+	if status.Code(err) == codes.Aborted {
+		err = &runtime.HTTPStatusError{HTTPStatus: http.StatusPreconditionFailed, Err: err}
+	}
+
+	runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+}
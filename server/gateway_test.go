@@ -0,0 +1,261 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	pb "github.com/casmelad/bootcamp-gateway/server/proto"
+	implementations "github.com/casmelad/bootcamp-gateway/server/repository"
+	domain "github.com/casmelad/bootcamp-gateway/users"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+//newTestConn returns a connection to a gRPC server for the service, the gRPC server listens on an in-memory
+//connection and is built with the options
+func newTestConn(t *testing.T, service domain.Service, opts ...grpc.ServerOption) *grpc.ClientConn {
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(opts...)
+	pb.RegisterUsersServer(grpcServer, NewUserServer(service))
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+//newTestGateway serves the gateway over httptest in front of the gRPC server of newTestConn
+func newTestGateway(t *testing.T, service domain.Service, opts ...grpc.ServerOption) *httptest.Server {
+
+	conn := newTestConn(t, service, opts...)
+	mux := runtime.NewServeMux(GatewayOptions()...)
+	require.NoError(t, pb.RegisterUsersHandler(context.Background(), mux, conn))
+
+	gateway := httptest.NewServer(mux)
+	t.Cleanup(gateway.Close)
+
+	return gateway
+}
+
+//newTestService returns a users service backed by an empty in-memory repository
+func newTestService() *domain.UserService {
+	return domain.NewUserService(implementations.NewInMemoryUserRepository())
+}
+
+//newPopulatedTestService returns a service with the user test@gmail.com
+func newPopulatedTestService(t *testing.T) *domain.UserService {
+
+	service := newTestService()
+	_, err := service.Create(context.Background(), domain.User{Email: "test@gmail.com", Name: "John", LastName: "Doe"})
+	require.NoError(t, err)
+
+	return service
+}
+
+//doRequest sends the request to the gateway with the headers given as name value pairs
+func doRequest(t *testing.T, gateway *httptest.Server, method, path, body string, headers ...string) *http.Response {
+
+	req, err := http.NewRequest(method, gateway.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := gateway.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+func createTestUser(t *testing.T, gateway *httptest.Server, email string, headers ...string) {
+	resp := doRequest(t, gateway, http.MethodPost, "/api/v1/users", `{"email":"`+email+`","name":"John","last_name":"Doe"}`, headers...)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func Test_Gateway_GetUser_ReturnsTheVersionAsETag(t *testing.T) {
+	//Arrange
+	gateway := newTestGateway(t, newTestService())
+	createTestUser(t, gateway, "test@gmail.com")
+	//Act
+	resp := doRequest(t, gateway, http.MethodGet, "/api/v1/users/test@gmail.com", "")
+	//Assert
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+	assert.Empty(t, resp.Header.Get(runtime.MetadataHeaderPrefix+ETagHeader))
+}
+
+func Test_Gateway_Update_MatchingIfMatch_ReturnsTheNewETag(t *testing.T) {
+	//Arrange
+	gateway := newTestGateway(t, newTestService())
+	createTestUser(t, gateway, "test@gmail.com")
+	//Act
+	resp := doRequest(t, gateway, http.MethodPatch, "/api/v1/users/1", `{"name":"Jane"}`, "If-Match", `"1"`)
+	current := doRequest(t, gateway, http.MethodGet, "/api/v1/users/test@gmail.com", "")
+	//Assert
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+	assert.Equal(t, `"2"`, current.Header.Get("ETag"))
+}
+
+func Test_Gateway_Update_StaleIfMatch_ReturnsPreconditionFailed(t *testing.T) {
+	//Arrange
+	gateway := newTestGateway(t, newTestService())
+	createTestUser(t, gateway, "test@gmail.com")
+	doRequest(t, gateway, http.MethodPatch, "/api/v1/users/1", `{"name":"Jane"}`)
+	//Act
+	stale := doRequest(t, gateway, http.MethodPatch, "/api/v1/users/1", `{"name":"Joe"}`, "If-Match", `"1"`)
+	staleDelete := doRequest(t, gateway, http.MethodDelete, "/api/v1/users/1", "", "If-Match", `"1"`)
+	invalid := doRequest(t, gateway, http.MethodPatch, "/api/v1/users/1", `{"name":"Joe"}`, "If-Match", `"abc"`)
+	current := doRequest(t, gateway, http.MethodGet, "/api/v1/users/test@gmail.com", "")
+	//Assert
+	assert.Equal(t, http.StatusPreconditionFailed, stale.StatusCode)
+	assert.Equal(t, http.StatusPreconditionFailed, staleDelete.StatusCode)
+	assert.Equal(t, http.StatusBadRequest, invalid.StatusCode)
+	assert.Equal(t, `"2"`, current.Header.Get("ETag"))
+}
+
+func Test_Gateway_Update_MaskOfIgnoredFields_ReturnsBadRequest(t *testing.T) {
+	//Arrange
+	gateway := newTestGateway(t, newTestService())
+	createTestUser(t, gateway, "test@gmail.com")
+	//Act
+	body := `{"id":1,"email":"other@gmail.com","name":"Jane","last_name":"Doe"}`
+	queryMask := doRequest(t, gateway, http.MethodPatch, "/api/v1/users/1?update_mask=id", body)
+	bodyMask := doRequest(t, gateway, http.MethodPatch, "/api/v1/users/1", `{"id":1,"version":1}`)
+	current := doRequest(t, gateway, http.MethodGet, "/api/v1/users/test@gmail.com", "")
+	//Assert
+	assert.Equal(t, http.StatusBadRequest, queryMask.StatusCode)
+	assert.Equal(t, http.StatusBadRequest, bodyMask.StatusCode)
+	require.Equal(t, http.StatusOK, current.StatusCode)
+	assert.Equal(t, `"1"`, current.Header.Get("ETag"))
+}
+
+//listPage is the JSON body of a page of users:list
+type listPage struct {
+	Users []struct {
+		Email string `json:"email"`
+	} `json:"users"`
+	NextPageToken string `json:"next_page_token"`
+	TotalSize     int    `json:"total_size"`
+}
+
+//listUsers gets a page of users:list with the query string
+func listUsers(t *testing.T, gateway *httptest.Server, query url.Values) listPage {
+
+	resp := doRequest(t, gateway, http.MethodGet, "/api/v1/users:list?"+query.Encode(), "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var page listPage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+
+	return page
+}
+
+func Test_Gateway_ListUsers_PagesThroughTheFilteredUsers(t *testing.T) {
+	//Arrange
+	gateway := newTestGateway(t, newTestService())
+	for _, email := range []string{"ann@gmail.com", "bob@gmail.com", "anna@gmail.com", "andy@gmail.com"} {
+		createTestUser(t, gateway, email)
+	}
+	query := url.Values{"filter": {"email_prefix=an"}, "page_size": {"2"}}
+	//Act
+	first := listUsers(t, gateway, query)
+	query.Set("page_token", first.NextPageToken)
+	second := listUsers(t, gateway, query)
+	//Assert
+	require.Len(t, first.Users, 2)
+	assert.Equal(t, "ann@gmail.com", first.Users[0].Email)
+	assert.Equal(t, "anna@gmail.com", first.Users[1].Email)
+	assert.NotEmpty(t, first.NextPageToken)
+	assert.Equal(t, 3, first.TotalSize)
+	require.Len(t, second.Users, 1)
+	assert.Equal(t, "andy@gmail.com", second.Users[0].Email)
+	assert.Empty(t, second.NextPageToken)
+	assert.Equal(t, 3, second.TotalSize)
+}
+
+func Test_Gateway_ListUsers_InvalidQuery_ReturnsBadRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unknown filter", "filter=age%3D3"},
+		{"malformed page token", "page_token=abc"},
+		{"page size not a number", "page_size=many"},
+	}
+	gateway := newTestGateway(t, newTestService())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//Act
+			resp := doRequest(t, gateway, http.MethodGet, "/api/v1/users:list?"+tt.query, "")
+			resp.Body.Close()
+			//Assert
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+}
+
+func Test_Gateway_WatchUsers_StreamsServerSentEvents(t *testing.T) {
+	//Arrange
+	service := newTestService()
+	gateway := newTestGateway(t, service)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := service.Watch(ctx, 0)
+	require.NoError(t, err)
+	createTestUser(t, gateway, "first@gmail.com")
+	first := <-w
+	createTestUser(t, gateway, "second@gmail.com")
+	second := <-w
+	//Act
+	resp := doRequest(t, gateway, http.MethodGet, "/api/v1/users:watch", "",
+		"Accept", EventStreamContentType, "Last-Event-ID", strconv.FormatInt(first.Revision, 10))
+	event := readEvent(t, bufio.NewReader(resp.Body))
+	//Assert
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, EventStreamContentType, resp.Header.Get("Content-Type"))
+	require.Len(t, event, 2)
+	assert.Equal(t, "id: "+strconv.FormatInt(second.Revision, 10), event[0])
+	assert.True(t, strings.HasPrefix(event[1], "data: {"))
+	assert.Contains(t, event[1], `"email":"second@gmail.com"`)
+	assert.NotContains(t, event[1], "first@gmail.com")
+}
+
+//readEvent reads the lines of the next Server-Sent Event of the stream
+func readEvent(t *testing.T, stream *bufio.Reader) []string {
+
+	var lines []string
+
+	for {
+		line, err := stream.ReadString('\n')
+		require.NoError(t, err)
+
+		line = strings.TrimSuffix(line, "\n")
+
+		if line == "" {
+			return lines
+		}
+
+		lines = append(lines, line)
+	}
+}
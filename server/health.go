@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	domain "github.com/casmelad/bootcamp-gateway/users"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	//HealthServicePrefix is the prefix of the methods of the grpc.health.v1 service
+	HealthServicePrefix = "/grpc.health.v1.Health/"
+	//DefaultReadinessTimeout bounds the checks of a readiness probe
+	DefaultReadinessTimeout = 2 * time.Second
+)
+
+//Health reports whether the server can take requests, over gRPC with the standard grpc.health.v1 service
+//and over HTTP with the /healthz liveness and /readyz readiness endpoints, the services are NOT_SERVING
+//until the gRPC server is served and again once it stops or Shutdown is called
+type Health struct {
+	server     *health.Server
+	services   []string
+	repository domain.Repository
+}
+
+//NewHealth returns a Health type pointer reporting the status of the services, the repository is checked
+//by the readiness probes when it is a users.Pinger
+func NewHealth(repository domain.Repository, services ...string) *Health {
+
+	h := &Health{server: health.NewServer(), services: append([]string{""}, services...), repository: repository}
+
+	for _, service := range h.services {
+		h.server.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+
+	return h
+}
+
+//Register - registers the grpc.health.v1 service in the gRPC server
+func (h *Health) Register(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, h.server)
+}
+
+//SetServing - reports every service as SERVING
+func (h *Health) SetServing() {
+	for _, service := range h.services {
+		h.server.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	}
+}
+
+//Serve - serves the gRPC server on the listener, reporting every service as SERVING until Serve returns
+func (h *Health) Serve(s *grpc.Server, lis net.Listener) error {
+
+	h.SetServing()
+	defer h.Shutdown()
+
+	return s.Serve(lis)
+}
+
+//Shutdown - reports every service as NOT_SERVING for good
+func (h *Health) Shutdown() {
+	h.server.Shutdown()
+}
+
+//LivenessHandler - answers 200 as long as the process serves HTTP
+func (h *Health) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, nil)
+	})
+}
+
+//ReadinessHandler - answers 200 when the gRPC server is SERVING and the repository can be reached,
+//503 with the failed checks otherwise
+func (h *Health) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx, cancel := context.WithTimeout(r.Context(), DefaultReadinessTimeout)
+		defer cancel()
+
+		checks := h.check(ctx)
+		status := http.StatusOK
+
+		for _, result := range checks {
+			if result != "ok" {
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		writeHealth(w, status, checks)
+	})
+}
+
+//check runs the readiness checks, the result of every check is ok or what failed
+func (h *Health) check(ctx context.Context) map[string]string {
+
+	checks := map[string]string{"grpc": "ok", "repository": "ok"}
+
+	resp, err := h.server.Check(ctx, &healthpb.HealthCheckRequest{})
+
+	if err != nil {
+		checks["grpc"] = err.Error()
+	} else if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		checks["grpc"] = resp.Status.String()
+	}
+
+	if pinger, ok := h.repository.(domain.Pinger); ok {
+		if err := pinger.Ping(ctx); err != nil {
+			checks["repository"] = err.Error()
+		}
+	}
+
+	return checks
+}
+
+func writeHealth(w http.ResponseWriter, status int, checks map[string]string) {
+
+	result := "ok"
+
+	if status != http.StatusOK {
+		result = "unavailable"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks,omitempty"`
+	}{result, checks})
+}
+
+//UnaryExceptHealth runs the interceptor on every call but the health checks, so the probes need no credentials
+func UnaryExceptHealth(interceptor grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		if strings.HasPrefix(info.FullMethod, HealthServicePrefix) {
+			return handler(ctx, req)
+		}
+
+		return interceptor(ctx, req, info, handler)
+	}
+}
+
+//StreamExceptHealth is the streaming counterpart of UnaryExceptHealth
+func StreamExceptHealth(interceptor grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		if strings.HasPrefix(info.FullMethod, HealthServicePrefix) {
+			return handler(srv, ss)
+		}
+
+		return interceptor(srv, ss, info, handler)
+	}
+}
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "github.com/casmelad/bootcamp-gateway/server/proto"
+	implementations "github.com/casmelad/bootcamp-gateway/server/repository"
+	domain "github.com/casmelad/bootcamp-gateway/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+//pingingRepository is a repository whose ping fails with err
+type pingingRepository struct {
+	domain.Repository
+	err error
+}
+
+func (r *pingingRepository) Ping(context.Context) error {
+	return r.err
+}
+
+//healthServer is a gRPC server with the health service, served on an in-memory connection the way main serves it
+type healthServer struct {
+	health   *Health
+	server   *grpc.Server
+	listener *bufconn.Listener
+	client   healthpb.HealthClient
+	served   chan error
+}
+
+//newHealthServer builds the gRPC server of the health and connects to it, the server is not served yet
+func newHealthServer(t *testing.T, repository domain.Repository, opts ...grpc.ServerOption) *healthServer {
+
+	hs := &healthServer{
+		health:   NewHealth(repository, pb.Users_ServiceDesc.ServiceName),
+		server:   grpc.NewServer(opts...),
+		listener: bufconn.Listen(1 << 20),
+		served:   make(chan error, 1),
+	}
+	hs.health.Register(hs.server)
+	t.Cleanup(hs.server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return hs.listener.Dial() }),
+		grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	hs.client = healthpb.NewHealthClient(conn)
+
+	return hs
+}
+
+//serve serves the gRPC server in the background, what Serve returns is sent to served
+func (hs *healthServer) serve() {
+	go func() { hs.served <- hs.health.Serve(hs.server, hs.listener) }()
+}
+
+//readiness answers a readiness probe
+func readiness(t *testing.T, h *Health) (int, map[string]string) {
+
+	recorder := httptest.NewRecorder()
+	h.ReadinessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var body struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&body))
+	assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+	return recorder.Code, body.Checks
+}
+
+//servingStatus checks the service over gRPC, waiting for the server to be served
+func servingStatus(t *testing.T, client healthpb.HealthClient, service string) healthpb.HealthCheckResponse_ServingStatus {
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service}, grpc.WaitForReady(true))
+	require.NoError(t, err)
+
+	return resp.Status
+}
+
+func Test_Health_BeforeServe_IsNotReady(t *testing.T) {
+	//Arrange
+	hs := newHealthServer(t, implementations.NewInMemoryUserRepository())
+	//Act
+	code, checks := readiness(t, hs.health)
+	//Assert
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, map[string]string{"grpc": "NOT_SERVING", "repository": "ok"}, checks)
+}
+
+func Test_Health_Serve_ReportsEveryServiceServing(t *testing.T) {
+	//Arrange
+	hs := newHealthServer(t, &pingingRepository{Repository: implementations.NewInMemoryUserRepository()})
+	//Act
+	hs.serve()
+	//Assert
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, hs.client, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, hs.client, pb.Users_ServiceDesc.ServiceName))
+	_, err := hs.client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown.Service"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	code, checks := readiness(t, hs.health)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]string{"grpc": "ok", "repository": "ok"}, checks)
+}
+
+func Test_Health_Shutdown_ReportsNotServing(t *testing.T) {
+	//Arrange
+	hs := newHealthServer(t, implementations.NewInMemoryUserRepository())
+	hs.serve()
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, hs.client, ""))
+	//Act
+	hs.health.Shutdown()
+	hs.health.SetServing()
+	//Assert
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, hs.client, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, hs.client, pb.Users_ServiceDesc.ServiceName))
+	code, checks := readiness(t, hs.health)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "NOT_SERVING", checks["grpc"])
+}
+
+func Test_Health_ServeExits_IsNotReady(t *testing.T) {
+	//Arrange
+	hs := newHealthServer(t, implementations.NewInMemoryUserRepository())
+	hs.serve()
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, hs.client, ""))
+	//Act
+	hs.server.Stop()
+	//Assert
+	assert.NoError(t, <-hs.served)
+	code, checks := readiness(t, hs.health)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "NOT_SERVING", checks["grpc"])
+}
+
+func Test_Health_ServeFails_IsNotReady(t *testing.T) {
+	//Arrange
+	hs := newHealthServer(t, implementations.NewInMemoryUserRepository())
+	hs.listener.Close()
+	//Act
+	hs.serve()
+	//Assert
+	assert.Error(t, <-hs.served)
+	code, _ := readiness(t, hs.health)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+}
+
+func Test_Health_RepositoryPingFails_IsNotReady(t *testing.T) {
+	//Arrange
+	repository := &pingingRepository{Repository: implementations.NewInMemoryUserRepository(), err: errors.New("connection refused")}
+	hs := newHealthServer(t, repository)
+	hs.serve()
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, hs.client, ""))
+	//Act
+	failedCode, failedChecks := readiness(t, hs.health)
+	repository.err = nil
+	recoveredCode, _ := readiness(t, hs.health)
+	//Assert
+	assert.Equal(t, http.StatusServiceUnavailable, failedCode)
+	assert.Equal(t, map[string]string{"grpc": "ok", "repository": "connection refused"}, failedChecks)
+	assert.Equal(t, http.StatusOK, recoveredCode)
+}
+
+func Test_LivenessHandler_AnswersOKWhileNotServing(t *testing.T) {
+	//Arrange
+	h := NewHealth(implementations.NewInMemoryUserRepository())
+	h.Shutdown()
+	recorder := httptest.NewRecorder()
+	//Act
+	h.LivenessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	//Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"status":"ok"}`, recorder.Body.String())
+}
+
+func Test_ExceptHealth_SkipsTheInterceptorForTheHealthChecks(t *testing.T) {
+	//Arrange
+	errRejected := status.Error(codes.Unauthenticated, "rejected")
+	reject := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return nil, errRejected
+	}
+	rejectStream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return errRejected
+	}
+	hs := newHealthServer(t, implementations.NewInMemoryUserRepository(),
+		grpc.UnaryInterceptor(UnaryExceptHealth(reject)), grpc.StreamInterceptor(StreamExceptHealth(rejectStream)))
+	hs.serve()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "called", nil }
+	//Act
+	checked := servingStatus(t, hs.client, "")
+	watch, err := hs.client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	watched, watchErr := watch.Recv()
+	_, rejectedErr := UnaryExceptHealth(reject)(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/users.Users/GetUser"}, handler)
+	//Assert
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, checked)
+	require.NoError(t, watchErr)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, watched.Status)
+	assert.Equal(t, errRejected, rejectedErr)
+}
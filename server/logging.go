@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/server/logging"
+	domain "github.com/casmelad/bootcamp-gateway/users"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+//UnaryLoggingInterceptor logs the method, status code, latency and request id of every call, the messages and
+//the error details are never logged since they can hold personal data, it must run after UnaryRequestIDInterceptor
+func UnaryLoggingInterceptor(logger *logging.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, logger, info.FullMethod, start, err)
+
+		return resp, err
+	}
+}
+
+//StreamLoggingInterceptor is the streaming counterpart of UnaryLoggingInterceptor, streams are logged when they end
+func StreamLoggingInterceptor(logger *logging.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), logger, info.FullMethod, start, err)
+
+		return err
+	}
+}
+
+//logCall logs a finished call, at the error level when the server failed
+func logCall(ctx context.Context, logger *logging.Logger, method string, start time.Time, err error) {
+
+	code := status.Code(err)
+	level := logging.LevelInfo
+
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = logging.LevelError
+	}
+
+	keyvals := []interface{}{
+		"method", method,
+		"code", code.String(),
+		"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+		"request_id", domain.RequestIDFromContext(ctx),
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		keyvals = append(keyvals, "peer", p.Addr.String())
+	}
+
+	logger.Log(level, "rpc", keyvals...)
+}
+
+//usersPath is the path of the users resource, a user is read at usersPath + {email}
+const usersPath = "/api/v1/users/"
+
+//LoggingMiddleware logs the method, path, status code, latency and request id of every HTTP request, the email
+//of the path of a user is replaced with {email}, it must run after RequestIDMiddleware
+func LoggingMiddleware(logger *logging.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		level := logging.LevelInfo
+
+		if recorder.status >= http.StatusInternalServerError {
+			level = logging.LevelError
+		}
+
+		logger.Log(level, "http",
+			"method", r.Method,
+			"path", redactPath(r.URL.Path),
+			"status", recorder.status,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+			"request_id", r.Header.Get(RequestIDHeader),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+//redactPath replaces the segment of the path naming a user with {email} unless it is the id of the user
+func redactPath(path string) string {
+
+	if !strings.HasPrefix(path, usersPath) {
+		return path
+	}
+
+	segments := strings.SplitN(strings.TrimPrefix(path, usersPath), "/", 2)
+
+	if _, err := strconv.ParseInt(segments[0], 10, 64); err == nil {
+		return path
+	}
+
+	segments[0] = "{email}"
+
+	return usersPath + strings.Join(segments, "/")
+}
+
+//statusRecorder keeps the status code written, flushing is passed through so the streams are not buffered
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,188 @@
+//Package logging writes structured logs as JSON lines
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Level - the severity of a log entry
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{LevelDebug: "debug", LevelInfo: "info", LevelWarn: "warn", LevelError: "error"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+//ParseLevel - returns the level with the name, debug, info, warn or error
+func ParseLevel(name string) (Level, error) {
+
+	for level, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return level, nil
+		}
+	}
+
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+//Redacted replaces the values of the redacted fields
+const Redacted = "[REDACTED]"
+
+//DefaultRedactedFields are the fields holding personal data of the users, their values are never written
+var DefaultRedactedFields = []string{"email", "name", "last_name"}
+
+//Option - configures optional behavior of a Logger
+type Option func(*Logger)
+
+//WithRedactedFields - redacts the fields instead of DefaultRedactedFields
+func WithRedactedFields(fields ...string) Option {
+	return func(l *Logger) {
+		l.redacted = map[string]bool{}
+		for _, f := range fields {
+			l.redacted[strings.ToLower(f)] = true
+		}
+	}
+}
+
+//Logger writes the entries at or above its level as JSON lines, it is safe for concurrent use
+type Logger struct {
+	mu       *sync.Mutex
+	out      io.Writer
+	level    Level
+	redacted map[string]bool
+	//fields are written with every entry
+	fields []interface{}
+	now    func() time.Time
+}
+
+//New returns a Logger type pointer writing to out
+func New(out io.Writer, level Level, opts ...Option) *Logger {
+
+	l := &Logger{mu: &sync.Mutex{}, out: out, level: level, now: time.Now}
+
+	WithRedactedFields(DefaultRedactedFields...)(l)
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+//With - returns a Logger writing the key value pairs with every entry
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+
+	child := *l
+	child.fields = append(append([]interface{}{}, l.fields...), keyvals...)
+
+	return &child
+}
+
+//Enabled - reports whether the entries of the level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+//Debug - writes an entry at the debug level
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.Log(LevelDebug, msg, keyvals...)
+}
+
+//Info - writes an entry at the info level
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.Log(LevelInfo, msg, keyvals...)
+}
+
+//Warn - writes an entry at the warn level
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.Log(LevelWarn, msg, keyvals...)
+}
+
+//Error - writes an entry at the error level
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.Log(LevelError, msg, keyvals...)
+}
+
+//Log - writes an entry with the message and the key value pairs, a key without value is written as !MISSING
+func (l *Logger) Log(level Level, msg string, keyvals ...interface{}) {
+
+	if !l.Enabled(level) {
+		return
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString(`{"time":`)
+	writeValue(&buf, l.now().UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeValue(&buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeValue(&buf, msg)
+
+	l.writeFields(&buf, l.fields)
+	l.writeFields(&buf, keyvals)
+
+	buf.WriteString("}\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.out.Write(buf.Bytes())
+}
+
+func (l *Logger) writeFields(buf *bytes.Buffer, keyvals []interface{}) {
+
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+
+		var value interface{} = "!MISSING"
+
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+
+		if l.redacted[strings.ToLower(key)] {
+			value = Redacted
+		}
+
+		buf.WriteByte(',')
+		writeValue(buf, key)
+		buf.WriteByte(':')
+		writeValue(buf, value)
+	}
+}
+
+//writeValue writes the value as JSON, errors and durations as their text
+func writeValue(buf *bytes.Buffer, value interface{}) {
+
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case time.Duration:
+		value = v.String()
+	case fmt.Stringer:
+		value = v.String()
+	}
+
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+
+	buf.Write(data)
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLogger(level Level, opts ...Option) (*Logger, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	l := New(out, level, opts...)
+	l.now = func() time.Time { return time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC) }
+	return l, out
+}
+
+func Test_Logger_WritesJSONLines(t *testing.T) {
+	//Arrange
+	logger, out := newTestLogger(LevelInfo)
+	//Act
+	logger.With("component", "test").Info("rpc", "method", "/users.Users/GetUser", "latency", 1500*time.Millisecond,
+		"error", errors.New("boom"), "count", 2)
+	//Assert
+	assert.Equal(t, `{"time":"2021-12-01T10:00:00Z","level":"info","msg":"rpc","component":"test","method":"/users.Users/GetUser",`+
+		`"latency":"1.5s","error":"boom","count":2}`+"\n", out.String())
+}
+
+func Test_Logger_SkipsEntriesBelowTheLevel(t *testing.T) {
+	//Arrange
+	logger, out := newTestLogger(LevelWarn)
+	//Act
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Error("error")
+	//Assert
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"level":"warn"`)
+	assert.Contains(t, lines[1], `"level":"error"`)
+}
+
+func Test_Logger_RedactsPersonalData(t *testing.T) {
+	//Arrange
+	logger, out := newTestLogger(LevelInfo)
+	custom, customOut := newTestLogger(LevelInfo, WithRedactedFields("token"))
+	//Act
+	logger.Info("user", "Email", "test@gmail.com", "name", "John", "last_name", "Doe", "id", 7)
+	custom.Info("user", "email", "test@gmail.com", "token", "abc")
+	//Assert
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, Redacted, entry["Email"])
+	assert.Equal(t, Redacted, entry["name"])
+	assert.Equal(t, Redacted, entry["last_name"])
+	assert.Equal(t, float64(7), entry["id"])
+	assert.NotContains(t, out.String(), "test@gmail.com")
+	assert.Contains(t, customOut.String(), `"email":"test@gmail.com","token":"[REDACTED]"`)
+}
+
+func Test_Logger_KeyWithoutValue(t *testing.T) {
+	//Arrange
+	logger, out := newTestLogger(LevelInfo)
+	//Act
+	logger.Info("odd", "key")
+	//Assert
+	assert.Contains(t, out.String(), `"key":"!MISSING"`)
+}
+
+func Test_ParseLevel(t *testing.T) {
+	//Act
+	level, err := ParseLevel("WARN")
+	_, unknownErr := ParseLevel("verbose")
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, LevelWarn, level)
+	assert.NotNil(t, unknownErr)
+}
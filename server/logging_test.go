@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/casmelad/bootcamp-gateway/server/logging"
+	pb "github.com/casmelad/bootcamp-gateway/server/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+//logEntries decodes the JSON lines written by a logger
+func logEntries(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+
+	var entries []map[string]interface{}
+	decoder := json.NewDecoder(out)
+
+	for {
+		var entry map[string]interface{}
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+}
+
+//newLoggedConn returns a connection to a gRPC server logging its calls to out
+func newLoggedConn(t *testing.T, out *bytes.Buffer) *grpc.ClientConn {
+
+	logger := logging.New(out, logging.LevelDebug)
+
+	return newTestConn(t, newPopulatedTestService(t),
+		grpc.ChainUnaryInterceptor(UnaryRequestIDInterceptor, UnaryLoggingInterceptor(logger)),
+		grpc.ChainStreamInterceptor(StreamRequestIDInterceptor, StreamLoggingInterceptor(logger)))
+}
+
+func Test_UnaryLoggingInterceptor_LogsTheCall(t *testing.T) {
+	//Arrange
+	var out bytes.Buffer
+	client := pb.NewUsersClient(newLoggedConn(t, &out))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), RequestIDHeader, "req-1")
+	//Act
+	_, errFound := client.GetUser(ctx, &pb.GetUserRequest{Email: "test@gmail.com"})
+	_, errMissing := client.GetUser(context.Background(), &pb.GetUserRequest{Email: "missing@gmail.com"})
+	//Assert
+	require.NoError(t, errFound)
+	require.Equal(t, codes.NotFound, status.Code(errMissing))
+	assert.NotContains(t, out.String(), "@gmail.com")
+	entries := logEntries(t, &out)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "rpc", entries[0]["msg"])
+	assert.Equal(t, "info", entries[0]["level"])
+	assert.Equal(t, "/users.Users/GetUser", entries[0]["method"])
+	assert.Equal(t, "OK", entries[0]["code"])
+	assert.Equal(t, "req-1", entries[0]["request_id"])
+	assert.IsType(t, float64(0), entries[0]["latency_ms"])
+	assert.Equal(t, "bufconn", entries[0]["peer"])
+	assert.Equal(t, "NotFound", entries[1]["code"])
+	assert.Equal(t, "info", entries[1]["level"])
+	assert.Len(t, entries[1]["request_id"], 32)
+}
+
+func Test_UnaryLoggingInterceptor_ServerFailure_LogsAnError(t *testing.T) {
+	//Arrange
+	var out bytes.Buffer
+	interceptor := UnaryLoggingInterceptor(logging.New(&out, logging.LevelInfo))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Errorf(codes.Internal, "failed for test@gmail.com")
+	}
+	//Act
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/users.Users/Create"}, handler)
+	//Assert
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.NotContains(t, out.String(), "test@gmail.com")
+	entries := logEntries(t, &out)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "error", entries[0]["level"])
+	assert.Equal(t, "Internal", entries[0]["code"])
+	assert.Equal(t, "", entries[0]["request_id"])
+	assert.NotContains(t, entries[0], "peer")
+}
+
+func Test_StreamLoggingInterceptor_LogsTheStreamWhenItEnds(t *testing.T) {
+	//Arrange
+	var out bytes.Buffer
+	client := pb.NewUsersClient(newLoggedConn(t, &out))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), RequestIDHeader, "req-2")
+	stream, err := client.GetAllUsers(ctx, &pb.GetAllUsersRequest{})
+	require.NoError(t, err)
+	//Act
+	_, errFirst := stream.Recv()
+	_, errEnd := stream.Recv()
+	//Assert
+	require.NoError(t, errFirst)
+	require.Equal(t, io.EOF, errEnd)
+	entries := logEntries(t, &out)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "/users.Users/GetAllUsers", entries[0]["method"])
+	assert.Equal(t, "OK", entries[0]["code"])
+	assert.Equal(t, "req-2", entries[0]["request_id"])
+}
+
+func Test_LoggingMiddleware_LogsTheRequest(t *testing.T) {
+	//Arrange
+	var out bytes.Buffer
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "fail") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	server := httptest.NewServer(RequestIDMiddleware(LoggingMiddleware(logging.New(&out, logging.LevelInfo), handler)))
+	defer server.Close()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/users/ok", nil)
+	req.Header.Set("X-Request-Id", "req-3")
+	//Act
+	ok, err := server.Client().Do(req)
+	require.NoError(t, err)
+	ok.Body.Close()
+	failed, err := server.Client().Post(server.URL+"/api/v1/users/fail", "application/json", nil)
+	require.NoError(t, err)
+	failed.Body.Close()
+	//Assert
+	entries := logEntries(t, &out)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "http", entries[0]["msg"])
+	assert.Equal(t, "info", entries[0]["level"])
+	assert.Equal(t, "GET", entries[0]["method"])
+	assert.Equal(t, "/api/v1/users/{email}", entries[0]["path"])
+	assert.Equal(t, float64(http.StatusOK), entries[0]["status"])
+	assert.Equal(t, "req-3", entries[0]["request_id"])
+	assert.NotEmpty(t, entries[0]["remote_addr"])
+	assert.Equal(t, "error", entries[1]["level"])
+	assert.Equal(t, "POST", entries[1]["method"])
+	assert.Equal(t, float64(http.StatusServiceUnavailable), entries[1]["status"])
+	assert.Len(t, entries[1]["request_id"], 32)
+}
+
+func Test_LoggingMiddleware_DoesNotLogTheEmailOfThePath(t *testing.T) {
+	//Arrange
+	var out bytes.Buffer
+	mux := runtime.NewServeMux(GatewayOptions()...)
+	require.NoError(t, pb.RegisterUsersHandler(context.Background(), mux, newTestConn(t, newPopulatedTestService(t))))
+	gateway := httptest.NewServer(LoggingMiddleware(logging.New(&out, logging.LevelInfo), mux))
+	defer gateway.Close()
+	//Act
+	resp := doRequest(t, gateway, http.MethodGet, "/api/v1/users/test@gmail.com", "")
+	resp.Body.Close()
+	//Assert
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	entries := logEntries(t, &out)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "/api/v1/users/{email}", entries[0]["path"])
+	assert.NotContains(t, out.String(), "test@gmail.com")
+}
+
+func Test_RedactPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		redacted string
+	}{
+		{"/api/v1/users/test@gmail.com", "/api/v1/users/{email}"},
+		{"/api/v1/users/test%40gmail.com", "/api/v1/users/{email}"},
+		{"/api/v1/users/42", "/api/v1/users/42"},
+		{"/api/v1/users", "/api/v1/users"},
+		{"/api/v1/webhooks/7", "/api/v1/webhooks/7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			//Act
+			redacted := redactPath(tt.path)
+			//Assert
+			assert.Equal(t, tt.redacted, redacted)
+		})
+	}
+}
+
+func Test_StatusRecorder_PassesFlushThrough(t *testing.T) {
+	//Arrange
+	w := httptest.NewRecorder()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	var flusher http.Flusher = recorder
+	//Act
+	recorder.WriteHeader(http.StatusAccepted)
+	flusher.Flush()
+	//Assert
+	assert.Equal(t, http.StatusAccepted, recorder.status)
+	assert.True(t, w.Flushed)
+}
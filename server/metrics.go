@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+//Metrics counts and times the RPCs and the HTTP requests served
+type Metrics struct {
+	rpcHandled   *prometheus.CounterVec
+	rpcLatency   *prometheus.HistogramVec
+	httpRequests *prometheus.CounterVec
+	httpLatency  *prometheus.HistogramVec
+}
+
+//NewMetrics registers the metrics of the RPCs and of the HTTP requests in the registerer, it panics
+//when they are already registered
+func NewMetrics(registerer prometheus.Registerer) *Metrics {
+
+	factory := promauto.With(registerer)
+
+	return &Metrics{
+		rpcHandled: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "RPCs completed on the server, by status code.",
+		}, []string{"grpc_service", "grpc_method", "grpc_code"}),
+		rpcLatency: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Time the server took to handle the RPCs.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"grpc_service", "grpc_method"}),
+		httpRequests: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests completed by the gateway, by method and status code.",
+		}, []string{"method", "code"}),
+		httpLatency: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time the gateway took to answer the HTTP requests.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+}
+
+//UnaryMetricsInterceptor counts the calls by method and status code and records their latency
+func UnaryMetricsInterceptor(m *Metrics) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observeCall(info.FullMethod, start, err)
+
+		return resp, err
+	}
+}
+
+//StreamMetricsInterceptor is the streaming counterpart of UnaryMetricsInterceptor, streams are observed when they end
+func StreamMetricsInterceptor(m *Metrics) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		start := time.Now()
+		err := handler(srv, ss)
+		m.observeCall(info.FullMethod, start, err)
+
+		return err
+	}
+}
+
+func (m *Metrics) observeCall(fullMethod string, start time.Time, err error) {
+
+	service, method := splitMethod(fullMethod)
+
+	m.rpcHandled.WithLabelValues(service, method, status.Code(err).String()).Inc()
+	m.rpcLatency.WithLabelValues(service, method).Observe(time.Since(start).Seconds())
+}
+
+//splitMethod splits /package.Service/Method into the service and the method names
+func splitMethod(fullMethod string) (string, string) {
+
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+
+	return "unknown", fullMethod
+}
+
+//MetricsMiddleware counts the HTTP requests by method and status code and records their latency, the paths
+//are left out since they hold the emails of the users
+func MetricsMiddleware(m *Metrics, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		m.httpRequests.WithLabelValues(r.Method, strconv.Itoa(recorder.status)).Inc()
+		m.httpLatency.WithLabelValues(r.Method).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "github.com/casmelad/bootcamp-gateway/server/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+//scrape returns the metrics of the registry the way Prometheus reads them
+func scrape(t *testing.T, registry *prometheus.Registry) string {
+
+	recorder := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorHandling: promhttp.PanicOnError}).
+		ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	return recorder.Body.String()
+}
+
+func Test_MetricsInterceptors_RecordTheCalls(t *testing.T) {
+	//Arrange
+	registry := prometheus.NewRegistry()
+	m := NewMetrics(registry)
+	conn := newTestConn(t, newPopulatedTestService(t),
+		grpc.UnaryInterceptor(UnaryMetricsInterceptor(m)), grpc.StreamInterceptor(StreamMetricsInterceptor(m)))
+	client := pb.NewUsersClient(conn)
+	//Act
+	client.GetUser(context.Background(), &pb.GetUserRequest{Email: "test@gmail.com"})
+	client.GetUser(context.Background(), &pb.GetUserRequest{Email: "missing@gmail.com"})
+	stream, err := client.GetAllUsers(context.Background(), &pb.GetAllUsersRequest{})
+	require.NoError(t, err)
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	//Assert
+	require.Equal(t, io.EOF, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.rpcHandled.WithLabelValues("users.Users", "GetUser", "OK")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.rpcHandled.WithLabelValues("users.Users", "GetUser", "NotFound")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.rpcHandled.WithLabelValues("users.Users", "GetAllUsers", "OK")))
+	out := scrape(t, registry)
+	assert.Contains(t, out, "# TYPE grpc_server_handled_total counter")
+	assert.Contains(t, out, "# TYPE grpc_server_handling_seconds histogram")
+	assert.Contains(t, out, `grpc_server_handling_seconds_count{grpc_method="GetUser",grpc_service="users.Users"} 2`)
+	assert.Contains(t, out, `grpc_server_handling_seconds_bucket{grpc_method="GetUser",grpc_service="users.Users",le="+Inf"} 2`)
+	assert.NotContains(t, out, "@gmail.com")
+}
+
+func Test_MetricsMiddleware_RecordsTheRequests(t *testing.T) {
+	//Arrange
+	registry := prometheus.NewRegistry()
+	m := NewMetrics(registry)
+	handler := MetricsMiddleware(m, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "missing@gmail.com") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	//Act
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/users/test@gmail.com", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/users/missing@gmail.com", nil))
+	//Assert
+	out := scrape(t, registry)
+	assert.Contains(t, out, "# TYPE http_requests_total counter")
+	assert.Contains(t, out, `http_requests_total{code="200",method="GET"} 1`)
+	assert.Contains(t, out, `http_requests_total{code="404",method="GET"} 1`)
+	assert.Contains(t, out, `http_request_duration_seconds_count{method="GET"} 2`)
+	assert.NotContains(t, out, "@gmail.com")
+}
+
+func Test_NewMetrics_AlreadyRegistered_Panics(t *testing.T) {
+	//Arrange
+	registry := prometheus.NewRegistry()
+	NewMetrics(registry)
+	//Act
+	register := func() { NewMetrics(registry) }
+	//Assert
+	assert.Panics(t, register)
+}
+
+func Test_SplitMethod(t *testing.T) {
+	tests := []struct {
+		fullMethod string
+		service    string
+		method     string
+	}{
+		{"/users.Users/GetUser", "users.Users", "GetUser"},
+		{"/grpc.health.v1.Health/Check", "grpc.health.v1.Health", "Check"},
+		{"malformed", "unknown", "malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fullMethod, func(t *testing.T) {
+			//Act
+			service, method := splitMethod(tt.fullMethod)
+			//Assert
+			assert.Equal(t, tt.service, service)
+			assert.Equal(t, tt.method, method)
+		})
+	}
+}
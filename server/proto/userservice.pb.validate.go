@@ -74,6 +74,99 @@ func (m *User) validate(all bool) error {
 
 	// no validation rules for LastName
 
+	// no validation rules for Version
+
+	if all {
+		switch v := interface{}(m.GetDeletedAt()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, UserValidationError{
+					field:  "DeletedAt",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, UserValidationError{
+					field:  "DeletedAt",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetDeletedAt()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return UserValidationError{
+				field:  "DeletedAt",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	if all {
+		switch v := interface{}(m.GetCreateTime()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, UserValidationError{
+					field:  "CreateTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, UserValidationError{
+					field:  "CreateTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetCreateTime()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return UserValidationError{
+				field:  "CreateTime",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	if all {
+		switch v := interface{}(m.GetUpdateTime()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, UserValidationError{
+					field:  "UpdateTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, UserValidationError{
+					field:  "UpdateTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetUpdateTime()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return UserValidationError{
+				field:  "UpdateTime",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	// no validation rules for CreatedBy
+
+	// no validation rules for UpdatedBy
+
 	if len(errors) > 0 {
 		return UserMultiError(errors)
 	}
@@ -416,6 +509,35 @@ func (m *UpdateRequest) validate(all bool) error {
 		}
 	}
 
+	if all {
+		switch v := interface{}(m.GetUpdateMask()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, UpdateRequestValidationError{
+					field:  "UpdateMask",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, UpdateRequestValidationError{
+					field:  "UpdateMask",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetUpdateMask()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return UpdateRequestValidationError{
+				field:  "UpdateMask",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
 	if len(errors) > 0 {
 		return UpdateRequestMultiError(errors)
 	}
@@ -515,6 +637,12 @@ func (m *GetAllUsersRequest) validate(all bool) error {
 
 	var errors []error
 
+	// no validation rules for PageSize
+
+	// no validation rules for PageToken
+
+	// no validation rules for Filter
+
 	if len(errors) > 0 {
 		return GetAllUsersRequestMultiError(errors)
 	}
@@ -618,6 +746,8 @@ func (m *DeleteRequest) validate(all bool) error {
 
 	// no validation rules for Id
 
+	// no validation rules for Version
+
 	if len(errors) > 0 {
 		return DeleteRequestMultiError(errors)
 	}
@@ -695,6 +825,211 @@ var _ interface {
 	ErrorName() string
 } = DeleteRequestValidationError{}
 
+// Validate checks the field values on UndeleteRequest with the rules defined
+// in the proto definition for this message. If any rules are violated, the
+// first error encountered is returned, or nil if there are no violations.
+func (m *UndeleteRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on UndeleteRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// UndeleteRequestMultiError, or nil if none found.
+func (m *UndeleteRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *UndeleteRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Id
+
+	// no validation rules for Version
+
+	if len(errors) > 0 {
+		return UndeleteRequestMultiError(errors)
+	}
+	return nil
+}
+
+// UndeleteRequestMultiError is an error wrapping multiple validation errors
+// returned by UndeleteRequest.ValidateAll() if the designated constraints
+// aren't met.
+type UndeleteRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m UndeleteRequestMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m UndeleteRequestMultiError) AllErrors() []error { return m }
+
+// UndeleteRequestValidationError is the validation error returned by
+// UndeleteRequest.Validate if the designated constraints aren't met.
+type UndeleteRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e UndeleteRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e UndeleteRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e UndeleteRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e UndeleteRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e UndeleteRequestValidationError) ErrorName() string { return "UndeleteRequestValidationError" }
+
+// Error satisfies the builtin error interface
+func (e UndeleteRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sUndeleteRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = UndeleteRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = UndeleteRequestValidationError{}
+
+// Validate checks the field values on PurgeRequest with the rules defined in
+// the proto definition for this message. If any rules are violated, the first
+// error encountered is returned, or nil if there are no violations.
+func (m *PurgeRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on PurgeRequest with the rules defined
+// in the proto definition for this message. If any rules are violated, the
+// result is a list of violation errors wrapped in PurgeRequestMultiError, or
+// nil if none found.
+func (m *PurgeRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *PurgeRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Id
+
+	// no validation rules for Version
+
+	if len(errors) > 0 {
+		return PurgeRequestMultiError(errors)
+	}
+	return nil
+}
+
+// PurgeRequestMultiError is an error wrapping multiple validation errors
+// returned by PurgeRequest.ValidateAll() if the designated constraints aren't met.
+type PurgeRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m PurgeRequestMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m PurgeRequestMultiError) AllErrors() []error { return m }
+
+// PurgeRequestValidationError is the validation error returned by
+// PurgeRequest.Validate if the designated constraints aren't met.
+type PurgeRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e PurgeRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e PurgeRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e PurgeRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e PurgeRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e PurgeRequestValidationError) ErrorName() string { return "PurgeRequestValidationError" }
+
+// Error satisfies the builtin error interface
+func (e PurgeRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sPurgeRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = PurgeRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = PurgeRequestValidationError{}
+
 // Validate checks the field values on GetUserRequest with the rules defined in
 // the proto definition for this message. If any rules are violated, the first
 // error encountered is returned, or nil if there are no violations.
@@ -1056,6 +1391,10 @@ func (m *GetAllUsersResponse) validate(all bool) error {
 
 	}
 
+	// no validation rules for NextPageToken
+
+	// no validation rules for TotalSize
+
 	if len(errors) > 0 {
 		return GetAllUsersResponseMultiError(errors)
 	}
@@ -1363,3 +1702,2575 @@ var _ interface {
 	Cause() error
 	ErrorName() string
 } = DeleteResponseValidationError{}
+
+// Validate checks the field values on FieldChange with the rules defined in
+// the proto definition for this message. If any rules are violated, the first
+// error encountered is returned, or nil if there are no violations.
+func (m *FieldChange) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on FieldChange with the rules defined in
+// the proto definition for this message. If any rules are violated, the
+// result is a list of violation errors wrapped in FieldChangeMultiError, or
+// nil if none found.
+func (m *FieldChange) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *FieldChange) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Field
+
+	// no validation rules for Before
+
+	// no validation rules for After
+
+	if len(errors) > 0 {
+		return FieldChangeMultiError(errors)
+	}
+	return nil
+}
+
+// FieldChangeMultiError is an error wrapping multiple validation errors
+// returned by FieldChange.ValidateAll() if the designated constraints aren't met.
+type FieldChangeMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m FieldChangeMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m FieldChangeMultiError) AllErrors() []error { return m }
+
+// FieldChangeValidationError is the validation error returned by
+// FieldChange.Validate if the designated constraints aren't met.
+type FieldChangeValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e FieldChangeValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e FieldChangeValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e FieldChangeValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e FieldChangeValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e FieldChangeValidationError) ErrorName() string { return "FieldChangeValidationError" }
+
+// Error satisfies the builtin error interface
+func (e FieldChangeValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sFieldChange.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = FieldChangeValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = FieldChangeValidationError{}
+
+// Validate checks the field values on AuditEvent with the rules defined in the
+// proto definition for this message. If any rules are violated, the first
+// error encountered is returned, or nil if there are no violations.
+func (m *AuditEvent) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on AuditEvent with the rules defined in
+// the proto definition for this message. If any rules are violated, the
+// result is a list of violation errors wrapped in AuditEventMultiError, or
+// nil if none found.
+func (m *AuditEvent) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *AuditEvent) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	if all {
+		switch v := interface{}(m.GetTime()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, AuditEventValidationError{
+					field:  "Time",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, AuditEventValidationError{
+					field:  "Time",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetTime()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return AuditEventValidationError{
+				field:  "Time",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	// no validation rules for Action
+
+	// no validation rules for UserId
+
+	// no validation rules for Actor
+
+	// no validation rules for RequestId
+
+	for idx, item := range m.GetChanges() {
+		_, _ = idx, item
+
+		if all {
+			switch v := interface{}(item).(type) {
+			case interface{ ValidateAll() error }:
+				if err := v.ValidateAll(); err != nil {
+					errors = append(errors, AuditEventValidationError{
+						field:  fmt.Sprintf("Changes[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			case interface{ Validate() error }:
+				if err := v.Validate(); err != nil {
+					errors = append(errors, AuditEventValidationError{
+						field:  fmt.Sprintf("Changes[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			}
+		} else if v, ok := interface{}(item).(interface{ Validate() error }); ok {
+			if err := v.Validate(); err != nil {
+				return AuditEventValidationError{
+					field:  fmt.Sprintf("Changes[%v]", idx),
+					reason: "embedded message failed validation",
+					cause:  err,
+				}
+			}
+		}
+
+	}
+
+	if len(errors) > 0 {
+		return AuditEventMultiError(errors)
+	}
+	return nil
+}
+
+// AuditEventMultiError is an error wrapping multiple validation errors
+// returned by AuditEvent.ValidateAll() if the designated constraints aren't met.
+type AuditEventMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m AuditEventMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m AuditEventMultiError) AllErrors() []error { return m }
+
+// AuditEventValidationError is the validation error returned by
+// AuditEvent.Validate if the designated constraints aren't met.
+type AuditEventValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e AuditEventValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e AuditEventValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e AuditEventValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e AuditEventValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e AuditEventValidationError) ErrorName() string { return "AuditEventValidationError" }
+
+// Error satisfies the builtin error interface
+func (e AuditEventValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sAuditEvent.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = AuditEventValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = AuditEventValidationError{}
+
+// Validate checks the field values on ListAuditEventsRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *ListAuditEventsRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on ListAuditEventsRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// ListAuditEventsRequestMultiError, or nil if none found.
+func (m *ListAuditEventsRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *ListAuditEventsRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for UserId
+
+	if all {
+		switch v := interface{}(m.GetStartTime()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, ListAuditEventsRequestValidationError{
+					field:  "StartTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, ListAuditEventsRequestValidationError{
+					field:  "StartTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetStartTime()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return ListAuditEventsRequestValidationError{
+				field:  "StartTime",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	if all {
+		switch v := interface{}(m.GetEndTime()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, ListAuditEventsRequestValidationError{
+					field:  "EndTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, ListAuditEventsRequestValidationError{
+					field:  "EndTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetEndTime()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return ListAuditEventsRequestValidationError{
+				field:  "EndTime",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return ListAuditEventsRequestMultiError(errors)
+	}
+	return nil
+}
+
+// ListAuditEventsRequestMultiError is an error wrapping multiple validation
+// errors returned by ListAuditEventsRequest.ValidateAll() if the designated
+// constraints aren't met.
+type ListAuditEventsRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m ListAuditEventsRequestMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m ListAuditEventsRequestMultiError) AllErrors() []error { return m }
+
+// ListAuditEventsRequestValidationError is the validation error returned by
+// ListAuditEventsRequest.Validate if the designated constraints aren't met.
+type ListAuditEventsRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e ListAuditEventsRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e ListAuditEventsRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e ListAuditEventsRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e ListAuditEventsRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e ListAuditEventsRequestValidationError) ErrorName() string {
+	return "ListAuditEventsRequestValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e ListAuditEventsRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sListAuditEventsRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = ListAuditEventsRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = ListAuditEventsRequestValidationError{}
+
+// Validate checks the field values on ListAuditEventsResponse with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *ListAuditEventsResponse) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on ListAuditEventsResponse with the
+// rules defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// ListAuditEventsResponseMultiError, or nil if none found.
+func (m *ListAuditEventsResponse) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *ListAuditEventsResponse) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	for idx, item := range m.GetEvents() {
+		_, _ = idx, item
+
+		if all {
+			switch v := interface{}(item).(type) {
+			case interface{ ValidateAll() error }:
+				if err := v.ValidateAll(); err != nil {
+					errors = append(errors, ListAuditEventsResponseValidationError{
+						field:  fmt.Sprintf("Events[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			case interface{ Validate() error }:
+				if err := v.Validate(); err != nil {
+					errors = append(errors, ListAuditEventsResponseValidationError{
+						field:  fmt.Sprintf("Events[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			}
+		} else if v, ok := interface{}(item).(interface{ Validate() error }); ok {
+			if err := v.Validate(); err != nil {
+				return ListAuditEventsResponseValidationError{
+					field:  fmt.Sprintf("Events[%v]", idx),
+					reason: "embedded message failed validation",
+					cause:  err,
+				}
+			}
+		}
+
+	}
+
+	if len(errors) > 0 {
+		return ListAuditEventsResponseMultiError(errors)
+	}
+	return nil
+}
+
+// ListAuditEventsResponseMultiError is an error wrapping multiple validation
+// errors returned by ListAuditEventsResponse.ValidateAll() if the designated
+// constraints aren't met.
+type ListAuditEventsResponseMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m ListAuditEventsResponseMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m ListAuditEventsResponseMultiError) AllErrors() []error { return m }
+
+// ListAuditEventsResponseValidationError is the validation error returned by
+// ListAuditEventsResponse.Validate if the designated constraints aren't met.
+type ListAuditEventsResponseValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e ListAuditEventsResponseValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e ListAuditEventsResponseValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e ListAuditEventsResponseValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e ListAuditEventsResponseValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e ListAuditEventsResponseValidationError) ErrorName() string {
+	return "ListAuditEventsResponseValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e ListAuditEventsResponseValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sListAuditEventsResponse.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = ListAuditEventsResponseValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = ListAuditEventsResponseValidationError{}
+
+// Validate checks the field values on WatchUsersRequest with the rules defined
+// in the proto definition for this message. If any rules are violated, the
+// first error encountered is returned, or nil if there are no violations.
+func (m *WatchUsersRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on WatchUsersRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// WatchUsersRequestMultiError, or nil if none found.
+func (m *WatchUsersRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *WatchUsersRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Revision
+
+	if len(errors) > 0 {
+		return WatchUsersRequestMultiError(errors)
+	}
+	return nil
+}
+
+// WatchUsersRequestMultiError is an error wrapping multiple validation errors
+// returned by WatchUsersRequest.ValidateAll() if the designated constraints
+// aren't met.
+type WatchUsersRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m WatchUsersRequestMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m WatchUsersRequestMultiError) AllErrors() []error { return m }
+
+// WatchUsersRequestValidationError is the validation error returned by
+// WatchUsersRequest.Validate if the designated constraints aren't met.
+type WatchUsersRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e WatchUsersRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e WatchUsersRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e WatchUsersRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e WatchUsersRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e WatchUsersRequestValidationError) ErrorName() string {
+	return "WatchUsersRequestValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e WatchUsersRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sWatchUsersRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = WatchUsersRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = WatchUsersRequestValidationError{}
+
+// Validate checks the field values on UserChange with the rules defined in the
+// proto definition for this message. If any rules are violated, the first
+// error encountered is returned, or nil if there are no violations.
+func (m *UserChange) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on UserChange with the rules defined in
+// the proto definition for this message. If any rules are violated, the
+// result is a list of violation errors wrapped in UserChangeMultiError, or
+// nil if none found.
+func (m *UserChange) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *UserChange) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Revision
+
+	// no validation rules for Type
+
+	if all {
+		switch v := interface{}(m.GetTime()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, UserChangeValidationError{
+					field:  "Time",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, UserChangeValidationError{
+					field:  "Time",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetTime()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return UserChangeValidationError{
+				field:  "Time",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	if all {
+		switch v := interface{}(m.GetUser()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, UserChangeValidationError{
+					field:  "User",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, UserChangeValidationError{
+					field:  "User",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetUser()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return UserChangeValidationError{
+				field:  "User",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	// no validation rules for Actor
+
+	if len(errors) > 0 {
+		return UserChangeMultiError(errors)
+	}
+	return nil
+}
+
+// UserChangeMultiError is an error wrapping multiple validation errors
+// returned by UserChange.ValidateAll() if the designated constraints aren't met.
+type UserChangeMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m UserChangeMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m UserChangeMultiError) AllErrors() []error { return m }
+
+// UserChangeValidationError is the validation error returned by
+// UserChange.Validate if the designated constraints aren't met.
+type UserChangeValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e UserChangeValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e UserChangeValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e UserChangeValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e UserChangeValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e UserChangeValidationError) ErrorName() string { return "UserChangeValidationError" }
+
+// Error satisfies the builtin error interface
+func (e UserChangeValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sUserChange.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = UserChangeValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = UserChangeValidationError{}
+
+// Validate checks the field values on Webhook with the rules defined in the
+// proto definition for this message. If any rules are violated, the first
+// error encountered is returned, or nil if there are no violations.
+func (m *Webhook) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on Webhook with the rules defined in the
+// proto definition for this message. If any rules are violated, the result is
+// a list of violation errors wrapped in WebhookMultiError, or nil if none found.
+func (m *Webhook) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *Webhook) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Id
+
+	// no validation rules for Url
+
+	// no validation rules for Secret
+
+	if all {
+		switch v := interface{}(m.GetCreateTime()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, WebhookValidationError{
+					field:  "CreateTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, WebhookValidationError{
+					field:  "CreateTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetCreateTime()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return WebhookValidationError{
+				field:  "CreateTime",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return WebhookMultiError(errors)
+	}
+	return nil
+}
+
+// WebhookMultiError is an error wrapping multiple validation errors returned
+// by Webhook.ValidateAll() if the designated constraints aren't met.
+type WebhookMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m WebhookMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m WebhookMultiError) AllErrors() []error { return m }
+
+// WebhookValidationError is the validation error returned by Webhook.Validate
+// if the designated constraints aren't met.
+type WebhookValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e WebhookValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e WebhookValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e WebhookValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e WebhookValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e WebhookValidationError) ErrorName() string { return "WebhookValidationError" }
+
+// Error satisfies the builtin error interface
+func (e WebhookValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sWebhook.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = WebhookValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = WebhookValidationError{}
+
+// Validate checks the field values on RegisterWebhookRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *RegisterWebhookRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on RegisterWebhookRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// RegisterWebhookRequestMultiError, or nil if none found.
+func (m *RegisterWebhookRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *RegisterWebhookRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	if all {
+		switch v := interface{}(m.GetWebhook()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, RegisterWebhookRequestValidationError{
+					field:  "Webhook",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, RegisterWebhookRequestValidationError{
+					field:  "Webhook",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetWebhook()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return RegisterWebhookRequestValidationError{
+				field:  "Webhook",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return RegisterWebhookRequestMultiError(errors)
+	}
+	return nil
+}
+
+// RegisterWebhookRequestMultiError is an error wrapping multiple validation
+// errors returned by RegisterWebhookRequest.ValidateAll() if the designated
+// constraints aren't met.
+type RegisterWebhookRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m RegisterWebhookRequestMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m RegisterWebhookRequestMultiError) AllErrors() []error { return m }
+
+// RegisterWebhookRequestValidationError is the validation error returned by
+// RegisterWebhookRequest.Validate if the designated constraints aren't met.
+type RegisterWebhookRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e RegisterWebhookRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e RegisterWebhookRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e RegisterWebhookRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e RegisterWebhookRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e RegisterWebhookRequestValidationError) ErrorName() string {
+	return "RegisterWebhookRequestValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e RegisterWebhookRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sRegisterWebhookRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = RegisterWebhookRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = RegisterWebhookRequestValidationError{}
+
+// Validate checks the field values on ListWebhooksRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *ListWebhooksRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on ListWebhooksRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// ListWebhooksRequestMultiError, or nil if none found.
+func (m *ListWebhooksRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *ListWebhooksRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	if len(errors) > 0 {
+		return ListWebhooksRequestMultiError(errors)
+	}
+	return nil
+}
+
+// ListWebhooksRequestMultiError is an error wrapping multiple validation
+// errors returned by ListWebhooksRequest.ValidateAll() if the designated
+// constraints aren't met.
+type ListWebhooksRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m ListWebhooksRequestMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m ListWebhooksRequestMultiError) AllErrors() []error { return m }
+
+// ListWebhooksRequestValidationError is the validation error returned by
+// ListWebhooksRequest.Validate if the designated constraints aren't met.
+type ListWebhooksRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e ListWebhooksRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e ListWebhooksRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e ListWebhooksRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e ListWebhooksRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e ListWebhooksRequestValidationError) ErrorName() string {
+	return "ListWebhooksRequestValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e ListWebhooksRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sListWebhooksRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = ListWebhooksRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = ListWebhooksRequestValidationError{}
+
+// Validate checks the field values on ListWebhooksResponse with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *ListWebhooksResponse) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on ListWebhooksResponse with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// ListWebhooksResponseMultiError, or nil if none found.
+func (m *ListWebhooksResponse) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *ListWebhooksResponse) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	for idx, item := range m.GetWebhooks() {
+		_, _ = idx, item
+
+		if all {
+			switch v := interface{}(item).(type) {
+			case interface{ ValidateAll() error }:
+				if err := v.ValidateAll(); err != nil {
+					errors = append(errors, ListWebhooksResponseValidationError{
+						field:  fmt.Sprintf("Webhooks[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			case interface{ Validate() error }:
+				if err := v.Validate(); err != nil {
+					errors = append(errors, ListWebhooksResponseValidationError{
+						field:  fmt.Sprintf("Webhooks[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			}
+		} else if v, ok := interface{}(item).(interface{ Validate() error }); ok {
+			if err := v.Validate(); err != nil {
+				return ListWebhooksResponseValidationError{
+					field:  fmt.Sprintf("Webhooks[%v]", idx),
+					reason: "embedded message failed validation",
+					cause:  err,
+				}
+			}
+		}
+
+	}
+
+	if len(errors) > 0 {
+		return ListWebhooksResponseMultiError(errors)
+	}
+	return nil
+}
+
+// ListWebhooksResponseMultiError is an error wrapping multiple validation
+// errors returned by ListWebhooksResponse.ValidateAll() if the designated
+// constraints aren't met.
+type ListWebhooksResponseMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m ListWebhooksResponseMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m ListWebhooksResponseMultiError) AllErrors() []error { return m }
+
+// ListWebhooksResponseValidationError is the validation error returned by
+// ListWebhooksResponse.Validate if the designated constraints aren't met.
+type ListWebhooksResponseValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e ListWebhooksResponseValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e ListWebhooksResponseValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e ListWebhooksResponseValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e ListWebhooksResponseValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e ListWebhooksResponseValidationError) ErrorName() string {
+	return "ListWebhooksResponseValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e ListWebhooksResponseValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sListWebhooksResponse.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = ListWebhooksResponseValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = ListWebhooksResponseValidationError{}
+
+// Validate checks the field values on DeleteWebhookRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *DeleteWebhookRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on DeleteWebhookRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// DeleteWebhookRequestMultiError, or nil if none found.
+func (m *DeleteWebhookRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *DeleteWebhookRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Id
+
+	if len(errors) > 0 {
+		return DeleteWebhookRequestMultiError(errors)
+	}
+	return nil
+}
+
+// DeleteWebhookRequestMultiError is an error wrapping multiple validation
+// errors returned by DeleteWebhookRequest.ValidateAll() if the designated
+// constraints aren't met.
+type DeleteWebhookRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m DeleteWebhookRequestMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m DeleteWebhookRequestMultiError) AllErrors() []error { return m }
+
+// DeleteWebhookRequestValidationError is the validation error returned by
+// DeleteWebhookRequest.Validate if the designated constraints aren't met.
+type DeleteWebhookRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e DeleteWebhookRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e DeleteWebhookRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e DeleteWebhookRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e DeleteWebhookRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e DeleteWebhookRequestValidationError) ErrorName() string {
+	return "DeleteWebhookRequestValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e DeleteWebhookRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sDeleteWebhookRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = DeleteWebhookRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = DeleteWebhookRequestValidationError{}
+
+// Validate checks the field values on ListDeadLettersRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *ListDeadLettersRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on ListDeadLettersRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// ListDeadLettersRequestMultiError, or nil if none found.
+func (m *ListDeadLettersRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *ListDeadLettersRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for WebhookId
+
+	if len(errors) > 0 {
+		return ListDeadLettersRequestMultiError(errors)
+	}
+	return nil
+}
+
+// ListDeadLettersRequestMultiError is an error wrapping multiple validation
+// errors returned by ListDeadLettersRequest.ValidateAll() if the designated
+// constraints aren't met.
+type ListDeadLettersRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m ListDeadLettersRequestMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m ListDeadLettersRequestMultiError) AllErrors() []error { return m }
+
+// ListDeadLettersRequestValidationError is the validation error returned by
+// ListDeadLettersRequest.Validate if the designated constraints aren't met.
+type ListDeadLettersRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e ListDeadLettersRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e ListDeadLettersRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e ListDeadLettersRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e ListDeadLettersRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e ListDeadLettersRequestValidationError) ErrorName() string {
+	return "ListDeadLettersRequestValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e ListDeadLettersRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sListDeadLettersRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = ListDeadLettersRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = ListDeadLettersRequestValidationError{}
+
+// Validate checks the field values on DeadLetter with the rules defined in the
+// proto definition for this message. If any rules are violated, the first
+// error encountered is returned, or nil if there are no violations.
+func (m *DeadLetter) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on DeadLetter with the rules defined in
+// the proto definition for this message. If any rules are violated, the
+// result is a list of violation errors wrapped in DeadLetterMultiError, or
+// nil if none found.
+func (m *DeadLetter) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *DeadLetter) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for DeliveryId
+
+	// no validation rules for WebhookId
+
+	// no validation rules for Url
+
+	if all {
+		switch v := interface{}(m.GetChange()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, DeadLetterValidationError{
+					field:  "Change",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, DeadLetterValidationError{
+					field:  "Change",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetChange()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return DeadLetterValidationError{
+				field:  "Change",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	// no validation rules for Attempts
+
+	// no validation rules for LastError
+
+	if all {
+		switch v := interface{}(m.GetFailTime()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, DeadLetterValidationError{
+					field:  "FailTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, DeadLetterValidationError{
+					field:  "FailTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetFailTime()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return DeadLetterValidationError{
+				field:  "FailTime",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return DeadLetterMultiError(errors)
+	}
+	return nil
+}
+
+// DeadLetterMultiError is an error wrapping multiple validation errors
+// returned by DeadLetter.ValidateAll() if the designated constraints aren't met.
+type DeadLetterMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m DeadLetterMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m DeadLetterMultiError) AllErrors() []error { return m }
+
+// DeadLetterValidationError is the validation error returned by
+// DeadLetter.Validate if the designated constraints aren't met.
+type DeadLetterValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e DeadLetterValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e DeadLetterValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e DeadLetterValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e DeadLetterValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e DeadLetterValidationError) ErrorName() string { return "DeadLetterValidationError" }
+
+// Error satisfies the builtin error interface
+func (e DeadLetterValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sDeadLetter.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = DeadLetterValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = DeadLetterValidationError{}
+
+// Validate checks the field values on ListDeadLettersResponse with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *ListDeadLettersResponse) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on ListDeadLettersResponse with the
+// rules defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// ListDeadLettersResponseMultiError, or nil if none found.
+func (m *ListDeadLettersResponse) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *ListDeadLettersResponse) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	for idx, item := range m.GetDeadLetters() {
+		_, _ = idx, item
+
+		if all {
+			switch v := interface{}(item).(type) {
+			case interface{ ValidateAll() error }:
+				if err := v.ValidateAll(); err != nil {
+					errors = append(errors, ListDeadLettersResponseValidationError{
+						field:  fmt.Sprintf("DeadLetters[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			case interface{ Validate() error }:
+				if err := v.Validate(); err != nil {
+					errors = append(errors, ListDeadLettersResponseValidationError{
+						field:  fmt.Sprintf("DeadLetters[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			}
+		} else if v, ok := interface{}(item).(interface{ Validate() error }); ok {
+			if err := v.Validate(); err != nil {
+				return ListDeadLettersResponseValidationError{
+					field:  fmt.Sprintf("DeadLetters[%v]", idx),
+					reason: "embedded message failed validation",
+					cause:  err,
+				}
+			}
+		}
+
+	}
+
+	if len(errors) > 0 {
+		return ListDeadLettersResponseMultiError(errors)
+	}
+	return nil
+}
+
+// ListDeadLettersResponseMultiError is an error wrapping multiple validation
+// errors returned by ListDeadLettersResponse.ValidateAll() if the designated
+// constraints aren't met.
+type ListDeadLettersResponseMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m ListDeadLettersResponseMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m ListDeadLettersResponseMultiError) AllErrors() []error { return m }
+
+// ListDeadLettersResponseValidationError is the validation error returned by
+// ListDeadLettersResponse.Validate if the designated constraints aren't met.
+type ListDeadLettersResponseValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e ListDeadLettersResponseValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e ListDeadLettersResponseValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e ListDeadLettersResponseValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e ListDeadLettersResponseValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e ListDeadLettersResponseValidationError) ErrorName() string {
+	return "ListDeadLettersResponseValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e ListDeadLettersResponseValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sListDeadLettersResponse.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = ListDeadLettersResponseValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = ListDeadLettersResponseValidationError{}
+
+// Validate checks the field values on ApiKey with the rules defined in the
+// proto definition for this message. If any rules are violated, the first
+// error encountered is returned, or nil if there are no violations.
+func (m *ApiKey) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on ApiKey with the rules defined in the
+// proto definition for this message. If any rules are violated, the result is
+// a list of violation errors wrapped in ApiKeyMultiError, or nil if none found.
+func (m *ApiKey) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *ApiKey) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Id
+
+	// no validation rules for Name
+
+	if all {
+		switch v := interface{}(m.GetCreateTime()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, ApiKeyValidationError{
+					field:  "CreateTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, ApiKeyValidationError{
+					field:  "CreateTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetCreateTime()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return ApiKeyValidationError{
+				field:  "CreateTime",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	if all {
+		switch v := interface{}(m.GetRevokeTime()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, ApiKeyValidationError{
+					field:  "RevokeTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, ApiKeyValidationError{
+					field:  "RevokeTime",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetRevokeTime()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return ApiKeyValidationError{
+				field:  "RevokeTime",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return ApiKeyMultiError(errors)
+	}
+	return nil
+}
+
+// ApiKeyMultiError is an error wrapping multiple validation errors returned by
+// ApiKey.ValidateAll() if the designated constraints aren't met.
+type ApiKeyMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m ApiKeyMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m ApiKeyMultiError) AllErrors() []error { return m }
+
+// ApiKeyValidationError is the validation error returned by ApiKey.Validate if
+// the designated constraints aren't met.
+type ApiKeyValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e ApiKeyValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e ApiKeyValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e ApiKeyValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e ApiKeyValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e ApiKeyValidationError) ErrorName() string { return "ApiKeyValidationError" }
+
+// Error satisfies the builtin error interface
+func (e ApiKeyValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sApiKey.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = ApiKeyValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = ApiKeyValidationError{}
+
+// Validate checks the field values on CreateApiKeyRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *CreateApiKeyRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on CreateApiKeyRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// CreateApiKeyRequestMultiError, or nil if none found.
+func (m *CreateApiKeyRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *CreateApiKeyRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Name
+
+	if len(errors) > 0 {
+		return CreateApiKeyRequestMultiError(errors)
+	}
+	return nil
+}
+
+// CreateApiKeyRequestMultiError is an error wrapping multiple validation
+// errors returned by CreateApiKeyRequest.ValidateAll() if the designated
+// constraints aren't met.
+type CreateApiKeyRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m CreateApiKeyRequestMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m CreateApiKeyRequestMultiError) AllErrors() []error { return m }
+
+// CreateApiKeyRequestValidationError is the validation error returned by
+// CreateApiKeyRequest.Validate if the designated constraints aren't met.
+type CreateApiKeyRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e CreateApiKeyRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e CreateApiKeyRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e CreateApiKeyRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e CreateApiKeyRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e CreateApiKeyRequestValidationError) ErrorName() string {
+	return "CreateApiKeyRequestValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e CreateApiKeyRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sCreateApiKeyRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = CreateApiKeyRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = CreateApiKeyRequestValidationError{}
+
+// Validate checks the field values on CreateApiKeyResponse with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *CreateApiKeyResponse) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on CreateApiKeyResponse with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// CreateApiKeyResponseMultiError, or nil if none found.
+func (m *CreateApiKeyResponse) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *CreateApiKeyResponse) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	if all {
+		switch v := interface{}(m.GetApiKey()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, CreateApiKeyResponseValidationError{
+					field:  "ApiKey",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, CreateApiKeyResponseValidationError{
+					field:  "ApiKey",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetApiKey()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return CreateApiKeyResponseValidationError{
+				field:  "ApiKey",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	// no validation rules for Key
+
+	if len(errors) > 0 {
+		return CreateApiKeyResponseMultiError(errors)
+	}
+	return nil
+}
+
+// CreateApiKeyResponseMultiError is an error wrapping multiple validation
+// errors returned by CreateApiKeyResponse.ValidateAll() if the designated
+// constraints aren't met.
+type CreateApiKeyResponseMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m CreateApiKeyResponseMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m CreateApiKeyResponseMultiError) AllErrors() []error { return m }
+
+// CreateApiKeyResponseValidationError is the validation error returned by
+// CreateApiKeyResponse.Validate if the designated constraints aren't met.
+type CreateApiKeyResponseValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e CreateApiKeyResponseValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e CreateApiKeyResponseValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e CreateApiKeyResponseValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e CreateApiKeyResponseValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e CreateApiKeyResponseValidationError) ErrorName() string {
+	return "CreateApiKeyResponseValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e CreateApiKeyResponseValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sCreateApiKeyResponse.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = CreateApiKeyResponseValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = CreateApiKeyResponseValidationError{}
+
+// Validate checks the field values on RevokeApiKeyRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *RevokeApiKeyRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on RevokeApiKeyRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// RevokeApiKeyRequestMultiError, or nil if none found.
+func (m *RevokeApiKeyRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *RevokeApiKeyRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Id
+
+	if len(errors) > 0 {
+		return RevokeApiKeyRequestMultiError(errors)
+	}
+	return nil
+}
+
+// RevokeApiKeyRequestMultiError is an error wrapping multiple validation
+// errors returned by RevokeApiKeyRequest.ValidateAll() if the designated
+// constraints aren't met.
+type RevokeApiKeyRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m RevokeApiKeyRequestMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m RevokeApiKeyRequestMultiError) AllErrors() []error { return m }
+
+// RevokeApiKeyRequestValidationError is the validation error returned by
+// RevokeApiKeyRequest.Validate if the designated constraints aren't met.
+type RevokeApiKeyRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e RevokeApiKeyRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e RevokeApiKeyRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e RevokeApiKeyRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e RevokeApiKeyRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e RevokeApiKeyRequestValidationError) ErrorName() string {
+	return "RevokeApiKeyRequestValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e RevokeApiKeyRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sRevokeApiKeyRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = RevokeApiKeyRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = RevokeApiKeyRequestValidationError{}
+
+// Validate checks the field values on ListApiKeysRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *ListApiKeysRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on ListApiKeysRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// ListApiKeysRequestMultiError, or nil if none found.
+func (m *ListApiKeysRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *ListApiKeysRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	if len(errors) > 0 {
+		return ListApiKeysRequestMultiError(errors)
+	}
+	return nil
+}
+
+// ListApiKeysRequestMultiError is an error wrapping multiple validation errors
+// returned by ListApiKeysRequest.ValidateAll() if the designated constraints
+// aren't met.
+type ListApiKeysRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m ListApiKeysRequestMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m ListApiKeysRequestMultiError) AllErrors() []error { return m }
+
+// ListApiKeysRequestValidationError is the validation error returned by
+// ListApiKeysRequest.Validate if the designated constraints aren't met.
+type ListApiKeysRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e ListApiKeysRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e ListApiKeysRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e ListApiKeysRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e ListApiKeysRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e ListApiKeysRequestValidationError) ErrorName() string {
+	return "ListApiKeysRequestValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e ListApiKeysRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sListApiKeysRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = ListApiKeysRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = ListApiKeysRequestValidationError{}
+
+// Validate checks the field values on ListApiKeysResponse with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *ListApiKeysResponse) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on ListApiKeysResponse with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// ListApiKeysResponseMultiError, or nil if none found.
+func (m *ListApiKeysResponse) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *ListApiKeysResponse) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	for idx, item := range m.GetApiKeys() {
+		_, _ = idx, item
+
+		if all {
+			switch v := interface{}(item).(type) {
+			case interface{ ValidateAll() error }:
+				if err := v.ValidateAll(); err != nil {
+					errors = append(errors, ListApiKeysResponseValidationError{
+						field:  fmt.Sprintf("ApiKeys[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			case interface{ Validate() error }:
+				if err := v.Validate(); err != nil {
+					errors = append(errors, ListApiKeysResponseValidationError{
+						field:  fmt.Sprintf("ApiKeys[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			}
+		} else if v, ok := interface{}(item).(interface{ Validate() error }); ok {
+			if err := v.Validate(); err != nil {
+				return ListApiKeysResponseValidationError{
+					field:  fmt.Sprintf("ApiKeys[%v]", idx),
+					reason: "embedded message failed validation",
+					cause:  err,
+				}
+			}
+		}
+
+	}
+
+	if len(errors) > 0 {
+		return ListApiKeysResponseMultiError(errors)
+	}
+	return nil
+}
+
+// ListApiKeysResponseMultiError is an error wrapping multiple validation
+// errors returned by ListApiKeysResponse.ValidateAll() if the designated
+// constraints aren't met.
+type ListApiKeysResponseMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m ListApiKeysResponseMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m ListApiKeysResponseMultiError) AllErrors() []error { return m }
+
+// ListApiKeysResponseValidationError is the validation error returned by
+// ListApiKeysResponse.Validate if the designated constraints aren't met.
+type ListApiKeysResponseValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e ListApiKeysResponseValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e ListApiKeysResponseValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e ListApiKeysResponseValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e ListApiKeysResponseValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e ListApiKeysResponseValidationError) ErrorName() string {
+	return "ListApiKeysResponseValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e ListApiKeysResponseValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sListApiKeysResponse.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = ListApiKeysResponseValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = ListApiKeysResponseValidationError{}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/casmelad/bootcamp-gateway/server/auth"
+	"github.com/casmelad/bootcamp-gateway/server/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	//RateLimitLimitHeader is the metadata key carrying the size of the bucket of the caller
+	RateLimitLimitHeader = "x-ratelimit-limit"
+	//RateLimitRemainingHeader is the metadata key carrying the calls the caller can make right away
+	RateLimitRemainingHeader = "x-ratelimit-remaining"
+	//RetryAfterHeader is the metadata key carrying the seconds to wait when the call was rejected
+	RetryAfterHeader = "retry-after"
+
+	forwardedForHeader = "x-forwarded-for"
+)
+
+//UnaryRateLimitInterceptor rejects with codes.ResourceExhausted the calls of a caller past the limit of the method,
+//the callers are told the quota left in the response metadata. The callers are limited by identity, the one of
+//their unverified credentials when it runs before UnaryAuthInterceptor, so the ones failing authentication are
+//limited too, and by address when they send no credentials
+func UnaryRateLimitInterceptor(limiter *ratelimit.Limiter, config ratelimit.Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		if err := takeToken(ctx, limiter, config, info.FullMethod, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) }); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+//StreamRateLimitInterceptor is the streaming counterpart of UnaryRateLimitInterceptor, opening a stream takes a token
+func StreamRateLimitInterceptor(limiter *ratelimit.Limiter, config ratelimit.Config) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		if err := takeToken(ss.Context(), limiter, config, info.FullMethod, ss.SetHeader); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+//takeToken takes a token from the bucket of the caller for the method and sends the quota with setHeader
+func takeToken(ctx context.Context, limiter *ratelimit.Limiter, config ratelimit.Config, method string, setHeader func(metadata.MD) error) error {
+
+	limit, ok := config.For(method)
+
+	if !ok {
+		return nil
+	}
+
+	decision := limiter.Allow(method+"|"+callerKey(ctx, config), limit)
+
+	md := metadata.Pairs(
+		RateLimitLimitHeader, strconv.Itoa(decision.Limit),
+		RateLimitRemainingHeader, strconv.Itoa(decision.Remaining),
+	)
+
+	if !decision.Allowed {
+		md.Set(RetryAfterHeader, ratelimit.RetryAfterSeconds(decision.RetryAfter))
+	}
+
+	if err := setHeader(md); err != nil {
+		return err
+	}
+
+	if !decision.Allowed {
+		return status.Errorf(codes.ResourceExhausted, "Too many calls to %s, retry in %s seconds", method, ratelimit.RetryAfterSeconds(decision.RetryAfter))
+	}
+
+	return nil
+}
+
+//callerKey identifies the caller by the identity of its credentials, authenticated or not, by its address otherwise,
+//the address a proxy forwards is only trusted when the call comes from one of the trusted proxies of the config
+func callerKey(ctx context.Context, config ratelimit.Config) string {
+
+	if claims, ok := auth.ClaimsFromContext(ctx); ok {
+		return "id:" + claims.Identity()
+	}
+
+	if key, ok := apiKey(ctx); ok {
+		return "key:" + auth.HashAPIKey(key)
+	}
+
+	if token, ok := bearerToken(ctx); ok {
+		if claims, err := auth.UnverifiedClaims(token); err == nil && claims.Subject != "" {
+			return "sub:" + claims.Subject
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+
+	if !ok {
+		return "ip:unknown"
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+
+	if err != nil {
+		host = p.Addr.String()
+	}
+
+	if ip := net.ParseIP(host); ip != nil && config.TrustsProxy(ip) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get(forwardedForHeader); len(values) > 0 {
+			//the gateway appends the address of its peer last
+			forwarded := strings.Split(values[len(values)-1], ",")
+			return "ip:" + strings.TrimSpace(forwarded[len(forwarded)-1])
+		}
+	}
+
+	return "ip:" + host
+}
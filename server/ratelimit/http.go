@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	//LimitHeader carries the size of the bucket of the caller
+	LimitHeader = "X-RateLimit-Limit"
+	//RemainingHeader carries the calls the caller can make right away
+	RemainingHeader = "X-RateLimit-Remaining"
+	//RetryAfterHeader carries the seconds to wait before calling again when the call was rejected
+	RetryAfterHeader = "Retry-After"
+)
+
+//Middleware limits the requests of every client IP, the rejected ones are answered with 429 Too Many Requests
+//and the quota of the client, the quota of the allowed ones is left to the handler that reports the limits
+//of the methods
+func Middleware(limiter *Limiter, limit Limit, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		decision := limiter.Allow("http|"+clientIP(r), limit)
+
+		if !decision.Allowed {
+			w.Header().Set(LimitHeader, strconv.Itoa(decision.Limit))
+			w.Header().Set(RemainingHeader, strconv.Itoa(decision.Remaining))
+			w.Header().Set(RetryAfterHeader, RetryAfterSeconds(decision.RetryAfter))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+//RetryAfterSeconds - the wait rounded up to whole seconds, as the Retry-After header expects
+func RetryAfterSeconds(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+//clientIP returns the address of the peer, the forwarding headers are not trusted
+func clientIP(r *http.Request) string {
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
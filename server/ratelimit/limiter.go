@@ -0,0 +1,198 @@
+//Package ratelimit limits how often every caller may call the services with token buckets
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+//idleAfter is how long an unused bucket is kept, it is usually full again by then and a new bucket starts full
+const idleAfter = 10 * time.Minute
+
+//Limit - a bucket of Burst tokens refilled at Rate tokens per second, every call takes a token
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+//Config - the limits of the methods keyed by full method name (/users.Users/Create), the methods without
+//a limit follow Default and are not limited when there is none, HTTP limits every request to the gateway.
+//The address a caller forwards is only trusted when the caller is in one of the TrustedProxies CIDRs
+type Config struct {
+	Methods        map[string]Limit `json:"methods"`
+	Default        *Limit           `json:"default"`
+	HTTP           *Limit           `json:"http"`
+	TrustedProxies []string         `json:"trusted_proxies"`
+}
+
+//DefaultConfig - the limits used when no configuration is given, the writes and the stream of every user
+//are limited the most
+func DefaultConfig() Config {
+	return Config{
+		Methods: map[string]Limit{
+			"/users.Users/Create":      {Rate: 5, Burst: 10},
+			"/users.Users/GetAllUsers": {Rate: 1, Burst: 5},
+		},
+		Default: &Limit{Rate: 50, Burst: 100},
+		HTTP:    &Limit{Rate: 100, Burst: 200},
+	}
+}
+
+//LoadConfig - reads a JSON configuration file
+func LoadConfig(path string) (Config, error) {
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return Config{}, err
+	}
+
+	var c Config
+
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Config{}, err
+	}
+
+	for method, limit := range c.Methods {
+		if !strings.HasPrefix(method, "/") {
+			return Config{}, fmt.Errorf("rate limit method %q is not /<service>/<method>", method)
+		}
+		if err := limit.validate(); err != nil {
+			return Config{}, fmt.Errorf("rate limit of %s: %v", method, err)
+		}
+	}
+
+	for _, limit := range []*Limit{c.Default, c.HTTP} {
+		if limit == nil {
+			continue
+		}
+		if err := limit.validate(); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, cidr := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return Config{}, fmt.Errorf("trusted proxy %q is not a CIDR", cidr)
+		}
+	}
+
+	return c, nil
+}
+
+//TrustsProxy - whether the address a caller from ip forwards is trusted, only the callers in the TrustedProxies
+//CIDRs are trusted
+func (c Config) TrustsProxy(ip net.IP) bool {
+
+	for _, cidr := range c.TrustedProxies {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//For - the limit of the method, false when it is not limited
+func (c Config) For(method string) (Limit, bool) {
+
+	if limit, ok := c.Methods[method]; ok {
+		return limit, true
+	}
+
+	if c.Default != nil {
+		return *c.Default, true
+	}
+
+	return Limit{}, false
+}
+
+func (l Limit) validate() error {
+
+	if l.Rate <= 0 || l.Burst < 1 {
+		return fmt.Errorf("rate must be positive and burst at least 1, got rate %v burst %d", l.Rate, l.Burst)
+	}
+
+	return nil
+}
+
+//Decision - the outcome of taking a token
+type Decision struct {
+	Allowed bool
+	//Limit - the size of the bucket
+	Limit int
+	//Remaining - the whole tokens left after the call
+	Remaining int
+	//RetryAfter - how long until a token is available when the call was not allowed
+	RetryAfter time.Duration
+}
+
+//Limiter keeps a bucket for every key, it is safe for concurrent use
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+//NewLimiter returns a Limiter type pointer without buckets
+func NewLimiter() *Limiter {
+	return &Limiter{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+//Allow - takes a token from the bucket of the key, the bucket starts full
+func (l *Limiter) Allow(key string, limit Limit) Decision {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	burst := float64(limit.Burst)
+	b, ok := l.buckets[key]
+
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+		return Decision{Limit: limit.Burst, RetryAfter: wait}
+	}
+
+	b.tokens--
+
+	return Decision{Allowed: true, Limit: limit.Burst, Remaining: int(b.tokens)}
+}
+
+//sweep drops the buckets not used for a while once a minute, the caller must hold the lock
+func (l *Limiter) sweep(now time.Time) {
+
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idleAfter {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//clock is a manual time source for the limiter
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newTestLimiter() (*Limiter, *clock) {
+	c := &clock{now: time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLimiter()
+	l.now = c.Now
+	return l, c
+}
+
+func Test_Limiter_Allow_UpToTheBurst(t *testing.T) {
+	//Arrange
+	limiter, _ := newTestLimiter()
+	limit := Limit{Rate: 1, Burst: 3}
+	//Act
+	first := limiter.Allow("a", limit)
+	limiter.Allow("a", limit)
+	third := limiter.Allow("a", limit)
+	fourth := limiter.Allow("a", limit)
+	other := limiter.Allow("b", limit)
+	//Assert
+	assert.Equal(t, Decision{Allowed: true, Limit: 3, Remaining: 2}, first)
+	assert.Equal(t, Decision{Allowed: true, Limit: 3, Remaining: 0}, third)
+	assert.Equal(t, Decision{Allowed: false, Limit: 3, RetryAfter: time.Second}, fourth)
+	assert.True(t, other.Allowed)
+}
+
+func Test_Limiter_Allow_RefillsAtTheRate(t *testing.T) {
+	//Arrange
+	limiter, clock := newTestLimiter()
+	limit := Limit{Rate: 2, Burst: 2}
+	limiter.Allow("a", limit)
+	limiter.Allow("a", limit)
+	//Act
+	clock.now = clock.now.Add(250 * time.Millisecond)
+	early := limiter.Allow("a", limit)
+	clock.now = clock.now.Add(250 * time.Millisecond)
+	refilled := limiter.Allow("a", limit)
+	clock.now = clock.now.Add(time.Hour)
+	full := limiter.Allow("a", limit)
+	//Assert
+	assert.False(t, early.Allowed)
+	assert.Equal(t, 250*time.Millisecond, early.RetryAfter)
+	assert.True(t, refilled.Allowed)
+	assert.Equal(t, 1, full.Remaining)
+}
+
+func Test_Limiter_Sweep_DropsIdleBuckets(t *testing.T) {
+	//Arrange
+	limiter, clock := newTestLimiter()
+	limiter.Allow("a", Limit{Rate: 1, Burst: 1})
+	//Act
+	clock.now = clock.now.Add(idleAfter + time.Minute)
+	limiter.Allow("b", Limit{Rate: 1, Burst: 1})
+	//Assert
+	assert.Len(t, limiter.buckets, 1)
+	assert.Contains(t, limiter.buckets, "b")
+}
+
+func Test_LoadConfig(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	invalid := filepath.Join(dir, "invalid.json")
+	invalidProxy := filepath.Join(dir, "invalid-proxy.json")
+	require.NoError(t, ioutil.WriteFile(valid, []byte(`{"methods":{"/users.Users/Create":{"rate":0.5,"burst":2}},"http":{"rate":10,"burst":20},"trusted_proxies":["10.0.0.0/8"]}`), 0644))
+	require.NoError(t, ioutil.WriteFile(invalid, []byte(`{"methods":{"/users.Users/Create":{"rate":0,"burst":2}}}`), 0644))
+	require.NoError(t, ioutil.WriteFile(invalidProxy, []byte(`{"trusted_proxies":["10.0.0.1"]}`), 0644))
+	//Act
+	config, err := LoadConfig(valid)
+	_, invalidErr := LoadConfig(invalid)
+	_, invalidProxyErr := LoadConfig(invalidProxy)
+	//Assert
+	require.NoError(t, err)
+	create, limited := config.For("/users.Users/Create")
+	_, otherLimited := config.For("/users.Users/GetUser")
+	assert.True(t, limited)
+	assert.Equal(t, Limit{Rate: 0.5, Burst: 2}, create)
+	assert.False(t, otherLimited)
+	assert.Equal(t, &Limit{Rate: 10, Burst: 20}, config.HTTP)
+	assert.True(t, config.TrustsProxy(net.ParseIP("10.1.2.3")))
+	assert.False(t, config.TrustsProxy(net.ParseIP("127.0.0.1")))
+	assert.False(t, DefaultConfig().TrustsProxy(net.ParseIP("127.0.0.1")))
+	assert.NotNil(t, invalidErr)
+	assert.NotNil(t, invalidProxyErr)
+}
+
+func Test_DefaultConfig_For(t *testing.T) {
+	//Act
+	create, _ := DefaultConfig().For("/users.Users/Create")
+	other, limited := DefaultConfig().For("/users.Users/GetUser")
+	//Assert
+	assert.Equal(t, Limit{Rate: 5, Burst: 10}, create)
+	assert.True(t, limited)
+	assert.Equal(t, *DefaultConfig().Default, other)
+}
+
+func Test_Middleware_RejectsWithTooManyRequests(t *testing.T) {
+	//Arrange
+	limiter, _ := newTestLimiter()
+	handler := Middleware(limiter, Limit{Rate: 0.5, Burst: 1}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	//Act
+	first := request("10.0.0.1:5000")
+	second := request("10.0.0.1:5001")
+	other := request("10.0.0.2:5000")
+	//Assert
+	assert.Equal(t, http.StatusNoContent, first.Code)
+	assert.Empty(t, first.Header().Get(LimitHeader))
+	assert.Equal(t, http.StatusTooManyRequests, second.Code)
+	assert.Equal(t, "1", second.Header().Get(LimitHeader))
+	assert.Equal(t, "0", second.Header().Get(RemainingHeader))
+	assert.Equal(t, "2", second.Header().Get(RetryAfterHeader))
+	assert.Equal(t, http.StatusNoContent, other.Code)
+}
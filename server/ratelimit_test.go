@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/casmelad/bootcamp-gateway/server/auth"
+	pb "github.com/casmelad/bootcamp-gateway/server/proto"
+	"github.com/casmelad/bootcamp-gateway/server/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+//testLimits allows two calls to GetUser and does not limit the rest
+func testLimits() ratelimit.Config {
+	return ratelimit.Config{Methods: map[string]ratelimit.Limit{"/users.Users/GetUser": {Rate: 1, Burst: 2}}}
+}
+
+func Test_UnaryRateLimitInterceptor_PastTheBurst_ReturnsResourceExhausted(t *testing.T) {
+	//Arrange
+	conn := newTestConn(t, newPopulatedTestService(t),
+		grpc.UnaryInterceptor(UnaryRateLimitInterceptor(ratelimit.NewLimiter(), testLimits())))
+	client := pb.NewUsersClient(conn)
+	req := &pb.GetUserRequest{Email: "test@gmail.com"}
+	var first, second, rejected metadata.MD
+	//Act
+	_, err := client.GetUser(context.Background(), req, grpc.Header(&first))
+	require.NoError(t, err)
+	_, err = client.GetUser(context.Background(), req, grpc.Header(&second))
+	require.NoError(t, err)
+	_, errRejected := client.GetUser(context.Background(), req, grpc.Header(&rejected))
+	_, errNotLimited := client.ListUsers(context.Background(), &pb.GetAllUsersRequest{})
+	//Assert
+	assert.Equal(t, []string{"2"}, first.Get(RateLimitLimitHeader))
+	assert.Equal(t, []string{"1"}, first.Get(RateLimitRemainingHeader))
+	assert.Empty(t, first.Get(RetryAfterHeader))
+	assert.Equal(t, []string{"0"}, second.Get(RateLimitRemainingHeader))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(errRejected))
+	assert.Equal(t, []string{"2"}, rejected.Get(RateLimitLimitHeader))
+	assert.Equal(t, []string{"0"}, rejected.Get(RateLimitRemainingHeader))
+	assert.Equal(t, []string{"1"}, rejected.Get(RetryAfterHeader))
+	assert.NoError(t, errNotLimited)
+}
+
+func Test_Gateway_RateLimited_ReturnsTooManyRequests(t *testing.T) {
+	//Arrange
+	gateway := newTestGateway(t, newPopulatedTestService(t),
+		grpc.UnaryInterceptor(UnaryRateLimitInterceptor(ratelimit.NewLimiter(), testLimits())))
+	//Act
+	first := doRequest(t, gateway, http.MethodGet, "/api/v1/users/test@gmail.com", "")
+	doRequest(t, gateway, http.MethodGet, "/api/v1/users/test@gmail.com", "")
+	rejected := doRequest(t, gateway, http.MethodGet, "/api/v1/users/test@gmail.com", "")
+	//Assert
+	assert.Equal(t, http.StatusOK, first.StatusCode)
+	assert.Equal(t, "2", first.Header.Get(ratelimit.LimitHeader))
+	assert.Equal(t, "1", first.Header.Get(ratelimit.RemainingHeader))
+	assert.Equal(t, http.StatusTooManyRequests, rejected.StatusCode)
+	assert.Equal(t, "0", rejected.Header.Get(ratelimit.RemainingHeader))
+	assert.Equal(t, "1", rejected.Header.Get(ratelimit.RetryAfterHeader))
+}
+
+func Test_StreamRateLimitInterceptor_PastTheBurst_ReturnsResourceExhausted(t *testing.T) {
+	//Arrange
+	limits := ratelimit.Config{Methods: map[string]ratelimit.Limit{"/users.Users/GetAllUsers": {Rate: 1, Burst: 1}}}
+	conn := newTestConn(t, newPopulatedTestService(t),
+		grpc.StreamInterceptor(StreamRateLimitInterceptor(ratelimit.NewLimiter(), limits)))
+	client := pb.NewUsersClient(conn)
+	//Act
+	allowed, err := client.GetAllUsers(context.Background(), &pb.GetAllUsersRequest{})
+	require.NoError(t, err)
+	_, errAllowed := allowed.Recv()
+	allowedHeader, _ := allowed.Header()
+	rejected, err := client.GetAllUsers(context.Background(), &pb.GetAllUsersRequest{})
+	require.NoError(t, err)
+	_, errRejected := rejected.Recv()
+	rejectedHeader, _ := rejected.Header()
+	//Assert
+	assert.NoError(t, errAllowed)
+	assert.Equal(t, []string{"0"}, allowedHeader.Get(RateLimitRemainingHeader))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(errRejected))
+	assert.Equal(t, []string{"1"}, rejectedHeader.Get(RetryAfterHeader))
+}
+
+func Test_CallerKey_IdentityOrAddress(t *testing.T) {
+	//Arrange
+	loopback := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000}})
+	remote := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.7"), Port: 5000}})
+	forwarded := metadata.Pairs(forwardedForHeader, "198.51.100.1, 203.0.113.9")
+	trusted := ratelimit.Config{TrustedProxies: []string{"127.0.0.1/32", "::1/128"}}
+	token := "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"42"}`)) + ".c2ln"
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		config ratelimit.Config
+		key    string
+	}{
+		{"authenticated", auth.WithClaims(remote, auth.Claims{Subject: "42", Email: "test@gmail.com"}), ratelimit.Config{}, "id:test@gmail.com"},
+		{"api key", metadata.NewIncomingContext(remote, metadata.Pairs(APIKeyHeader, "k3y")), ratelimit.Config{}, "key:" + auth.HashAPIKey("k3y")},
+		{"unverified token", metadata.NewIncomingContext(remote, metadata.Pairs(AuthorizationHeader, "Bearer "+token)), ratelimit.Config{}, "sub:42"},
+		{"malformed token", metadata.NewIncomingContext(remote, metadata.Pairs(AuthorizationHeader, "Bearer garbage")), ratelimit.Config{}, "ip:10.0.0.7"},
+		{"address", remote, ratelimit.Config{}, "ip:10.0.0.7"},
+		{"forwarded by an untrusted peer", metadata.NewIncomingContext(remote, forwarded), trusted, "ip:10.0.0.7"},
+		{"forwarded without trusted proxies", metadata.NewIncomingContext(loopback, forwarded), ratelimit.Config{}, "ip:127.0.0.1"},
+		{"forwarded by a trusted proxy", metadata.NewIncomingContext(loopback, forwarded), trusted, "ip:203.0.113.9"},
+		{"unknown", context.Background(), ratelimit.Config{}, "ip:unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//Act
+			key := callerKey(tt.ctx, tt.config)
+			//Assert
+			assert.Equal(t, tt.key, key)
+		})
+	}
+}
+
+func Test_UnaryRateLimitInterceptor_BeforeAuthentication_LimitsEveryAPIKey(t *testing.T) {
+	//Arrange
+	conn := newTestConn(t, newPopulatedTestService(t),
+		grpc.UnaryInterceptor(UnaryRateLimitInterceptor(ratelimit.NewLimiter(), testLimits())))
+	client := pb.NewUsersClient(conn)
+	req := &pb.GetUserRequest{Email: "test@gmail.com"}
+	first := metadata.AppendToOutgoingContext(context.Background(), APIKeyHeader, "first")
+	second := metadata.AppendToOutgoingContext(context.Background(), APIKeyHeader, "second")
+	//Act
+	client.GetUser(first, req)
+	client.GetUser(first, req)
+	_, errFirst := client.GetUser(first, req)
+	_, errSecond := client.GetUser(second, req)
+	//Assert
+	assert.Equal(t, codes.ResourceExhausted, status.Code(errFirst))
+	assert.NoError(t, errSecond)
+}
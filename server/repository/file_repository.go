@@ -0,0 +1,582 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+)
+
+const (
+	walFileName      = "users.wal"
+	snapshotFileName = "users.snapshot"
+
+	//DefaultCompactEvery is the number of logged writes after which the log is compacted into a snapshot
+	DefaultCompactEvery = 1000
+)
+
+const (
+	opPut       = "put"
+	opDelete    = "delete"
+	opEvents    = "events"
+	opPublished = "published"
+	//opBatch groups the records of a transaction in a single line so they are replayed all or none
+	opBatch = "batch"
+)
+
+//walRecord is a single entry of the write-ahead log, it always carries the resulting state
+//so replaying a record more than once is harmless
+type walRecord struct {
+	Op      string        `json:"op"`
+	User    *users.User   `json:"user,omitempty"`
+	ID      int           `json:"id,omitempty"`
+	Events  []users.Event `json:"events,omitempty"`
+	IDs     []int64       `json:"ids,omitempty"`
+	Records []walRecord   `json:"records,omitempty"`
+}
+
+//snapshot is the compacted state of the repository
+type snapshot struct {
+	LastID      int           `json:"last_id"`
+	Users       []users.User  `json:"users"`
+	LastEventID int64         `json:"last_event_id"`
+	Events      []users.Event `json:"events"`
+}
+
+//FileUserRepository is a durable implementation of user Repository, users are served from memory
+//while every change is appended to a write-ahead log that is periodically compacted into a snapshot
+type FileUserRepository struct {
+	mem *InMemoryUserRepository
+
+	//mu serializes the writes so the log order matches the order the changes were applied
+	mu           sync.Mutex
+	dir          string
+	wal          *os.File
+	writes       int
+	compactEvery int
+	logger       users.Logger
+}
+
+//FileOption - configures optional behavior of a FileUserRepository
+type FileOption func(*FileUserRepository)
+
+//WithFileLogger - reports the failed compactions to the logger
+func WithFileLogger(logger users.Logger) FileOption {
+	return func(repo *FileUserRepository) {
+		repo.logger = logger
+	}
+}
+
+//NewFileUserRepository replays the snapshot and the log found in dir and returns a FileUserRepository type pointer,
+//the log is compacted every compactEvery writes (DefaultCompactEvery when it is not positive)
+func NewFileUserRepository(dir string, compactEvery int, opts ...FileOption) (*FileUserRepository, error) {
+
+	if compactEvery <= 0 {
+		compactEvery = DefaultCompactEvery
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	repo := &FileUserRepository{
+		mem:          NewInMemoryUserRepository(),
+		dir:          dir,
+		compactEvery: compactEvery,
+		logger:       users.NopLogger{},
+	}
+
+	for _, opt := range opts {
+		opt(repo)
+	}
+
+	if err := repo.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	if err := repo.replay(); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(repo.path(walFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	repo.wal = wal
+
+	return repo, nil
+}
+
+//Add - adds a user to the repository
+func (repo *FileUserRepository) Add(ctx context.Context, u users.User) (int, error) {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if existing, _ := repo.mem.GetByEmail(ctx, u.Email); existing.ID > 0 {
+		return 0, nil
+	}
+
+	u.ID = repo.mem.nextID()
+	u.Version = 1
+
+	if err := repo.log(walRecord{Op: opPut, User: &u}); err != nil {
+		return 0, err
+	}
+
+	repo.mem.put(u)
+	repo.compactIfNeeded()
+
+	return u.ID, nil
+}
+
+//GetByID - retrieves a user from the repository based on the integer id
+func (repo *FileUserRepository) GetByID(ctx context.Context, userID int) (users.User, error) {
+	return repo.mem.GetByID(ctx, userID)
+}
+
+//GetByEmail - retrieves a user from the repository based on the email address
+func (repo *FileUserRepository) GetByEmail(ctx context.Context, email string) (users.User, error) {
+	return repo.mem.GetByEmail(ctx, email)
+}
+
+//GetAll - retrieves all the users from the repository
+func (repo *FileUserRepository) GetAll(ctx context.Context) ([]users.User, error) {
+	return repo.mem.GetAll(ctx)
+}
+
+//List - retrieves the users matching the options ordered by id
+func (repo *FileUserRepository) List(ctx context.Context, opts users.ListOptions) ([]users.User, error) {
+	return repo.mem.List(ctx, opts)
+}
+
+//Count - counts the users matching the filter
+func (repo *FileUserRepository) Count(ctx context.Context, filter users.Filter) (int, error) {
+	return repo.mem.Count(ctx, filter)
+}
+
+//Update -  updates the information of a user, users.ErrUserAlreadyExists is returned
+//when another user has the new email
+func (repo *FileUserRepository) Update(ctx context.Context, u users.User) error {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, _ := repo.mem.GetByID(ctx, u.ID)
+
+	if stored.ID == 0 {
+		return nil
+	}
+
+	if !versionMatches(stored, u.Version) {
+		return users.ErrVersionMismatch
+	}
+
+	if existing, _ := repo.mem.GetByEmail(ctx, u.Email); existing.ID > 0 && existing.ID != u.ID {
+		return users.ErrUserAlreadyExists
+	}
+
+	updated := mergeUpdate(stored, u)
+
+	if err := repo.log(walRecord{Op: opPut, User: &updated}); err != nil {
+		return err
+	}
+
+	repo.mem.put(updated)
+	repo.compactIfNeeded()
+
+	return nil
+}
+
+//Delete - deletes a user from the repository
+func (repo *FileUserRepository) Delete(ctx context.Context, userID int, version int) error {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, _ := repo.mem.GetByID(ctx, userID)
+
+	if stored.ID == 0 {
+		return nil
+	}
+
+	if !versionMatches(stored, version) {
+		return users.ErrVersionMismatch
+	}
+
+	if err := repo.log(walRecord{Op: opDelete, ID: userID}); err != nil {
+		return err
+	}
+
+	if err := repo.mem.Delete(ctx, userID, 0); err != nil {
+		return err
+	}
+
+	repo.compactIfNeeded()
+
+	return nil
+}
+
+//WithinTransaction - runs fn and logs every change it made as a single record, the changes are undone
+//when fn or the log fails
+func (repo *FileUserRepository) WithinTransaction(ctx context.Context, fn func(users.Repository, users.Outbox) error) error {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	err := repo.mem.transaction(func(memTx *inMemoryTx) error {
+		tx := &fileTx{inMemoryTx: memTx}
+
+		if err := fn(tx, tx); err != nil {
+			return err
+		}
+
+		if len(tx.records) == 0 {
+			return nil
+		}
+
+		return repo.log(walRecord{Op: opBatch, Records: tx.records})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	repo.compactIfNeeded()
+
+	return nil
+}
+
+//Append - records the events to be published
+func (repo *FileUserRepository) Append(ctx context.Context, events ...users.Event) error {
+	return repo.WithinTransaction(ctx, func(_ users.Repository, outbox users.Outbox) error {
+		return outbox.Append(ctx, events...)
+	})
+}
+
+//Pending - retrieves up to limit events not yet published in the order they were recorded
+func (repo *FileUserRepository) Pending(ctx context.Context, limit int) ([]users.Event, error) {
+	return repo.mem.Pending(ctx, limit)
+}
+
+//MarkPublished - removes the published events from the outbox
+func (repo *FileUserRepository) MarkPublished(ctx context.Context, ids ...int64) error {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if err := repo.log(walRecord{Op: opPublished, IDs: ids}); err != nil {
+		return err
+	}
+
+	repo.mem.MarkPublished(ctx, ids...)
+	repo.compactIfNeeded()
+
+	return nil
+}
+
+//Compact writes the current state to the snapshot and empties the log
+func (repo *FileUserRepository) Compact() error {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	return repo.compact()
+}
+
+//Ping - checks that the log is still open
+func (repo *FileUserRepository) Ping(ctx context.Context) error {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	_, err := repo.wal.Stat()
+
+	return err
+}
+
+//Close compacts the log and releases the underlying files
+func (repo *FileUserRepository) Close() error {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if err := repo.compact(); err != nil {
+		repo.wal.Close()
+		return err
+	}
+
+	return repo.wal.Close()
+}
+
+func (repo *FileUserRepository) path(name string) string {
+	return filepath.Join(repo.dir, name)
+}
+
+//log appends the record to the write-ahead log and waits for it to reach the disk
+func (repo *FileUserRepository) log(record walRecord) error {
+
+	line, err := json.Marshal(record)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := repo.wal.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	repo.writes++
+
+	return repo.wal.Sync()
+}
+
+//compactIfNeeded compacts once enough writes were logged, a failure does not affect the write
+//that triggered it since the record is already in the log, so it is retried on the next write
+func (repo *FileUserRepository) compactIfNeeded() {
+
+	if repo.writes < repo.compactEvery {
+		return
+	}
+
+	if err := repo.compact(); err != nil {
+		repo.logger.Warn("compacting the file repository failed", "dir", repo.dir, "error", err)
+	}
+}
+
+//compact replaces the snapshot atomically and then truncates the log, a crash between both steps
+//only means some records are replayed on top of a snapshot that already contains them
+func (repo *FileUserRepository) compact() error {
+
+	lastID, all, lastEventID, events := repo.mem.state()
+
+	data, err := json.Marshal(snapshot{LastID: lastID, Users: all, LastEventID: lastEventID, Events: events})
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(repo.dir, snapshotFileName+".*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), repo.path(snapshotFileName)); err != nil {
+		return err
+	}
+
+	if err := repo.wal.Truncate(0); err != nil {
+		return err
+	}
+
+	repo.writes = 0
+
+	return nil
+}
+
+func (repo *FileUserRepository) loadSnapshot() error {
+
+	data, err := ioutil.ReadFile(repo.path(snapshotFileName))
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var snap snapshot
+
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+
+	for _, u := range snap.Users {
+		repo.mem.put(u)
+	}
+
+	repo.mem.reserveIDs(snap.LastID)
+
+	for _, e := range snap.Events {
+		repo.mem.putEvent(e)
+	}
+
+	repo.mem.reserveEventIDs(snap.LastEventID)
+
+	return nil
+}
+
+//replay applies the log on top of the snapshot, a torn record at the end of the log
+//(a crash in the middle of a write) is discarded
+func (repo *FileUserRepository) replay() error {
+
+	f, err := os.OpenFile(repo.path(walFileName), os.O_RDWR, 0644)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var offset int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				return f.Truncate(offset)
+			}
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var record walRecord
+
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("reading log at offset %d: %w", offset, err)
+		}
+
+		if err := repo.apply(record); err != nil {
+			return err
+		}
+
+		offset += int64(len(line))
+		repo.writes++
+	}
+}
+
+func (repo *FileUserRepository) apply(record walRecord) error {
+
+	switch record.Op {
+	case opPut:
+		if record.User == nil {
+			return errors.New("log put record without user")
+		}
+		repo.mem.put(*record.User)
+	case opDelete:
+		return repo.mem.Delete(context.Background(), record.ID, 0)
+	case opEvents:
+		for _, e := range record.Events {
+			repo.mem.putEvent(e)
+		}
+	case opPublished:
+		return repo.mem.MarkPublished(context.Background(), record.IDs...)
+	case opBatch:
+		for _, r := range record.Records {
+			if err := repo.apply(r); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("unknown log operation " + record.Op)
+	}
+
+	return nil
+}
+
+//fileTx is the repository and outbox given to the function run by WithinTransaction, the changes are
+//applied in memory and collected as log records
+type fileTx struct {
+	*inMemoryTx
+	records []walRecord
+}
+
+//Add - adds a user to the repository
+func (tx *fileTx) Add(ctx context.Context, u users.User) (int, error) {
+
+	id, err := tx.inMemoryTx.Add(ctx, u)
+
+	if err == nil && id > 0 {
+		tx.logPut(id)
+	}
+
+	return id, err
+}
+
+//Update -  updates the information of a user
+func (tx *fileTx) Update(ctx context.Context, u users.User) error {
+
+	if err := tx.inMemoryTx.Update(ctx, u); err != nil {
+		return err
+	}
+
+	tx.logPut(u.ID)
+
+	return nil
+}
+
+//Delete - deletes a user from the repository
+func (tx *fileTx) Delete(ctx context.Context, userID int, version int) error {
+
+	if err := tx.inMemoryTx.Delete(ctx, userID, version); err != nil {
+		return err
+	}
+
+	tx.records = append(tx.records, walRecord{Op: opDelete, ID: userID})
+
+	return nil
+}
+
+//Append - records the events to be published
+func (tx *fileTx) Append(ctx context.Context, events ...users.Event) error {
+	tx.records = append(tx.records, walRecord{Op: opEvents, Events: tx.appendEvents(events)})
+	return nil
+}
+
+//MarkPublished - removes the published events from the outbox
+func (tx *fileTx) MarkPublished(ctx context.Context, ids ...int64) error {
+
+	if err := tx.inMemoryTx.MarkPublished(ctx, ids...); err != nil {
+		return err
+	}
+
+	tx.records = append(tx.records, walRecord{Op: opPublished, IDs: ids})
+
+	return nil
+}
+
+//logPut records the resulting state of the user, nothing when the user does not exist
+func (tx *fileTx) logPut(userID int) {
+
+	if usr := tx.repo.findByID(userID); usr.ID > 0 {
+		tx.records = append(tx.records, walRecord{Op: opPut, User: &usr})
+	}
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/casmelad/bootcamp-gateway/server/repository/repositorytest"
+	"github.com/casmelad/bootcamp-gateway/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openFileTestRepository(t *testing.T, dir string, compactEvery int) *FileUserRepository {
+
+	repository, err := NewFileUserRepository(dir, compactEvery)
+	require.NoError(t, err)
+
+	return repository
+}
+
+func Test_FileUserRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func() users.Repository {
+		repository := openFileTestRepository(t, t.TempDir(), 0)
+		t.Cleanup(func() { repository.Close() })
+		return repository
+	})
+}
+
+func Test_FileUserRepository_OutboxConformance(t *testing.T) {
+	repositorytest.RunOutbox(t, func() repositorytest.OutboxRepository {
+		repository := openFileTestRepository(t, t.TempDir(), 0)
+		t.Cleanup(func() { repository.Close() })
+		return repository
+	})
+}
+
+func Test_File_Reopen_ReplaysLog(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	ctx := context.Background()
+	repository := openFileTestRepository(t, dir, 100)
+	kept := users.User{Email: "test@gmail.com", Name: "Test1", LastName: "LastName1"}
+	kept.ID, _ = repository.Add(ctx, kept)
+	deletedID, _ := repository.Add(ctx, users.User{Email: "test2@gmail.com"})
+	kept.Name = "Test1_Updated"
+	repository.Update(ctx, kept)
+	kept.Version = 2
+	repository.Delete(ctx, deletedID, 0)
+	require.NoError(t, repository.wal.Close())
+	//Act
+	reopened := openFileTestRepository(t, dir, 100)
+	defer reopened.Close()
+	all, err := reopened.GetAll(ctx)
+	newID, _ := reopened.Add(ctx, users.User{Email: "test3@gmail.com"})
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []users.User{kept}, all)
+	assert.Equal(t, deletedID+1, newID)
+}
+
+func Test_File_Reopen_KeepsChangedEmail(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	ctx := context.Background()
+	repository := openFileTestRepository(t, dir, 100)
+	changed := users.User{Email: "test@gmail.com", Name: "Test1", LastName: "LastName1"}
+	changed.ID, _ = repository.Add(ctx, changed)
+	changed.Email = "changed@gmail.com"
+	repository.Update(ctx, changed)
+	changed.Version = 2
+	require.NoError(t, repository.wal.Close())
+	//Act
+	reopened := openFileTestRepository(t, dir, 100)
+	defer reopened.Close()
+	all, _ := reopened.GetAll(ctx)
+	previous, _ := reopened.GetByEmail(ctx, "test@gmail.com")
+	//Assert
+	assert.Equal(t, []users.User{changed}, all)
+	assert.Equal(t, users.User{}, previous)
+}
+
+func Test_File_Compact_WritesSnapshotAndEmptiesLog(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	ctx := context.Background()
+	repository := openFileTestRepository(t, dir, 2)
+	first, _ := repository.Add(ctx, users.User{Email: "test@gmail.com"})
+	//Act
+	second, _ := repository.Add(ctx, users.User{Email: "test2@gmail.com"})
+	repository.Delete(ctx, second, 0)
+	require.NoError(t, repository.wal.Close())
+	//Assert
+	snapshot, err := ioutil.ReadFile(filepath.Join(dir, snapshotFileName))
+	assert.Nil(t, err)
+	assert.Contains(t, string(snapshot), "test2@gmail.com")
+	wal, _ := ioutil.ReadFile(filepath.Join(dir, walFileName))
+	assert.Equal(t, 1, len(bytesLines(wal)))
+
+	reopened := openFileTestRepository(t, dir, 2)
+	defer reopened.Close()
+	all, _ := reopened.GetAll(ctx)
+	assert.Equal(t, []users.User{{ID: first, Email: "test@gmail.com", Version: 1}}, all)
+	newID, _ := reopened.Add(ctx, users.User{Email: "test3@gmail.com"})
+	assert.Equal(t, second+1, newID)
+}
+
+//warningLogger keeps the messages of the warnings written to it
+type warningLogger struct {
+	users.NopLogger
+	warnings []string
+}
+
+func (l *warningLogger) Warn(msg string, keyvals ...interface{}) {
+	l.warnings = append(l.warnings, msg)
+}
+
+func Test_File_CompactFails_LogsAndKeepsTheWrite(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	ctx := context.Background()
+	logger := &warningLogger{}
+	repository, err := NewFileUserRepository(dir, 1, WithFileLogger(logger))
+	require.NoError(t, err)
+	defer repository.Close()
+	//a non empty directory in place of the snapshot cannot be replaced
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, snapshotFileName, "blocked"), 0755))
+	//Act
+	id, err := repository.Add(ctx, users.User{Email: "test@gmail.com"})
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"compacting the file repository failed"}, logger.warnings)
+	stored, _ := repository.GetByID(ctx, id)
+	assert.Equal(t, "test@gmail.com", stored.Email)
+	wal, _ := ioutil.ReadFile(filepath.Join(dir, walFileName))
+	assert.Equal(t, 1, len(bytesLines(wal)))
+}
+
+func Test_File_Close_KeepsIdsAfterDeletingTheLastUser(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	ctx := context.Background()
+	repository := openFileTestRepository(t, dir, 0)
+	userID, _ := repository.Add(ctx, users.User{Email: "test@gmail.com"})
+	repository.Delete(ctx, userID, 0)
+	require.NoError(t, repository.Close())
+	//Act
+	reopened := openFileTestRepository(t, dir, 0)
+	defer reopened.Close()
+	newID, err := reopened.Add(ctx, users.User{Email: "test@gmail.com"})
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, userID+1, newID)
+}
+
+func Test_File_Reopen_DiscardsTornRecord(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	ctx := context.Background()
+	repository := openFileTestRepository(t, dir, 100)
+	userID, _ := repository.Add(ctx, users.User{Email: "test@gmail.com"})
+	require.NoError(t, repository.wal.Close())
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	wal.WriteString(`{"op":"put","user":{"id":2,"em`)
+	wal.Close()
+	//Act
+	reopened := openFileTestRepository(t, dir, 100)
+	defer reopened.Close()
+	all, _ := reopened.GetAll(ctx)
+	newID, _ := reopened.Add(ctx, users.User{Email: "test2@gmail.com"})
+	//Assert
+	assert.Equal(t, []users.User{{ID: userID, Email: "test@gmail.com", Version: 1}}, all)
+	assert.Equal(t, userID+1, newID)
+}
+
+func Test_File_Reopen_KeepsPendingEvents(t *testing.T) {
+	for _, compact := range []bool{false, true} {
+		//Arrange
+		dir := t.TempDir()
+		ctx := context.Background()
+		repository := openFileTestRepository(t, dir, 100)
+		var userID int
+		repository.WithinTransaction(ctx, func(txRepo users.Repository, outbox users.Outbox) error {
+			userID, _ = txRepo.Add(ctx, users.User{Email: "test@gmail.com"})
+			return outbox.Append(ctx, users.Event{Type: users.EventUserCreated, UserID: userID}, users.Event{Type: users.EventUserUpdated, UserID: userID})
+		})
+		pending, _ := repository.Pending(ctx, 10)
+		require.Len(t, pending, 2)
+		repository.MarkPublished(ctx, pending[0].ID)
+		if compact {
+			require.NoError(t, repository.Compact())
+		}
+		require.NoError(t, repository.wal.Close())
+		//Act
+		reopened := openFileTestRepository(t, dir, 100)
+		result, err := reopened.Pending(ctx, 10)
+		reopened.Append(ctx, users.Event{Type: users.EventUserDeleted, UserID: userID})
+		next, _ := reopened.Pending(ctx, 10)
+		reopened.Close()
+		//Assert
+		assert.Nil(t, err)
+		assert.Equal(t, pending[1:], result)
+		require.Len(t, next, 2)
+		assert.Equal(t, pending[1].ID+1, next[1].ID)
+		stored, _ := reopened.GetByID(ctx, userID)
+		assert.Equal(t, "test@gmail.com", stored.Email)
+	}
+}
+
+func Test_File_Reopen_DiscardsTornTransaction(t *testing.T) {
+	//Arrange
+	dir := t.TempDir()
+	ctx := context.Background()
+	repository := openFileTestRepository(t, dir, 100)
+	require.NoError(t, repository.wal.Close())
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	wal.WriteString(`{"op":"batch","records":[{"op":"put","user":{"id":1,"email":"test@gmail.com","version":1}},{"op":"ev`)
+	wal.Close()
+	//Act
+	reopened := openFileTestRepository(t, dir, 100)
+	defer reopened.Close()
+	all, _ := reopened.GetAll(ctx)
+	pending, _ := reopened.Pending(ctx, 10)
+	//Assert
+	assert.Empty(t, all)
+	assert.Empty(t, pending)
+}
+
+func bytesLines(data []byte) []string {
+	lines := []string{}
+	start := 0
+	for i, b := range data {
+		if b == '\n' {
+			lines = append(lines, string(data[start:i]))
+			start = i + 1
+		}
+	}
+	return lines
+}
+
+func Test_File_Ping_FailsOnceClosed(t *testing.T) {
+	//Arrange
+	repository := openFileTestRepository(t, t.TempDir(), 0)
+	ctx := context.Background()
+	//Act
+	open := repository.Ping(ctx)
+	repository.Close()
+	closed := repository.Ping(ctx)
+	//Assert
+	assert.Nil(t, open)
+	assert.NotNil(t, closed)
+}
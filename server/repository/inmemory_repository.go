@@ -2,94 +2,444 @@ package repository
 
 import (
 	"context"
+	"sort"
+	"sync"
 
 	"github.com/casmelad/bootcamp-gateway/users"
 )
 
-//InMemoryUserRepository is an in memory implementation of user Repository
+//InMemoryUserRepository is an in memory implementation of user Repository with an outbox of events,
+//it is safe for concurrent use
 type InMemoryUserRepository struct {
+	mu     sync.RWMutex
 	dict   map[string]users.User
-	regist []int
+	byID   map[int]string
+	lastID int
+	//outbox holds the events not yet published ordered by id
+	outbox      []users.Event
+	lastEventID int64
 }
 
 //NewInMemoryUserRepository returns an InMemoryUserRepository type pointer
 func NewInMemoryUserRepository() *InMemoryUserRepository {
 	return &InMemoryUserRepository{
-		dict:   map[string]users.User{},
-		regist: []int{},
+		dict: map[string]users.User{},
+		byID: map[int]string{},
 	}
 }
 
 //Add - adds a user to the repository
 func (repo *InMemoryUserRepository) Add(ctx context.Context, u users.User) (int, error) {
 
-	if _, ok := repo.dict[u.Email]; ok {
-		return 0, nil
-	}
-
-	u.ID = len(repo.regist) + 1
-	repo.regist = append(repo.regist, u.ID)
-	repo.dict[u.Email] = u
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
 
-	return u.ID, nil
+	return repo.add(u), nil
 }
 
 //GetByID - retrieves a user from the repository based on the integer id
 func (repo *InMemoryUserRepository) GetByID(ctx context.Context, userID int) (users.User, error) {
 
-	for _, usr := range repo.dict {
-		if usr.ID == userID {
-			return usr, nil
-		}
-	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
 
-	return users.User{}, nil
+	return repo.findByID(userID), nil
 }
 
 //GetByEmail - retrieves a user from the repository based on the email address
 func (repo *InMemoryUserRepository) GetByEmail(ctx context.Context, id string) (users.User, error) {
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
 	return repo.dict[id], nil
 }
 
 //GetAll - retrieves all the users from the repository
 func (repo *InMemoryUserRepository) GetAll(ctx context.Context) ([]users.User, error) {
 
-	result := []users.User{}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.all(), nil
+}
+
+//List - retrieves the users matching the options ordered by id
+func (repo *InMemoryUserRepository) List(ctx context.Context, opts users.ListOptions) ([]users.User, error) {
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.list(opts), nil
+}
+
+//Count - counts the users matching the filter
+func (repo *InMemoryUserRepository) Count(ctx context.Context, filter users.Filter) (int, error) {
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.count(filter), nil
+}
+
+//Update -  updates the information of a user, the user is re-keyed when the email changes
+//and users.ErrUserAlreadyExists is returned when another user has the new email
+func (repo *InMemoryUserRepository) Update(ctx context.Context, u users.User) error {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	_, err := repo.update(u)
+
+	return err
+}
+
+//Delete - deletes a user from the repository
+func (repo *InMemoryUserRepository) Delete(ctx context.Context, userID int, version int) error {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	_, err := repo.delete(userID, version)
+
+	return err
+}
+
+//WithinTransaction - runs fn holding the lock, the changes fn made are undone when it fails
+func (repo *InMemoryUserRepository) WithinTransaction(ctx context.Context, fn func(users.Repository, users.Outbox) error) error {
+	return repo.transaction(func(tx *inMemoryTx) error {
+		return fn(tx, tx)
+	})
+}
+
+//Append - records the events to be published
+func (repo *InMemoryUserRepository) Append(ctx context.Context, events ...users.Event) error {
+	return repo.transaction(func(tx *inMemoryTx) error {
+		return tx.Append(ctx, events...)
+	})
+}
+
+//Pending - retrieves up to limit events not yet published in the order they were recorded
+func (repo *InMemoryUserRepository) Pending(ctx context.Context, limit int) ([]users.Event, error) {
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.pending(limit), nil
+}
+
+//MarkPublished - removes the published events from the outbox
+func (repo *InMemoryUserRepository) MarkPublished(ctx context.Context, ids ...int64) error {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.markPublished(ids)
+
+	return nil
+}
+
+//transaction runs fn holding the lock and undoes its changes when it fails
+func (repo *InMemoryUserRepository) transaction(fn func(*inMemoryTx) error) error {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	tx := &inMemoryTx{repo: repo}
+
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+
+	return nil
+}
+
+//add stores a new user with the next id and version 1, 0 is returned when the email is taken,
+//the caller must hold the lock
+func (repo *InMemoryUserRepository) add(u users.User) int {
+
+	if _, ok := repo.dict[u.Email]; ok {
+		return 0
+	}
+
+	repo.lastID++
+	u.ID = repo.lastID
+	u.Version = 1
+	repo.dict[u.Email] = u
+	repo.byID[u.ID] = u.Email
+
+	return u.ID
+}
+
+//all returns every stored user, the caller must hold the lock
+func (repo *InMemoryUserRepository) all() []users.User {
+
+	result := make([]users.User, 0, len(repo.dict))
 
 	for _, usr := range repo.dict {
 		result = append(result, usr)
 	}
 
-	return result, nil
+	return result
 }
 
-//Update -  updates the information of a user
-func (repo *InMemoryUserRepository) Update(ctx context.Context, u users.User) error {
+//list returns the users matching the options ordered by id, the caller must hold the lock
+func (repo *InMemoryUserRepository) list(opts users.ListOptions) []users.User {
 
-	userToUpdate, err := repo.GetByID(ctx, u.ID)
+	ids := make([]int, 0, len(repo.byID))
 
-	if err != nil {
-		return err
+	for id := range repo.byID {
+		if id > opts.AfterID {
+			ids = append(ids, id)
+		}
 	}
 
-	if userToUpdate.ID > 0 {
-		userToUpdate.Name = u.Name
-		userToUpdate.LastName = u.LastName
-		repo.dict[userToUpdate.Email] = userToUpdate
+	sort.Ints(ids)
+
+	result := []users.User{}
+
+	for _, id := range ids {
+		if opts.Limit > 0 && len(result) == opts.Limit {
+			break
+		}
+
+		if usr := repo.dict[repo.byID[id]]; opts.Filter.Matches(usr) {
+			result = append(result, usr)
+		}
 	}
 
-	return nil
+	return result
 }
 
-//Delete - deletes a user from the repository
-func (repo *InMemoryUserRepository) Delete(ctx context.Context, userID int) error {
+//count counts the users matching the filter, the caller must hold the lock
+func (repo *InMemoryUserRepository) count(filter users.Filter) int {
+
+	count := 0
 
 	for _, usr := range repo.dict {
-		if usr.ID == userID {
-			delete(repo.dict, usr.Email)
-			return nil
+		if filter.Matches(usr) {
+			count++
 		}
 	}
 
-	return nil
+	return count
+}
+
+//update replaces the updatable fields of the user and returns the user it replaced, zero when there is no
+//user with the id, the caller must hold the lock
+func (repo *InMemoryUserRepository) update(u users.User) (users.User, error) {
+
+	userToUpdate := repo.findByID(u.ID)
+
+	if userToUpdate.ID == 0 {
+		return users.User{}, nil
+	}
+
+	if !versionMatches(userToUpdate, u.Version) {
+		return users.User{}, users.ErrVersionMismatch
+	}
+
+	if repo.emailTaken(u.Email, u.ID) {
+		return users.User{}, users.ErrUserAlreadyExists
+	}
+
+	updated := mergeUpdate(userToUpdate, u)
+
+	delete(repo.dict, userToUpdate.Email)
+	repo.dict[updated.Email] = updated
+	repo.byID[updated.ID] = updated.Email
+
+	return userToUpdate, nil
+}
+
+//delete removes the user and returns it, zero when there is no user with the id, the caller must hold the lock
+func (repo *InMemoryUserRepository) delete(userID int, version int) (users.User, error) {
+
+	email, ok := repo.byID[userID]
+
+	if !ok {
+		return users.User{}, nil
+	}
+
+	removed := repo.dict[email]
+
+	if !versionMatches(removed, version) {
+		return users.User{}, users.ErrVersionMismatch
+	}
+
+	delete(repo.dict, email)
+	delete(repo.byID, userID)
+
+	return removed, nil
+}
+
+//appendEvents assigns the next ids to the events and adds them to the outbox, the caller must hold the lock
+func (repo *InMemoryUserRepository) appendEvents(events []users.Event) []users.Event {
+
+	appended := make([]users.Event, 0, len(events))
+
+	for _, e := range events {
+		repo.lastEventID++
+		e.ID = repo.lastEventID
+		appended = append(appended, e)
+	}
+
+	repo.outbox = append(repo.outbox, appended...)
+
+	return appended
+}
+
+//pending returns up to limit events of the outbox, every event when limit is not positive,
+//the caller must hold the lock
+func (repo *InMemoryUserRepository) pending(limit int) []users.Event {
+
+	if limit <= 0 || limit > len(repo.outbox) {
+		limit = len(repo.outbox)
+	}
+
+	return append([]users.Event{}, repo.outbox[:limit]...)
+}
+
+//markPublished removes the events from the outbox, the caller must hold the lock
+func (repo *InMemoryUserRepository) markPublished(ids []int64) {
+
+	published := make(map[int64]bool, len(ids))
+
+	for _, id := range ids {
+		published[id] = true
+	}
+
+	kept := repo.outbox[:0]
+
+	for _, e := range repo.outbox {
+		if !published[e.ID] {
+			kept = append(kept, e)
+		}
+	}
+
+	repo.outbox = kept
+}
+
+//findByID looks up a user through the id index, the caller must hold the lock
+func (repo *InMemoryUserRepository) findByID(userID int) users.User {
+
+	email, ok := repo.byID[userID]
+
+	if !ok {
+		return users.User{}
+	}
+
+	return repo.dict[email]
+}
+
+//emailTaken reports whether a user other than userID has the email, the caller must hold the lock
+func (repo *InMemoryUserRepository) emailTaken(email string, userID int) bool {
+
+	usr, ok := repo.dict[email]
+
+	return ok && usr.ID != userID
+}
+
+//put stores the user with its own id, replacing any previous version, used to restore persisted state
+func (repo *InMemoryUserRepository) put(u users.User) {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.putLocked(u)
+}
+
+//putLocked stores the user with its own id, the caller must hold the lock
+func (repo *InMemoryUserRepository) putLocked(u users.User) {
+
+	if email, ok := repo.byID[u.ID]; ok {
+		delete(repo.dict, email)
+	}
+
+	repo.dict[u.Email] = u
+	repo.byID[u.ID] = u.Email
+
+	if u.ID > repo.lastID {
+		repo.lastID = u.ID
+	}
+}
+
+//nextID returns the id the next added user will receive
+func (repo *InMemoryUserRepository) nextID() int {
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.lastID + 1
+}
+
+//reserveIDs makes sure ids up to lastID are never assigned again
+func (repo *InMemoryUserRepository) reserveIDs(lastID int) {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if lastID > repo.lastID {
+		repo.lastID = lastID
+	}
+}
+
+//putEvent stores an event of the outbox with its own id unless it is already there, used to restore
+//persisted state
+func (repo *InMemoryUserRepository) putEvent(e users.Event) {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	i := sort.Search(len(repo.outbox), func(i int) bool { return repo.outbox[i].ID >= e.ID })
+
+	if i < len(repo.outbox) && repo.outbox[i].ID == e.ID {
+		return
+	}
+
+	repo.outbox = append(repo.outbox, users.Event{})
+	copy(repo.outbox[i+1:], repo.outbox[i:])
+	repo.outbox[i] = e
+
+	if e.ID > repo.lastEventID {
+		repo.lastEventID = e.ID
+	}
+}
+
+//reserveEventIDs makes sure event ids up to lastEventID are never assigned again
+func (repo *InMemoryUserRepository) reserveEventIDs(lastEventID int64) {
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if lastEventID > repo.lastEventID {
+		repo.lastEventID = lastEventID
+	}
+}
+
+//state returns a consistent copy of the stored users and events together with the last assigned ids
+func (repo *InMemoryUserRepository) state() (int, []users.User, int64, []users.Event) {
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.lastID, repo.all(), repo.lastEventID, repo.pending(0)
+}
+
+//mergeUpdate returns the stored user with the updatable fields taken from changes and the next version
+func mergeUpdate(stored, changes users.User) users.User {
+	stored.Email = changes.Email
+	stored.Name = changes.Name
+	stored.LastName = changes.LastName
+	stored.DeletedAt = changes.DeletedAt
+	stored.UpdatedAt = changes.UpdatedAt
+	stored.UpdatedBy = changes.UpdatedBy
+	stored.Version++
+	return stored
+}
+
+//versionMatches reports whether the stored user has the expected version, 0 matches any version
+func versionMatches(stored users.User, expected int) bool {
+	return expected == 0 || stored.Version == expected
 }
@@ -2,8 +2,11 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 
+	"github.com/casmelad/bootcamp-gateway/server/repository/repositorytest"
 	"github.com/casmelad/bootcamp-gateway/users"
 	"github.com/stretchr/testify/assert"
 )
@@ -38,7 +41,7 @@ func Test_GetByEmail_ReturnsExistingData(t *testing.T) {
 	id := "test@gmail.com"
 	repository := NewInMemoryUserRepository()
 	userToAdd := users.User{Email: id}
-	expected := users.User{ID: 1, Email: id}
+	expected := users.User{ID: 1, Email: id, Version: 1}
 	ctx := context.Background()
 	repository.Add(ctx, userToAdd)
 	//Act
@@ -119,7 +122,85 @@ func Test_Delete_ValidId_DeletesUser(t *testing.T) {
 	ctx := context.Background()
 	userID, _ := repository.Add(ctx, userToAdd)
 	//Act
-	err := repository.Delete(ctx, userID)
+	err := repository.Delete(ctx, userID, 0)
 	//Assert
 	assert.Nil(t, err)
 }
+
+func Test_InMemoryUserRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func() users.Repository {
+		return NewInMemoryUserRepository()
+	})
+}
+
+func Test_InMemoryUserRepository_OutboxConformance(t *testing.T) {
+	repositorytest.RunOutbox(t, func() repositorytest.OutboxRepository {
+		return NewInMemoryUserRepository()
+	})
+}
+
+func Test_Add_AfterDelete_DoesNotReuseId(t *testing.T) {
+	//Arrange
+	repository := NewInMemoryUserRepository()
+	ctx := context.Background()
+	repository.Add(ctx, users.User{Email: "test@gmail.com"})
+	deletedID, _ := repository.Add(ctx, users.User{Email: "test2@gmail.com"})
+	repository.Delete(ctx, deletedID, 0)
+	//Act
+	result, err := repository.Add(ctx, users.User{Email: "test3@gmail.com"})
+	//Assert
+	assert.Equal(t, 3, result)
+	assert.Nil(t, err)
+}
+
+func Test_Delete_RemovesIdIndexEntry(t *testing.T) {
+	//Arrange
+	repository := NewInMemoryUserRepository()
+	ctx := context.Background()
+	userID, _ := repository.Add(ctx, users.User{Email: "test@gmail.com"})
+	//Act
+	repository.Delete(ctx, userID, 0)
+	result, err := repository.GetByID(ctx, userID)
+	//Assert
+	assert.Equal(t, users.User{}, result)
+	assert.Nil(t, err)
+	assert.Empty(t, repository.byID)
+	assert.Empty(t, repository.dict)
+}
+
+//Test_Stress_ConcurrentMutations_KeepsIndexConsistent is meant to be run with -race
+func Test_Stress_ConcurrentMutations_KeepsIndexConsistent(t *testing.T) {
+	//Arrange
+	repository := NewInMemoryUserRepository()
+	ctx := context.Background()
+	workers, iterations := 16, 200
+	//Act
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				email := fmt.Sprintf("user%d@gmail.com", i%20)
+				id, _ := repository.Add(ctx, users.User{Email: email, Name: "Name", LastName: "LastName"})
+				repository.GetByEmail(ctx, email)
+				repository.GetAll(ctx)
+				if id > 0 {
+					repository.Update(ctx, users.User{ID: id, Email: email, Name: fmt.Sprintf("Name%d", w), LastName: "Updated"})
+					repository.GetByID(ctx, id)
+				}
+				if (w+i)%3 == 0 {
+					usr, _ := repository.GetByEmail(ctx, email)
+					repository.Delete(ctx, usr.ID, 0)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	//Assert
+	assert.Equal(t, len(repository.dict), len(repository.byID))
+	for id, email := range repository.byID {
+		assert.Equal(t, id, repository.dict[email].ID)
+	}
+	assert.LessOrEqual(t, len(repository.dict), 20)
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+)
+
+//inMemoryTx is the repository and outbox given to the function run by WithinTransaction, it works
+//on the repository while its lock is held and keeps what is needed to undo every change
+type inMemoryTx struct {
+	repo *InMemoryUserRepository
+	undo []func()
+}
+
+//Add - adds a user to the repository
+func (tx *inMemoryTx) Add(ctx context.Context, u users.User) (int, error) {
+
+	id := tx.repo.add(u)
+
+	if id > 0 {
+		tx.onRollback(func() { tx.repo.delete(id, 0) })
+	}
+
+	return id, nil
+}
+
+//GetByID - retrieves a user from the repository based on the integer id
+func (tx *inMemoryTx) GetByID(ctx context.Context, userID int) (users.User, error) {
+	return tx.repo.findByID(userID), nil
+}
+
+//GetByEmail - retrieves a user from the repository based on the email address
+func (tx *inMemoryTx) GetByEmail(ctx context.Context, email string) (users.User, error) {
+	return tx.repo.dict[email], nil
+}
+
+//GetAll - retrieves all the users from the repository
+func (tx *inMemoryTx) GetAll(ctx context.Context) ([]users.User, error) {
+	return tx.repo.all(), nil
+}
+
+//List - retrieves the users matching the options ordered by id
+func (tx *inMemoryTx) List(ctx context.Context, opts users.ListOptions) ([]users.User, error) {
+	return tx.repo.list(opts), nil
+}
+
+//Count - counts the users matching the filter
+func (tx *inMemoryTx) Count(ctx context.Context, filter users.Filter) (int, error) {
+	return tx.repo.count(filter), nil
+}
+
+//Update -  updates the information of a user
+func (tx *inMemoryTx) Update(ctx context.Context, u users.User) error {
+
+	replaced, err := tx.repo.update(u)
+
+	if err == nil && replaced.ID > 0 {
+		tx.onRollback(func() { tx.repo.putLocked(replaced) })
+	}
+
+	return err
+}
+
+//Delete - deletes a user from the repository
+func (tx *inMemoryTx) Delete(ctx context.Context, userID int, version int) error {
+
+	removed, err := tx.repo.delete(userID, version)
+
+	if err == nil && removed.ID > 0 {
+		tx.onRollback(func() { tx.repo.putLocked(removed) })
+	}
+
+	return err
+}
+
+//Append - records the events to be published
+func (tx *inMemoryTx) Append(ctx context.Context, events ...users.Event) error {
+	tx.appendEvents(events)
+	return nil
+}
+
+//Pending - retrieves up to limit events not yet published in the order they were recorded
+func (tx *inMemoryTx) Pending(ctx context.Context, limit int) ([]users.Event, error) {
+	return tx.repo.pending(limit), nil
+}
+
+//MarkPublished - removes the published events from the outbox
+func (tx *inMemoryTx) MarkPublished(ctx context.Context, ids ...int64) error {
+
+	outbox := append([]users.Event{}, tx.repo.outbox...)
+	tx.repo.markPublished(ids)
+	tx.onRollback(func() { tx.repo.outbox = outbox })
+
+	return nil
+}
+
+//appendEvents adds the events to the outbox and returns them with their ids
+func (tx *inMemoryTx) appendEvents(events []users.Event) []users.Event {
+
+	size := len(tx.repo.outbox)
+	appended := tx.repo.appendEvents(events)
+	tx.onRollback(func() { tx.repo.outbox = tx.repo.outbox[:size] })
+
+	return appended
+}
+
+func (tx *inMemoryTx) onRollback(fn func()) {
+	tx.undo = append(tx.undo, fn)
+}
+
+//rollback undoes the changes in the reverse order they were made, the event ids are not reused
+func (tx *inMemoryTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+}
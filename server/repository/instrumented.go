@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+//observer is told about every operation of an InstrumentedRepository, the returned context is given to the
+//wrapped repository and done is called with the result of the operation
+type observer interface {
+	observe(ctx context.Context, operation string) (context.Context, func(err error))
+}
+
+//repositoryMetrics counts and times the operations of a repository
+type repositoryMetrics struct {
+	operations *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+}
+
+func (m *repositoryMetrics) observe(ctx context.Context, operation string) (context.Context, func(error)) {
+
+	start := time.Now()
+
+	return ctx, func(err error) {
+
+		result := "ok"
+
+		if err != nil {
+			result = "error"
+		}
+
+		m.operations.WithLabelValues(operation, result).Inc()
+		m.latency.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
+
+//InstrumentedRepository is a users Repository reporting every operation of the repository it wraps
+//to an observer, the metrics or the tracer of the operations
+type InstrumentedRepository struct {
+	repo     users.Repository
+	observer observer
+}
+
+//instrumentedOutboxRepository is the InstrumentedRepository of a repository with an outbox, its transactions
+//and outbox operations are recorded as well
+type instrumentedOutboxRepository struct {
+	*InstrumentedRepository
+	instrumentedOutbox
+	transactor users.Transactor
+}
+
+//NewInstrumentedRepository registers the metrics of the repository operations in the registerer and returns
+//the repository recording them, it is also a users.Transactor and a users.Outbox when repo is both
+func NewInstrumentedRepository(repo users.Repository, registerer prometheus.Registerer) users.Repository {
+
+	factory := promauto.With(registerer)
+
+	return instrument(repo, &repositoryMetrics{
+		operations: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "users_repository_operations_total",
+			Help: "Operations of the users repository, by result.",
+		}, []string{"operation", "result"}),
+		latency: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "users_repository_operation_seconds",
+			Help:    "Time the operations of the users repository took.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation"}),
+	})
+}
+
+//instrument wraps the repository, keeping its transactions and its outbox
+func instrument(repo users.Repository, o observer) users.Repository {
+
+	instrumented := &InstrumentedRepository{repo: repo, observer: o}
+
+	transactor, isTransactor := repo.(users.Transactor)
+	outbox, isOutbox := repo.(users.Outbox)
+
+	if isTransactor && isOutbox {
+		return &instrumentedOutboxRepository{
+			InstrumentedRepository: instrumented,
+			instrumentedOutbox:     instrumentedOutbox{outbox: outbox, observer: o},
+			transactor:             transactor,
+		}
+	}
+
+	return instrumented
+}
+
+//Add - adds a user to the repository
+func (repo *InstrumentedRepository) Add(ctx context.Context, u users.User) (int, error) {
+
+	ctx, done := repo.observer.observe(ctx, "Add")
+	id, err := repo.repo.Add(ctx, u)
+	done(err)
+
+	return id, err
+}
+
+//GetByID - retrieves a user from the repository based on the integer id
+func (repo *InstrumentedRepository) GetByID(ctx context.Context, userID int) (users.User, error) {
+
+	ctx, done := repo.observer.observe(ctx, "GetByID")
+	u, err := repo.repo.GetByID(ctx, userID)
+	done(err)
+
+	return u, err
+}
+
+//GetByEmail - retrieves a user from the repository based on the email address
+func (repo *InstrumentedRepository) GetByEmail(ctx context.Context, email string) (users.User, error) {
+
+	ctx, done := repo.observer.observe(ctx, "GetByEmail")
+	u, err := repo.repo.GetByEmail(ctx, email)
+	done(err)
+
+	return u, err
+}
+
+//GetAll - retrieves all the users from the repository
+func (repo *InstrumentedRepository) GetAll(ctx context.Context) ([]users.User, error) {
+
+	ctx, done := repo.observer.observe(ctx, "GetAll")
+	all, err := repo.repo.GetAll(ctx)
+	done(err)
+
+	return all, err
+}
+
+//List - retrieves the users matching the options ordered by id
+func (repo *InstrumentedRepository) List(ctx context.Context, opts users.ListOptions) ([]users.User, error) {
+
+	ctx, done := repo.observer.observe(ctx, "List")
+	list, err := repo.repo.List(ctx, opts)
+	done(err)
+
+	return list, err
+}
+
+//Count - counts the users matching the filter
+func (repo *InstrumentedRepository) Count(ctx context.Context, filter users.Filter) (int, error) {
+
+	ctx, done := repo.observer.observe(ctx, "Count")
+	count, err := repo.repo.Count(ctx, filter)
+	done(err)
+
+	return count, err
+}
+
+//Update -  updates the information of a user
+func (repo *InstrumentedRepository) Update(ctx context.Context, u users.User) error {
+
+	ctx, done := repo.observer.observe(ctx, "Update")
+	err := repo.repo.Update(ctx, u)
+	done(err)
+
+	return err
+}
+
+//Delete - permanently deletes a user from the repository
+func (repo *InstrumentedRepository) Delete(ctx context.Context, userID int, version int) error {
+
+	ctx, done := repo.observer.observe(ctx, "Delete")
+	err := repo.repo.Delete(ctx, userID, version)
+	done(err)
+
+	return err
+}
+
+//WithinTransaction - runs fn in a transaction of the wrapped repository, the operations made by fn are recorded too
+func (repo *instrumentedOutboxRepository) WithinTransaction(ctx context.Context, fn func(users.Repository, users.Outbox) error) error {
+
+	o := repo.InstrumentedRepository.observer
+
+	ctx, done := o.observe(ctx, "WithinTransaction")
+	err := repo.transactor.WithinTransaction(ctx, func(txRepo users.Repository, txOutbox users.Outbox) error {
+		return fn(&InstrumentedRepository{repo: txRepo, observer: o}, instrumentedOutbox{outbox: txOutbox, observer: o})
+	})
+	done(err)
+
+	return err
+}
+
+//instrumentedOutbox is a users Outbox reporting its operations to an observer
+type instrumentedOutbox struct {
+	outbox   users.Outbox
+	observer observer
+}
+
+//Append - records the events to be published
+func (o instrumentedOutbox) Append(ctx context.Context, events ...users.Event) error {
+
+	ctx, done := o.observer.observe(ctx, "Append")
+	err := o.outbox.Append(ctx, events...)
+	done(err)
+
+	return err
+}
+
+//Pending - retrieves up to limit events not yet published
+func (o instrumentedOutbox) Pending(ctx context.Context, limit int) ([]users.Event, error) {
+
+	ctx, done := o.observer.observe(ctx, "Pending")
+	events, err := o.outbox.Pending(ctx, limit)
+	done(err)
+
+	return events, err
+}
+
+//MarkPublished - removes the published events from the outbox
+func (o instrumentedOutbox) MarkPublished(ctx context.Context, ids ...int64) error {
+
+	ctx, done := o.observer.observe(ctx, "MarkPublished")
+	err := o.outbox.MarkPublished(ctx, ids...)
+	done(err)
+
+	return err
+}
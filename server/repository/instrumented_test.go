@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/casmelad/bootcamp-gateway/server/repository/repositorytest"
+	"github.com/casmelad/bootcamp-gateway/users"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_InstrumentedRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func() users.Repository {
+		return NewInstrumentedRepository(NewInMemoryUserRepository(), prometheus.NewRegistry())
+	})
+}
+
+func Test_InstrumentedRepository_OutboxConformance(t *testing.T) {
+	repositorytest.RunOutbox(t, func() repositorytest.OutboxRepository {
+		return NewInstrumentedRepository(NewInMemoryUserRepository(), prometheus.NewRegistry()).(repositorytest.OutboxRepository)
+	})
+}
+
+//scrape returns the metrics of the registry in the text exposition format
+func scrape(t *testing.T, registry *prometheus.Registry) string {
+
+	recorder := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorHandling: promhttp.PanicOnError}).
+		ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	return recorder.Body.String()
+}
+
+func Test_InstrumentedRepository_RecordsOperations(t *testing.T) {
+	//Arrange
+	registry := prometheus.NewRegistry()
+	repository := NewInstrumentedRepository(NewInMemoryUserRepository(), registry)
+	ctx := context.Background()
+	id, _ := repository.Add(ctx, users.User{Email: "test@gmail.com"})
+	//Act
+	repository.GetByID(ctx, id)
+	repository.Delete(ctx, id, 5)
+	repository.(users.Transactor).WithinTransaction(ctx, func(repo users.Repository, outbox users.Outbox) error {
+		_, err := repo.GetByEmail(ctx, "test@gmail.com")
+		return err
+	})
+	//Assert
+	out := scrape(t, registry)
+	assert.Contains(t, out, "# TYPE users_repository_operations_total counter")
+	assert.Contains(t, out, "# TYPE users_repository_operation_seconds histogram")
+	assert.Contains(t, out, `users_repository_operations_total{operation="Add",result="ok"} 1`)
+	assert.Contains(t, out, `users_repository_operations_total{operation="Delete",result="error"} 1`)
+	assert.Contains(t, out, `users_repository_operations_total{operation="GetByEmail",result="ok"} 1`)
+	assert.Contains(t, out, `users_repository_operations_total{operation="WithinTransaction",result="ok"} 1`)
+	assert.Contains(t, out, `users_repository_operation_seconds_count{operation="GetByID"} 1`)
+}
+
+func Test_NewInstrumentedRepository_KeepsTheOutboxOnlyWhenWrapped(t *testing.T) {
+	//Arrange
+	plain := struct{ users.Repository }{NewInMemoryUserRepository()}
+	//Act
+	_, isOutbox := NewInstrumentedRepository(plain, prometheus.NewRegistry()).(users.Outbox)
+	//Assert
+	assert.False(t, isOutbox)
+}
+
+func Test_TracedRepository_RecordsChildSpans(t *testing.T) {
+	//Arrange
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	repository := NewTracedRepository(NewInMemoryUserRepository(), provider)
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	//Act
+	repository.(users.Transactor).WithinTransaction(ctx, func(repo users.Repository, outbox users.Outbox) error {
+		_, err := repo.Add(ctx, users.User{Email: "test@gmail.com"})
+		return err
+	})
+	repository.Delete(ctx, 1, 5)
+	//Assert
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "Repository.WithinTransaction", spans[1].Name())
+	assert.Equal(t, "Repository.Add", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[1].Parent().SpanID())
+	assert.Equal(t, parent.SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+	assert.Equal(t, users.ErrVersionMismatch.Error(), spans[2].Status().Description)
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+//migration is a versioned schema change applied once per database
+type migration struct {
+	version    int
+	statements []string
+}
+
+//migrate applies the pending migrations in order, each one in its own transaction
+func migrate(ctx context.Context, db *sql.DB, migrations []migration) error {
+
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if err := applyMigration(ctx, db, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, m migration) error {
+
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var applied int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = $1`, m.version).Scan(&applied); err != nil {
+		return err
+	}
+
+	if applied > 0 {
+		return nil
+	}
+
+	for _, stmt := range m.statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+//pgUniqueViolation is the SQLSTATE raised when a unique index is violated
+const pgUniqueViolation = "23505"
+
+var postgresMigrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE SEQUENCE IF NOT EXISTS users_id_seq`,
+			`CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY DEFAULT nextval('users_id_seq'),
+				email TEXT NOT NULL,
+				name TEXT NOT NULL,
+				last_name TEXT NOT NULL
+			)`,
+			`ALTER SEQUENCE users_id_seq OWNED BY users.id`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS users_email_idx ON users (email)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now()`,
+			`CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at)`,
+		},
+	},
+	{
+		version: 3,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ NULL`,
+		},
+	},
+	{
+		version: 5,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now()`,
+			`UPDATE users SET updated_at = created_at`,
+			`ALTER TABLE users ADD COLUMN IF NOT EXISTS created_by TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_by TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 6,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS outbox (
+				id BIGSERIAL PRIMARY KEY,
+				payload TEXT NOT NULL
+			)`,
+		},
+	},
+}
+
+//PostgresUserRepository is a PostgreSQL implementation of user Repository
+type PostgresUserRepository struct {
+	*sqlUserRepository
+}
+
+//NewPostgresUserRepository runs the schema migrations and returns a PostgresUserRepository type pointer
+func NewPostgresUserRepository(ctx context.Context, db *sql.DB) (*PostgresUserRepository, error) {
+
+	if err := migrate(ctx, db, postgresMigrations); err != nil {
+		return nil, err
+	}
+
+	return &PostgresUserRepository{newSQLUserRepository(db, isPostgresUniqueViolation)}, nil
+}
+
+func isPostgresUniqueViolation(err error) bool {
+
+	var pqErr *pq.Error
+
+	return errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/casmelad/bootcamp-gateway/server/repository/repositorytest"
+	"github.com/casmelad/bootcamp-gateway/users"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//newPostgresTestRepository connects to the database in POSTGRES_DSN, the test is skipped when no database
+//is configured unless the tests are built with the postgres tag, as the CI does, then it fails
+func newPostgresTestRepository(t *testing.T) *PostgresUserRepository {
+
+	dsn := os.Getenv("POSTGRES_DSN")
+
+	if dsn == "" && postgresRequired {
+		t.Fatal("POSTGRES_DSN is not set, it is required by the postgres build tag")
+	}
+
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	repository, err := NewPostgresUserRepository(context.Background(), db)
+	require.NoError(t, err)
+
+	return repository
+}
+
+func Test_PostgresUserRepository_Conformance(t *testing.T) {
+	repository := newPostgresTestRepository(t)
+
+	repositorytest.Run(t, func() users.Repository {
+		if _, err := repository.pool.Exec(`TRUNCATE users, outbox RESTART IDENTITY`); err != nil {
+			t.Fatal(err)
+		}
+		return repository
+	})
+}
+
+func Test_PostgresUserRepository_OutboxConformance(t *testing.T) {
+	repository := newPostgresTestRepository(t)
+
+	repositorytest.RunOutbox(t, func() repositorytest.OutboxRepository {
+		if _, err := repository.pool.Exec(`TRUNCATE users, outbox RESTART IDENTITY`); err != nil {
+			t.Fatal(err)
+		}
+		return repository
+	})
+}
+
+func Test_Postgres_MigrateAgain_KeepsData(t *testing.T) {
+	//Arrange
+	repository := newPostgresTestRepository(t)
+	ctx := context.Background()
+	_, err := repository.pool.Exec(`TRUNCATE users, outbox RESTART IDENTITY`)
+	require.NoError(t, err)
+	userID, _ := repository.Add(ctx, users.User{Email: "test@gmail.com", Name: "Test1", LastName: "LastName1"})
+	//Act
+	migrated, err := NewPostgresUserRepository(ctx, repository.pool)
+	require.NoError(t, err)
+	result, errGet := migrated.GetByEmail(ctx, "test@gmail.com")
+	newID, _ := migrated.Add(ctx, users.User{Email: "test2@gmail.com"})
+	//Assert
+	var applied int
+	require.NoError(t, repository.pool.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied))
+	assert.Equal(t, len(postgresMigrations), applied)
+	assert.Nil(t, errGet)
+	assert.Equal(t, userID, result.ID)
+	assert.Equal(t, userID+1, newID)
+}
+
+func Test_Postgres_Ping(t *testing.T) {
+	//Arrange
+	repository := newPostgresTestRepository(t)
+	//Act
+	err := repository.Ping(context.Background())
+	//Assert
+	assert.Nil(t, err)
+}
+
+func Test_IsPostgresUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unique violation", &pq.Error{Code: pgUniqueViolation}, true},
+		{"wrapped unique violation", fmt.Errorf("adding: %w", &pq.Error{Code: pgUniqueViolation}), true},
+		{"other constraint", &pq.Error{Code: "23503"}, false},
+		{"not a postgres error", errors.New("23505"), false},
+		{"no error", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//Act
+			got := isPostgresUniqueViolation(tt.err)
+			//Assert
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
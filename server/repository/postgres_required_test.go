@@ -0,0 +1,7 @@
+//go:build postgres
+// +build postgres
+
+package repository
+
+//postgresRequired makes the PostgreSQL tests fail instead of being skipped when no database is configured
+const postgresRequired = true
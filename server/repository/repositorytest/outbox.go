@@ -0,0 +1,186 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//OutboxRepository is a repository storing its events in an outbox written atomically with the users
+type OutboxRepository interface {
+	users.Repository
+	users.Transactor
+	users.Outbox
+}
+
+//errAbort makes a transaction fail on purpose
+var errAbort = errors.New("abort")
+
+//RunOutbox executes the outbox conformance suite, factory must return an empty repository on every call
+func RunOutbox(t *testing.T, factory func() OutboxRepository) {
+
+	tests := []struct {
+		name string
+		test func(*testing.T, OutboxRepository)
+	}{
+		{"Pending_Empty_ReturnsEmptySlice", testPendingEmpty},
+		{"Append_AssignsIncreasingIds", testAppendAssignsIDs},
+		{"Pending_Limit_ReturnsOldestEvents", testPendingLimit},
+		{"MarkPublished_RemovesEvents", testMarkPublished},
+		{"Transaction_Commit_StoresUserAndEvent", testTransactionCommit},
+		{"Transaction_Failure_DiscardsEveryWrite", testTransactionFailure},
+		{"Transaction_StaleVersion_ReturnsVersionMismatch", testTransactionStaleVersion},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, factory())
+		})
+	}
+}
+
+func newEvent(eventType string, userID int) users.Event {
+	return users.Event{Type: eventType, UserID: userID, User: users.User{ID: userID}}
+}
+
+//eventTypes returns the types of the events in order
+func eventTypes(events []users.Event) []string {
+
+	types := make([]string, 0, len(events))
+
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+
+	return types
+}
+
+func testPendingEmpty(t *testing.T, repo OutboxRepository) {
+	result, err := repo.Pending(context.Background(), 10)
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+}
+
+func testAppendAssignsIDs(t *testing.T, repo OutboxRepository) {
+	ctx := context.Background()
+
+	require.NoError(t, repo.Append(ctx, newEvent(users.EventUserCreated, 1), newEvent(users.EventUserUpdated, 1)))
+	require.NoError(t, repo.Append(ctx, newEvent(users.EventUserDeleted, 1)))
+
+	result, err := repo.Pending(ctx, 10)
+	assert.Nil(t, err)
+	require.Len(t, result, 3)
+	assert.Equal(t, []string{users.EventUserCreated, users.EventUserUpdated, users.EventUserDeleted}, eventTypes(result))
+	assert.True(t, result[0].ID > 0)
+	assert.True(t, result[0].ID < result[1].ID && result[1].ID < result[2].ID)
+	assert.Equal(t, 1, result[2].User.ID)
+}
+
+func testPendingLimit(t *testing.T, repo OutboxRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Append(ctx, newEvent(users.EventUserCreated, 1), newEvent(users.EventUserCreated, 2), newEvent(users.EventUserCreated, 3)))
+
+	result, err := repo.Pending(ctx, 2)
+
+	assert.Nil(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, 1, result[0].UserID)
+	assert.Equal(t, 2, result[1].UserID)
+}
+
+func testMarkPublished(t *testing.T, repo OutboxRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.Append(ctx, newEvent(users.EventUserCreated, 1), newEvent(users.EventUserCreated, 2)))
+	pending, _ := repo.Pending(ctx, 10)
+	require.Len(t, pending, 2)
+
+	err := repo.MarkPublished(ctx, pending[0].ID)
+
+	assert.Nil(t, err)
+	result, _ := repo.Pending(ctx, 10)
+	require.Len(t, result, 1)
+	assert.Equal(t, pending[1].ID, result[0].ID)
+	require.NoError(t, repo.Append(ctx, newEvent(users.EventUserCreated, 3)))
+	result, _ = repo.Pending(ctx, 10)
+	require.Len(t, result, 2)
+	assert.True(t, result[1].ID > pending[1].ID, "published ids must not be reused")
+}
+
+func testTransactionCommit(t *testing.T, repo OutboxRepository) {
+	ctx := context.Background()
+	var userID int
+
+	err := repo.WithinTransaction(ctx, func(txRepo users.Repository, outbox users.Outbox) error {
+		id, err := txRepo.Add(ctx, newUser(1))
+		if err != nil {
+			return err
+		}
+		userID = id
+		return outbox.Append(ctx, newEvent(users.EventUserCreated, id))
+	})
+
+	assert.Nil(t, err)
+	stored, _ := repo.GetByID(ctx, userID)
+	assert.Equal(t, newUser(1).Email, stored.Email)
+	pending, _ := repo.Pending(ctx, 10)
+	require.Len(t, pending, 1)
+	assert.Equal(t, userID, pending[0].UserID)
+}
+
+func testTransactionFailure(t *testing.T, repo OutboxRepository) {
+	ctx := context.Background()
+	toUpdate := add(t, repo, newUser(1))
+	toDelete := add(t, repo, newUser(2))
+
+	err := repo.WithinTransaction(ctx, func(txRepo users.Repository, outbox users.Outbox) error {
+		if _, err := txRepo.Add(ctx, newUser(3)); err != nil {
+			return err
+		}
+		changes := toUpdate
+		changes.Email = "changed@gmail.com"
+		if err := txRepo.Update(ctx, changes); err != nil {
+			return err
+		}
+		if err := txRepo.Delete(ctx, toDelete.ID, 0); err != nil {
+			return err
+		}
+		if err := outbox.Append(ctx, newEvent(users.EventUserUpdated, toUpdate.ID)); err != nil {
+			return err
+		}
+		return errAbort
+	})
+
+	assert.Equal(t, errAbort, err)
+	added, _ := repo.GetByEmail(ctx, newUser(3).Email)
+	assert.Zero(t, added.ID)
+	updated, _ := repo.GetByID(ctx, toUpdate.ID)
+	assert.Equal(t, toUpdate, updated)
+	renamed, _ := repo.GetByEmail(ctx, "changed@gmail.com")
+	assert.Zero(t, renamed.ID)
+	deleted, _ := repo.GetByID(ctx, toDelete.ID)
+	assert.Equal(t, toDelete, deleted)
+	pending, _ := repo.Pending(ctx, 10)
+	assert.Empty(t, pending)
+}
+
+func testTransactionStaleVersion(t *testing.T, repo OutboxRepository) {
+	ctx := context.Background()
+	original := add(t, repo, newUser(1))
+	require.NoError(t, repo.Update(ctx, original))
+
+	err := repo.WithinTransaction(ctx, func(txRepo users.Repository, outbox users.Outbox) error {
+		if err := txRepo.Update(ctx, original); err != nil {
+			return err
+		}
+		return outbox.Append(ctx, newEvent(users.EventUserUpdated, original.ID))
+	})
+
+	assert.Equal(t, users.ErrVersionMismatch, err)
+	pending, _ := repo.Pending(ctx, 10)
+	assert.Empty(t, pending)
+}
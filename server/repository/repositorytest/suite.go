@@ -0,0 +1,703 @@
+//Package repositorytest provides the conformance suite every users.Repository implementation must pass
+package repositorytest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//concurrency is the number of goroutines used by the concurrent test cases
+const concurrency = 50
+
+//Run executes the conformance suite, factory must return an empty repository on every call
+func Run(t *testing.T, factory func() users.Repository) {
+
+	tests := []struct {
+		name string
+		test func(*testing.T, users.Repository)
+	}{
+		{"Add_ValidData_ReturnsNewId", testAddValidData},
+		{"Add_IgnoresGivenId", testAddIgnoresGivenID},
+		{"Add_DuplicatedEmail_ReturnsZero", testAddDuplicatedEmail},
+		{"Add_AfterDelete_DoesNotReuseId", testAddAfterDelete},
+		{"GetByID_ReturnsExistingData", testGetByID},
+		{"GetByID_Missing_ReturnsZeroUser", testGetByIDMissing},
+		{"GetByEmail_ReturnsExistingData", testGetByEmail},
+		{"GetByEmail_Missing_ReturnsZeroUser", testGetByEmailMissing},
+		{"GetAll_Empty_ReturnsEmptySlice", testGetAllEmpty},
+		{"GetAll_ReturnsEveryUser", testGetAll},
+		{"Add_KeepsAuditFields", testAddKeepsAuditFields},
+		{"List_OrdersById", testListOrdersByID},
+		{"List_AfterIdAndLimit_Paginates", testListPaginates},
+		{"List_EmailPrefix_Filters", testListEmailPrefix},
+		{"List_NameContains_Filters", testListNameContains},
+		{"List_CreatedAfter_Filters", testListCreatedAfter},
+		{"List_CombinedFilters", testListCombinedFilters},
+		{"List_HidesSoftDeleted", testListHidesSoftDeleted},
+		{"List_DeletedBefore", testListDeletedBefore},
+		{"Count_Empty_ReturnsZero", testCountEmpty},
+		{"Count_Filters", testCountFilters},
+		{"Update_ChangesUserData", testUpdate},
+		{"Update_ChangesEmail_RekeysUser", testUpdateEmail},
+		{"Update_EmailOfAnotherUser_ReturnsAlreadyExists", testUpdateEmailCollision},
+		{"Update_Missing_DoesNothing", testUpdateMissing},
+		{"Update_IncrementsVersion", testUpdateIncrementsVersion},
+		{"Update_KeepsCreationAudit", testUpdateKeepsCreationAudit},
+		{"Update_SoftDeletesAndRestores", testUpdateSoftDeletesAndRestores},
+		{"Update_StaleVersion_ReturnsVersionMismatch", testUpdateStaleVersion},
+		{"Delete_RemovesUser", testDelete},
+		{"Delete_Missing_DoesNothing", testDeleteMissing},
+		{"Delete_StaleVersion_ReturnsVersionMismatch", testDeleteStaleVersion},
+		{"Delete_CurrentVersion_RemovesUser", testDeleteCurrentVersion},
+		{"Concurrent_Add_AssignsUniqueIds", testConcurrentAdd},
+		{"Concurrent_AddSameEmail_StoresOnce", testConcurrentAddSameEmail},
+		{"Concurrent_ReadsAndWrites", testConcurrentReadsAndWrites},
+		{"Concurrent_UpdateSameVersion_OneWins", testConcurrentUpdateSameVersion},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, factory())
+		})
+	}
+}
+
+func newUser(n int) users.User {
+	return users.User{
+		Email:    fmt.Sprintf("user%d@gmail.com", n),
+		Name:     fmt.Sprintf("Name%d", n),
+		LastName: fmt.Sprintf("LastName%d", n),
+	}
+}
+
+func add(t *testing.T, repo users.Repository, u users.User) users.User {
+	id, err := repo.Add(context.Background(), u)
+	require.NoError(t, err)
+	require.Greater(t, id, 0)
+	u.ID = id
+	u.Version = 1
+	return u
+}
+
+func testAddValidData(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+
+	first, err := repo.Add(ctx, newUser(1))
+	assert.Nil(t, err)
+	second, err := repo.Add(ctx, newUser(2))
+	assert.Nil(t, err)
+
+	assert.Greater(t, first, 0)
+	assert.Greater(t, second, first)
+}
+
+func testAddIgnoresGivenID(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	u := newUser(1)
+	u.ID = 999
+
+	id, err := repo.Add(ctx, u)
+
+	assert.Nil(t, err)
+	assert.NotEqual(t, 999, id)
+	stored, _ := repo.GetByEmail(ctx, u.Email)
+	assert.Equal(t, id, stored.ID)
+}
+
+func testAddDuplicatedEmail(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	original := add(t, repo, newUser(1))
+	duplicated := newUser(2)
+	duplicated.Email = original.Email
+
+	id, err := repo.Add(ctx, duplicated)
+
+	assert.Equal(t, 0, id)
+	assert.Nil(t, err)
+	stored, _ := repo.GetByEmail(ctx, original.Email)
+	assert.Equal(t, original, stored)
+}
+
+func testAddAfterDelete(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	add(t, repo, newUser(1))
+	deleted := add(t, repo, newUser(2))
+	require.NoError(t, repo.Delete(ctx, deleted.ID, 0))
+
+	id, err := repo.Add(ctx, newUser(3))
+
+	assert.Nil(t, err)
+	assert.Greater(t, id, deleted.ID)
+}
+
+func testGetByID(t *testing.T, repo users.Repository) {
+	expected := add(t, repo, newUser(1))
+	add(t, repo, newUser(2))
+
+	result, err := repo.GetByID(context.Background(), expected.ID)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, result)
+}
+
+func testGetByIDMissing(t *testing.T, repo users.Repository) {
+	add(t, repo, newUser(1))
+
+	result, err := repo.GetByID(context.Background(), 999)
+
+	assert.Nil(t, err)
+	assert.Equal(t, users.User{}, result)
+}
+
+func testGetByEmail(t *testing.T, repo users.Repository) {
+	expected := add(t, repo, newUser(1))
+	add(t, repo, newUser(2))
+
+	result, err := repo.GetByEmail(context.Background(), expected.Email)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, result)
+}
+
+func testGetByEmailMissing(t *testing.T, repo users.Repository) {
+	add(t, repo, newUser(1))
+
+	result, err := repo.GetByEmail(context.Background(), "missing@gmail.com")
+
+	assert.Nil(t, err)
+	assert.Equal(t, users.User{}, result)
+}
+
+func testGetAllEmpty(t *testing.T, repo users.Repository) {
+	result, err := repo.GetAll(context.Background())
+
+	assert.Nil(t, err)
+	assert.NotNil(t, result)
+	assert.Empty(t, result)
+}
+
+func testGetAll(t *testing.T, repo users.Repository) {
+	expected := []users.User{add(t, repo, newUser(1)), add(t, repo, newUser(2)), add(t, repo, newUser(3))}
+
+	result, err := repo.GetAll(context.Background())
+
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, expected, result)
+}
+
+func testAddKeepsAuditFields(t *testing.T, repo users.Repository) {
+	u := newUser(1)
+	u.CreatedAt = time.Date(2021, 12, 1, 10, 30, 0, 123456000, time.UTC)
+	u.UpdatedAt = u.CreatedAt
+	u.CreatedBy = "admin@gmail.com"
+	u.UpdatedBy = "admin@gmail.com"
+	expected := add(t, repo, u)
+
+	result, err := repo.GetByID(context.Background(), expected.ID)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, result)
+}
+
+func testListOrdersByID(t *testing.T, repo users.Repository) {
+	expected := make([]users.User, 5)
+	for i := range expected {
+		expected[i] = add(t, repo, newUser(i))
+	}
+
+	result, err := repo.List(context.Background(), users.ListOptions{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, result)
+}
+
+func testListPaginates(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	all := make([]users.User, 5)
+	for i := range all {
+		all[i] = add(t, repo, newUser(i))
+	}
+
+	first, err := repo.List(ctx, users.ListOptions{Limit: 2})
+	assert.Nil(t, err)
+	second, _ := repo.List(ctx, users.ListOptions{AfterID: first[len(first)-1].ID, Limit: 2})
+	third, _ := repo.List(ctx, users.ListOptions{AfterID: second[len(second)-1].ID, Limit: 2})
+	empty, _ := repo.List(ctx, users.ListOptions{AfterID: all[4].ID, Limit: 2})
+
+	assert.Equal(t, all[0:2], first)
+	assert.Equal(t, all[2:4], second)
+	assert.Equal(t, all[4:], third)
+	assert.NotNil(t, empty)
+	assert.Empty(t, empty)
+}
+
+func testListEmailPrefix(t *testing.T, repo users.Repository) {
+	matching := add(t, repo, users.User{Email: "John.Doe@gmail.com", Name: "John", LastName: "Doe"})
+	add(t, repo, users.User{Email: "jane@gmail.com", Name: "Jane", LastName: "Doe"})
+	add(t, repo, users.User{Email: "johnxdoe@gmail.com", Name: "John", LastName: "X"})
+
+	result, err := repo.List(context.Background(), users.ListOptions{Filter: users.Filter{EmailPrefix: "john.d"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []users.User{matching}, result)
+
+	wildcard, _ := repo.List(context.Background(), users.ListOptions{Filter: users.Filter{EmailPrefix: "%"}})
+	assert.Empty(t, wildcard)
+}
+
+func testListNameContains(t *testing.T, repo users.Repository) {
+	byName := add(t, repo, users.User{Email: "a@gmail.com", Name: "Maria", LastName: "Lopez"})
+	byLastName := add(t, repo, users.User{Email: "b@gmail.com", Name: "John", LastName: "Marin"})
+	add(t, repo, users.User{Email: "c@gmail.com", Name: "John", LastName: "Doe"})
+
+	result, err := repo.List(context.Background(), users.ListOptions{Filter: users.Filter{NameContains: "MAR"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []users.User{byName, byLastName}, result)
+}
+
+func testListCreatedAfter(t *testing.T, repo users.Repository) {
+	since := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	old := newUser(1)
+	old.CreatedAt = since.Add(-time.Hour)
+	recent := newUser(2)
+	recent.CreatedAt = since.Add(time.Microsecond)
+	add(t, repo, old)
+	expected := add(t, repo, recent)
+
+	result, err := repo.List(context.Background(), users.ListOptions{Filter: users.Filter{CreatedAfter: since}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []users.User{expected}, result)
+}
+
+func testListHidesSoftDeleted(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	active := add(t, repo, newUser(1))
+	deleted := add(t, repo, newUser(2))
+	deleted.DeletedAt = time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.Update(ctx, deleted))
+	deleted.Version++
+
+	hidden, err := repo.List(ctx, users.ListOptions{})
+	shown, _ := repo.List(ctx, users.ListOptions{Filter: users.Filter{ShowDeleted: true}})
+	count, _ := repo.Count(ctx, users.Filter{})
+	countAll, _ := repo.Count(ctx, users.Filter{ShowDeleted: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []users.User{active}, hidden)
+	assert.Equal(t, []users.User{active, deleted}, shown)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 2, countAll)
+}
+
+func testListDeletedBefore(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	cutoff := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	add(t, repo, newUser(1))
+	expired := add(t, repo, newUser(2))
+	expired.DeletedAt = cutoff.Add(-time.Microsecond)
+	require.NoError(t, repo.Update(ctx, expired))
+	expired.Version++
+	recent := add(t, repo, newUser(3))
+	recent.DeletedAt = cutoff
+	require.NoError(t, repo.Update(ctx, recent))
+
+	result, err := repo.List(ctx, users.ListOptions{Filter: users.Filter{ShowDeleted: true, DeletedBefore: cutoff}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []users.User{expired}, result)
+}
+
+func testListCombinedFilters(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	var matching []users.User
+	for i := 0; i < 6; i++ {
+		u := newUser(i)
+		if i%2 == 0 {
+			u.Email = fmt.Sprintf("team%d@gmail.com", i)
+		}
+		u = add(t, repo, u)
+		if i%2 == 0 {
+			matching = append(matching, u)
+		}
+	}
+	filter := users.Filter{EmailPrefix: "team", NameContains: "lastname"}
+
+	first, err := repo.List(ctx, users.ListOptions{Limit: 2, Filter: filter})
+	second, _ := repo.List(ctx, users.ListOptions{AfterID: first[1].ID, Limit: 2, Filter: filter})
+
+	assert.Nil(t, err)
+	assert.Equal(t, matching[:2], first)
+	assert.Equal(t, matching[2:], second)
+}
+
+func testCountEmpty(t *testing.T, repo users.Repository) {
+	result, err := repo.Count(context.Background(), users.Filter{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, result)
+}
+
+func testCountFilters(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		u := newUser(i)
+		u.CreatedAt = time.Date(2021, 12, i+1, 0, 0, 0, 0, time.UTC)
+		add(t, repo, u)
+	}
+	add(t, repo, users.User{Email: "other@gmail.com", Name: "Other", LastName: "Person"})
+
+	all, errAll := repo.Count(ctx, users.Filter{})
+	byEmail, errEmail := repo.Count(ctx, users.Filter{EmailPrefix: "user"})
+	byName, _ := repo.Count(ctx, users.Filter{NameContains: "person"})
+	byDate, _ := repo.Count(ctx, users.Filter{EmailPrefix: "user", CreatedAfter: time.Date(2021, 12, 3, 0, 0, 0, 0, time.UTC)})
+
+	assert.Nil(t, errAll)
+	assert.Nil(t, errEmail)
+	assert.Equal(t, 6, all)
+	assert.Equal(t, 5, byEmail)
+	assert.Equal(t, 1, byName)
+	assert.Equal(t, 2, byDate)
+}
+
+func testUpdate(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	original := add(t, repo, newUser(1))
+	other := add(t, repo, newUser(2))
+	changes := users.User{ID: original.ID, Email: original.Email, Name: "Name1_Updated", LastName: "LastName1_Updated", CreatedAt: time.Now()}
+
+	err := repo.Update(ctx, changes)
+
+	assert.Nil(t, err)
+	updated, _ := repo.GetByID(ctx, original.ID)
+	assert.Equal(t, users.User{ID: original.ID, Email: original.Email, Name: changes.Name, LastName: changes.LastName, Version: 2}, updated)
+	untouched, _ := repo.GetByID(ctx, other.ID)
+	assert.Equal(t, other, untouched)
+}
+
+func testUpdateEmail(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	original := add(t, repo, newUser(1))
+	changes := original
+	changes.Email = "changed@gmail.com"
+
+	err := repo.Update(ctx, changes)
+	changes.Version++
+
+	assert.Nil(t, err)
+	byID, _ := repo.GetByID(ctx, original.ID)
+	assert.Equal(t, changes, byID)
+	byEmail, _ := repo.GetByEmail(ctx, changes.Email)
+	assert.Equal(t, changes, byEmail)
+	previous, _ := repo.GetByEmail(ctx, original.Email)
+	assert.Equal(t, users.User{}, previous)
+	all, _ := repo.GetAll(ctx)
+	assert.Equal(t, []users.User{changes}, all)
+
+	reused := add(t, repo, newUser(1))
+	assert.NotEqual(t, original.ID, reused.ID)
+}
+
+func testUpdateEmailCollision(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	original := add(t, repo, newUser(1))
+	other := add(t, repo, newUser(2))
+	changes := original
+	changes.Email = other.Email
+	changes.Name = "Name1_Updated"
+
+	err := repo.Update(ctx, changes)
+
+	assert.Equal(t, users.ErrUserAlreadyExists, err)
+	unchanged, _ := repo.GetByID(ctx, original.ID)
+	assert.Equal(t, original, unchanged)
+	owner, _ := repo.GetByEmail(ctx, other.Email)
+	assert.Equal(t, other, owner)
+}
+
+func testUpdateMissing(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	existing := add(t, repo, newUser(1))
+	changes := newUser(2)
+	changes.ID = 999
+
+	err := repo.Update(ctx, changes)
+
+	assert.Nil(t, err)
+	all, _ := repo.GetAll(ctx)
+	assert.Equal(t, []users.User{existing}, all)
+}
+
+func testUpdateIncrementsVersion(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	original := add(t, repo, newUser(1))
+	changes := original
+
+	for want := 2; want <= 3; want++ {
+		changes.Name = fmt.Sprintf("Name1_%d", want)
+
+		err := repo.Update(ctx, changes)
+
+		assert.Nil(t, err)
+		updated, _ := repo.GetByID(ctx, original.ID)
+		assert.Equal(t, want, updated.Version)
+		changes.Version = updated.Version
+	}
+}
+
+func testUpdateKeepsCreationAudit(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	u := newUser(1)
+	u.CreatedAt = time.Date(2021, 12, 1, 10, 30, 0, 0, time.UTC)
+	u.CreatedBy = "owner@gmail.com"
+	original := add(t, repo, u)
+	changes := original
+	changes.CreatedAt = time.Date(2021, 12, 5, 0, 0, 0, 0, time.UTC)
+	changes.CreatedBy = "someone@gmail.com"
+	changes.UpdatedAt = time.Date(2021, 12, 2, 8, 0, 0, 654321000, time.UTC)
+	changes.UpdatedBy = "admin@gmail.com"
+
+	err := repo.Update(ctx, changes)
+
+	assert.Nil(t, err)
+	stored, _ := repo.GetByID(ctx, original.ID)
+	assert.Equal(t, original.CreatedAt, stored.CreatedAt)
+	assert.Equal(t, original.CreatedBy, stored.CreatedBy)
+	assert.Equal(t, changes.UpdatedAt, stored.UpdatedAt)
+	assert.Equal(t, changes.UpdatedBy, stored.UpdatedBy)
+}
+
+func testUpdateSoftDeletesAndRestores(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	original := add(t, repo, newUser(1))
+	deleted := original
+	deleted.DeletedAt = time.Date(2021, 12, 1, 10, 30, 0, 123456000, time.UTC)
+
+	err := repo.Update(ctx, deleted)
+
+	assert.Nil(t, err)
+	stored, _ := repo.GetByID(ctx, original.ID)
+	assert.Equal(t, deleted.DeletedAt, stored.DeletedAt)
+	byEmail, _ := repo.GetByEmail(ctx, original.Email)
+	assert.Equal(t, stored, byEmail)
+
+	restored := stored
+	restored.DeletedAt = time.Time{}
+	require.NoError(t, repo.Update(ctx, restored))
+	stored, _ = repo.GetByID(ctx, original.ID)
+	assert.False(t, stored.IsDeleted())
+	assert.Equal(t, 3, stored.Version)
+}
+
+func testUpdateStaleVersion(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	original := add(t, repo, newUser(1))
+	first := original
+	first.Name = "Name1_First"
+	require.NoError(t, repo.Update(ctx, first))
+	second := original
+	second.Name = "Name1_Second"
+
+	err := repo.Update(ctx, second)
+
+	assert.Equal(t, users.ErrVersionMismatch, err)
+	stored, _ := repo.GetByID(ctx, original.ID)
+	assert.Equal(t, "Name1_First", stored.Name)
+	assert.Equal(t, 2, stored.Version)
+}
+
+func testDeleteStaleVersion(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	original := add(t, repo, newUser(1))
+	require.NoError(t, repo.Update(ctx, original))
+
+	err := repo.Delete(ctx, original.ID, original.Version)
+
+	assert.Equal(t, users.ErrVersionMismatch, err)
+	stored, _ := repo.GetByID(ctx, original.ID)
+	assert.Equal(t, original.ID, stored.ID)
+}
+
+func testDeleteCurrentVersion(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	original := add(t, repo, newUser(1))
+
+	err := repo.Delete(ctx, original.ID, original.Version)
+
+	assert.Nil(t, err)
+	stored, _ := repo.GetByID(ctx, original.ID)
+	assert.Equal(t, users.User{}, stored)
+}
+
+func testDelete(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	deleted := add(t, repo, newUser(1))
+	remaining := add(t, repo, newUser(2))
+
+	err := repo.Delete(ctx, deleted.ID, 0)
+
+	assert.Nil(t, err)
+	byID, _ := repo.GetByID(ctx, deleted.ID)
+	assert.Equal(t, users.User{}, byID)
+	byEmail, _ := repo.GetByEmail(ctx, deleted.Email)
+	assert.Equal(t, users.User{}, byEmail)
+	all, _ := repo.GetAll(ctx)
+	assert.Equal(t, []users.User{remaining}, all)
+}
+
+func testDeleteMissing(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	existing := add(t, repo, newUser(1))
+
+	err := repo.Delete(ctx, 999, 0)
+
+	assert.Nil(t, err)
+	all, _ := repo.GetAll(ctx)
+	assert.Equal(t, []users.User{existing}, all)
+}
+
+func testConcurrentAdd(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	ids := make([]int, concurrency)
+	errs := make([]error, concurrency)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i], errs[i] = repo.Add(ctx, newUser(i))
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[int]bool{}
+	for i := range ids {
+		assert.Nil(t, errs[i])
+		assert.Greater(t, ids[i], 0)
+		assert.False(t, seen[ids[i]], "id %d assigned twice", ids[i])
+		seen[ids[i]] = true
+	}
+	all, _ := repo.GetAll(ctx)
+	assert.Len(t, all, concurrency)
+}
+
+func testConcurrentAddSameEmail(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	ids := make(chan int, concurrency)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := repo.Add(ctx, newUser(1))
+			assert.Nil(t, err)
+			ids <- id
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	created := 0
+	for id := range ids {
+		if id > 0 {
+			created++
+		}
+	}
+	assert.Equal(t, 1, created)
+	all, _ := repo.GetAll(ctx)
+	assert.Len(t, all, 1)
+}
+
+func testConcurrentReadsAndWrites(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	seeded := make([]users.User, concurrency)
+	for i := range seeded {
+		seeded[i] = add(t, repo, newUser(i))
+	}
+
+	deleted := map[int]bool{}
+	for i, u := range seeded {
+		if i%2 == 0 {
+			deleted[u.ID] = true
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, u := range seeded {
+		wg.Add(4)
+		go func(u users.User) {
+			defer wg.Done()
+			u.Name = u.Name + "_Updated"
+			assert.Nil(t, repo.Update(ctx, u))
+		}(u)
+		go func(u users.User) {
+			defer wg.Done()
+			_, err := repo.GetByEmail(ctx, u.Email)
+			assert.Nil(t, err)
+		}(u)
+		go func() {
+			defer wg.Done()
+			_, err := repo.GetAll(ctx)
+			assert.Nil(t, err)
+		}()
+		go func(u users.User) {
+			defer wg.Done()
+			if deleted[u.ID] {
+				assert.Nil(t, repo.Delete(ctx, u.ID, 0))
+			} else {
+				_, err := repo.GetByID(ctx, u.ID)
+				assert.Nil(t, err)
+			}
+		}(u)
+	}
+	wg.Wait()
+
+	all, err := repo.GetAll(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, all, concurrency/2)
+	for _, u := range all {
+		assert.NotContains(t, deleted, u.ID, "user %d should have been deleted", u.ID)
+	}
+}
+
+func testConcurrentUpdateSameVersion(t *testing.T, repo users.Repository) {
+	ctx := context.Background()
+	original := add(t, repo, newUser(1))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			changes := original
+			changes.Name = fmt.Sprintf("Name1_%d", i)
+			errs <- repo.Update(ctx, changes)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		} else {
+			assert.Equal(t, users.ErrVersionMismatch, err)
+		}
+	}
+	assert.Equal(t, 1, succeeded)
+	stored, _ := repo.GetByID(ctx, original.ID)
+	assert.Equal(t, 2, stored.Version)
+}
@@ -0,0 +1,364 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+)
+
+//userColumns is the column list every query reading users selects, in the order scanUser expects
+const userColumns = `id, email, name, last_name, created_at, version, deleted_at, updated_at, created_by, updated_by`
+
+//querier runs the statements, it is either the database or a transaction
+type querier interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+//sqlUserRepository holds the queries shared by the database/sql backed repositories,
+//they are written with $n placeholders understood by both PostgreSQL and SQLite
+type sqlUserRepository struct {
+	db querier
+	//pool begins the transactions, nil for a repository already bound to a transaction
+	pool *sql.DB
+	//isUniqueViolation recognizes the driver error raised when the email index is violated
+	isUniqueViolation func(error) bool
+}
+
+//newSQLUserRepository returns a sqlUserRepository running its statements on the database
+func newSQLUserRepository(db *sql.DB, isUniqueViolation func(error) bool) *sqlUserRepository {
+	return &sqlUserRepository{db: db, pool: db, isUniqueViolation: isUniqueViolation}
+}
+
+//Ping - checks that the database can be reached
+func (repo *sqlUserRepository) Ping(ctx context.Context) error {
+
+	if repo.pool == nil {
+		return nil
+	}
+
+	return repo.pool.PingContext(ctx)
+}
+
+//Add - adds a user to the repository
+func (repo *sqlUserRepository) Add(ctx context.Context, u users.User) (int, error) {
+
+	var id int
+
+	err := repo.db.QueryRowContext(ctx,
+		`INSERT INTO users (email, name, last_name, created_at, updated_at, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (email) DO NOTHING RETURNING id`,
+		u.Email, u.Name, u.LastName, u.CreatedAt.UTC(), u.UpdatedAt.UTC(), u.CreatedBy, u.UpdatedBy).Scan(&id)
+
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+//GetByID - retrieves a user from the repository based on the integer id
+func (repo *sqlUserRepository) GetByID(ctx context.Context, userID int) (users.User, error) {
+	return repo.getOne(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, userID)
+}
+
+//GetByEmail - retrieves a user from the repository based on the email address
+func (repo *sqlUserRepository) GetByEmail(ctx context.Context, email string) (users.User, error) {
+	return repo.getOne(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email)
+}
+
+//GetAll - retrieves all the users from the repository
+func (repo *sqlUserRepository) GetAll(ctx context.Context) ([]users.User, error) {
+	return repo.getMany(ctx, `SELECT `+userColumns+` FROM users ORDER BY id`)
+}
+
+//List - retrieves the users matching the options ordered by id
+func (repo *sqlUserRepository) List(ctx context.Context, opts users.ListOptions) ([]users.User, error) {
+
+	conditions, args := filterConditions(opts.Filter, []interface{}{opts.AfterID})
+	conditions = append([]string{"id > $1"}, conditions...)
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + strings.Join(conditions, " AND ") + ` ORDER BY id`
+
+	if opts.Limit > 0 {
+		args = append(args, opts.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	return repo.getMany(ctx, query, args...)
+}
+
+//Count - counts the users matching the filter
+func (repo *sqlUserRepository) Count(ctx context.Context, filter users.Filter) (int, error) {
+
+	conditions, args := filterConditions(filter, nil)
+	query := `SELECT COUNT(*) FROM users`
+
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	var count int
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(&count)
+
+	return count, err
+}
+
+//Update -  updates the information of a user, users.ErrUserAlreadyExists is returned
+//when another user has the new email
+func (repo *sqlUserRepository) Update(ctx context.Context, u users.User) error {
+
+	result, err := repo.db.ExecContext(ctx,
+		`UPDATE users SET email = $1, name = $2, last_name = $3, deleted_at = $4, updated_at = $5, updated_by = $6,
+		version = version + 1 WHERE id = $7 AND ($8 = 0 OR version = $8)`,
+		u.Email, u.Name, u.LastName, nullTime(u.DeletedAt), u.UpdatedAt.UTC(), u.UpdatedBy, u.ID, u.Version)
+
+	if err != nil && repo.isUniqueViolation(err) {
+		return users.ErrUserAlreadyExists
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return repo.checkAffected(ctx, result, u.ID)
+}
+
+//Delete - deletes a user from the repository
+func (repo *sqlUserRepository) Delete(ctx context.Context, userID int, version int) error {
+
+	result, err := repo.db.ExecContext(ctx,
+		`DELETE FROM users WHERE id = $1 AND ($2 = 0 OR version = $2)`, userID, version)
+
+	if err != nil {
+		return err
+	}
+
+	return repo.checkAffected(ctx, result, userID)
+}
+
+//WithinTransaction - runs fn with a repository and an outbox bound to a database transaction,
+//it is committed when fn returns nil and rolled back otherwise
+func (repo *sqlUserRepository) WithinTransaction(ctx context.Context, fn func(users.Repository, users.Outbox) error) error {
+
+	if repo.pool == nil {
+		return fn(repo, repo)
+	}
+
+	tx, err := repo.pool.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	txRepo := &sqlUserRepository{db: tx, isUniqueViolation: repo.isUniqueViolation}
+
+	if err := fn(txRepo, txRepo); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//Append - records the events to be published
+func (repo *sqlUserRepository) Append(ctx context.Context, events ...users.Event) error {
+
+	for _, e := range events {
+		payload, err := json.Marshal(e)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := repo.db.ExecContext(ctx, `INSERT INTO outbox (payload) VALUES ($1)`, string(payload)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Pending - retrieves up to limit events not yet published in the order they were recorded
+func (repo *sqlUserRepository) Pending(ctx context.Context, limit int) ([]users.Event, error) {
+
+	rows, err := repo.db.QueryContext(ctx, `SELECT id, payload FROM outbox ORDER BY id LIMIT $1`, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	result := []users.Event{}
+
+	for rows.Next() {
+		var id int64
+		var payload string
+
+		if err := rows.Scan(&id, &payload); err != nil {
+			return nil, err
+		}
+
+		var e users.Event
+
+		if err := json.Unmarshal([]byte(payload), &e); err != nil {
+			return nil, fmt.Errorf("reading outbox event %d: %w", id, err)
+		}
+
+		e.ID = id
+		result = append(result, e)
+	}
+
+	return result, rows.Err()
+}
+
+//MarkPublished - removes the published events from the outbox
+func (repo *sqlUserRepository) MarkPublished(ctx context.Context, ids ...int64) error {
+
+	for _, id := range ids {
+		if _, err := repo.db.ExecContext(ctx, `DELETE FROM outbox WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//checkAffected tells a missing user, which is ignored, apart from a version mismatch
+//when a conditional write changed no rows
+func (repo *sqlUserRepository) checkAffected(ctx context.Context, result sql.Result, userID int) error {
+
+	affected, err := result.RowsAffected()
+
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	stored, err := repo.GetByID(ctx, userID)
+
+	if err != nil || stored.ID == 0 {
+		return err
+	}
+
+	return users.ErrVersionMismatch
+}
+
+func (repo *sqlUserRepository) getOne(ctx context.Context, query string, args ...interface{}) (users.User, error) {
+
+	usr, err := scanUser(repo.db.QueryRowContext(ctx, query, args...))
+
+	if err == sql.ErrNoRows {
+		return users.User{}, nil
+	}
+
+	if err != nil {
+		return users.User{}, err
+	}
+
+	return usr, nil
+}
+
+func (repo *sqlUserRepository) getMany(ctx context.Context, query string, args ...interface{}) ([]users.User, error) {
+
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	result := []users.User{}
+
+	for rows.Next() {
+		usr, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, usr)
+	}
+
+	return result, rows.Err()
+}
+
+//scanUser reads a row selected with userColumns, times are normalized to UTC
+func scanUser(row interface{ Scan(...interface{}) error }) (users.User, error) {
+
+	var usr users.User
+	var deletedAt sql.NullTime
+
+	if err := row.Scan(&usr.ID, &usr.Email, &usr.Name, &usr.LastName, &usr.CreatedAt, &usr.Version, &deletedAt,
+		&usr.UpdatedAt, &usr.CreatedBy, &usr.UpdatedBy); err != nil {
+		return users.User{}, err
+	}
+
+	usr.CreatedAt = usr.CreatedAt.UTC()
+	usr.UpdatedAt = usr.UpdatedAt.UTC()
+
+	if deletedAt.Valid {
+		usr.DeletedAt = deletedAt.Time.UTC()
+	}
+
+	return usr, nil
+}
+
+//filterConditions translates the filter into SQL conditions, their placeholders are numbered
+//after the arguments already given
+func filterConditions(filter users.Filter, args []interface{}) ([]string, []interface{}) {
+
+	conditions := []string{}
+
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.EmailPrefix != "" {
+		where(`LOWER(email) LIKE $%d ESCAPE '\'`, escapeLike(strings.ToLower(filter.EmailPrefix))+"%")
+	}
+
+	if filter.NameContains != "" {
+		where(`LOWER(name || ' ' || last_name) LIKE $%d ESCAPE '\'`, "%"+escapeLike(strings.ToLower(filter.NameContains))+"%")
+	}
+
+	if !filter.CreatedAfter.IsZero() {
+		where(`created_at > $%d`, filter.CreatedAfter.UTC())
+	}
+
+	if !filter.ShowDeleted {
+		conditions = append(conditions, `deleted_at IS NULL`)
+	}
+
+	if !filter.DeletedBefore.IsZero() {
+		where(`deleted_at < $%d`, filter.DeletedBefore.UTC())
+	}
+
+	return conditions, args
+}
+
+//nullTime stores the zero time as NULL
+func nullTime(t time.Time) interface{} {
+
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.UTC()
+}
+
+//escapeLike escapes the LIKE wildcards so the value is matched literally
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var sqliteMigrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				email TEXT NOT NULL,
+				name TEXT NOT NULL,
+				last_name TEXT NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS users_email_idx ON users (email)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			//SQLite only allows constant defaults when adding a column, existing users are stamped afterwards
+			`ALTER TABLE users ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00 +0000 UTC'`,
+			`UPDATE users SET created_at = strftime('%Y-%m-%d %H:%M:%S +0000 UTC', 'now')`,
+			`CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at)`,
+		},
+	},
+	{
+		version: 3,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP NULL`,
+		},
+	},
+	{
+		version: 5,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00 +0000 UTC'`,
+			`UPDATE users SET updated_at = created_at`,
+			`ALTER TABLE users ADD COLUMN created_by TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE users ADD COLUMN updated_by TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 6,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS outbox (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				payload TEXT NOT NULL
+			)`,
+		},
+	},
+}
+
+//SQLiteUserRepository is an embedded SQLite implementation of user Repository
+type SQLiteUserRepository struct {
+	*sqlUserRepository
+}
+
+//NewSQLiteUserRepository runs the schema migrations and returns a SQLiteUserRepository type pointer,
+//the database is limited to a single connection since SQLite allows only one writer at a time
+func NewSQLiteUserRepository(ctx context.Context, db *sql.DB) (*SQLiteUserRepository, error) {
+
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.ExecContext(ctx, `PRAGMA journal_mode = WAL`); err != nil {
+		return nil, err
+	}
+
+	if err := migrate(ctx, db, sqliteMigrations); err != nil {
+		return nil, err
+	}
+
+	return &SQLiteUserRepository{newSQLUserRepository(db, isSQLiteUniqueViolation)}, nil
+}
+
+func isSQLiteUniqueViolation(err error) bool {
+
+	var sqliteErr *sqlite.Error
+
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
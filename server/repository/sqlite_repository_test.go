@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/casmelad/bootcamp-gateway/server/repository/repositorytest"
+	"github.com/casmelad/bootcamp-gateway/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+//openSQLiteTestRepository opens the SQLite database stored in path
+func openSQLiteTestRepository(t *testing.T, path string) (*SQLiteUserRepository, *sql.DB) {
+
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+
+	repository, err := NewSQLiteUserRepository(context.Background(), db)
+	require.NoError(t, err)
+
+	return repository, db
+}
+
+//newSQLiteTestRepository returns a repository backed by an empty database file
+func newSQLiteTestRepository(t *testing.T) *SQLiteUserRepository {
+
+	repository, db := openSQLiteTestRepository(t, filepath.Join(t.TempDir(), "users.db"))
+	t.Cleanup(func() { db.Close() })
+
+	return repository
+}
+
+func Test_SQLiteUserRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func() users.Repository {
+		return newSQLiteTestRepository(t)
+	})
+}
+
+func Test_SQLiteUserRepository_OutboxConformance(t *testing.T) {
+	repositorytest.RunOutbox(t, func() repositorytest.OutboxRepository {
+		return newSQLiteTestRepository(t)
+	})
+}
+
+func Test_SQLite_Reopen_KeepsData(t *testing.T) {
+	//Arrange
+	path := filepath.Join(t.TempDir(), "users.db")
+	ctx := context.Background()
+	repository, db := openSQLiteTestRepository(t, path)
+	userToAdd := users.User{Email: "test@gmail.com", Name: "Test1", LastName: "LastName1"}
+	userID, _ := repository.Add(ctx, userToAdd)
+	require.NoError(t, db.Close())
+	//Act
+	reopened, db := openSQLiteTestRepository(t, path)
+	defer db.Close()
+	result, err := reopened.GetByEmail(ctx, userToAdd.Email)
+	newID, _ := reopened.Add(ctx, users.User{Email: "test2@gmail.com"})
+	//Assert
+	userToAdd.ID = userID
+	userToAdd.Version = 1
+	assert.Equal(t, userToAdd, result)
+	assert.Nil(t, err)
+	assert.Equal(t, userID+1, newID)
+}
+
+func Test_SQLite_Ping_FailsOnceTheDatabaseIsClosed(t *testing.T) {
+	//Arrange
+	repository, db := openSQLiteTestRepository(t, filepath.Join(t.TempDir(), "users.db"))
+	ctx := context.Background()
+	//Act
+	open := repository.Ping(ctx)
+	db.Close()
+	closed := repository.Ping(ctx)
+	//Assert
+	assert.Nil(t, open)
+	assert.NotNil(t, closed)
+}
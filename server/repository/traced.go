@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//repositoryTracer records a span for every operation of a repository
+type repositoryTracer struct {
+	tracer trace.Tracer
+}
+
+func (t repositoryTracer) observe(ctx context.Context, operation string) (context.Context, func(error)) {
+
+	ctx, span := t.tracer.Start(ctx, "Repository."+operation, trace.WithSpanKind(trace.SpanKindInternal))
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+//NewTracedRepository returns the repository recording a span, child of the span of the context, for every operation,
+//it is also a users.Transactor and a users.Outbox when repo is both
+func NewTracedRepository(repo users.Repository, provider trace.TracerProvider) users.Repository {
+	return instrument(repo, repositoryTracer{tracer: provider.Tracer("github.com/casmelad/bootcamp-gateway/server/repository")})
+}
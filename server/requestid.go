@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	domain "github.com/casmelad/bootcamp-gateway/users"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+//RequestIDHeader is the metadata key carrying the id of the request, it is generated when the caller does not send one
+const RequestIDHeader = "x-request-id"
+
+//UnaryRequestIDInterceptor stores the id of the request in the context so the audit events can reference it,
+//the id is also returned in the response metadata
+func UnaryRequestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+	ctx, err := withRequestID(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+//StreamRequestIDInterceptor is the streaming counterpart of UnaryRequestIDInterceptor
+func StreamRequestIDInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+	ctx, err := withRequestID(ss.Context())
+
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+//contextStream overrides the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+//withRequestID takes the request id from the incoming metadata or generates a new one
+func withRequestID(ctx context.Context) (context.Context, error) {
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(RequestIDHeader)
+
+	var requestID string
+
+	if len(values) > 0 && values[0] != "" {
+		requestID = values[0]
+	} else {
+		requestID = newRequestID()
+	}
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, requestID)); err != nil {
+		return nil, err
+	}
+
+	return domain.WithRequestID(ctx, requestID), nil
+}
+
+//RequestIDMiddleware generates the id of the HTTP requests that do not have one, the gateway forwards it to the
+//gRPC server as metadata and returns it in the X-Request-Id header
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get(RequestIDHeader) == "" {
+			r.Header.Set(RequestIDHeader, newRequestID())
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+//newRequestID returns 16 random bytes hex encoded
+func newRequestID() string {
+
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -3,14 +3,29 @@ package server
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
 	pb "github.com/casmelad/bootcamp-gateway/server/proto"
 	domain "github.com/casmelad/bootcamp-gateway/users"
 	mappers "github.com/casmelad/bootcamp-gateway/users/mappers"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
+const (
+	//NextPageTokenHeader is the metadata key carrying the token of the following page of users
+	NextPageTokenHeader = "next-page-token"
+	//ETagHeader is the metadata key carrying the version of the returned user
+	ETagHeader = "etag"
+	//IfMatchHeader is the metadata key carrying the version the caller expects the user to have
+	IfMatchHeader = "if-match"
+	//LastEventIDHeader is the metadata key carrying the last revision received by a reconnecting watcher
+	LastEventIDHeader = "last-event-id"
+)
+
 type UserServer struct {
 	appService domain.Service
 	pb.UsersServer
@@ -25,8 +40,6 @@ func NewUserServer(s domain.Service) *UserServer {
 //Get a user by the email
 func (s UserServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
 
-	fmt.Println(req)
-
 	email := req.GetEmail()
 
 	result, err := s.appService.GetByEmail(ctx, email)
@@ -37,14 +50,16 @@ func (s UserServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.Us
 
 	mappedUser, err := mappers.ToGrpcUser(result)
 
-	return &mappedUser, nil
+	if err := grpc.SetHeader(ctx, metadata.Pairs(ETagHeader, formatETag(result.Version))); err != nil {
+		return nil, err
+	}
+
+	return mappedUser, nil
 }
 
 //Creates a nw user record
 func (s UserServer) Create(ctx context.Context, req *pb.CreateRequest) (*pb.CreateResponse, error) {
 
-	fmt.Println(req)
-
 	err := req.Validate()
 
 	if err != nil {
@@ -75,18 +90,25 @@ func (s UserServer) Create(ctx context.Context, req *pb.CreateRequest) (*pb.Crea
 	return &pb.CreateResponse{Code: pb.CodeResult_OK, UserId: int32(result)}, nil
 }
 
-//Gets all users7
-func (s UserServer) GetAllUsers(_ *pb.GetAllUsersRequest, resp pb.Users_GetAllUsersServer) error {
-	result, err := s.appService.GetAll(context.TODO())
+//Gets a page of users, the token of the next page is sent as header metadata
+func (s UserServer) GetAllUsers(req *pb.GetAllUsersRequest, resp pb.Users_GetAllUsersServer) error {
+
+	result, err := s.listUsers(resp.Context(), req)
 
 	if err != nil {
-		return status.Errorf(codes.Internal, "Internal error")
+		return err
+	}
+
+	if result.NextPageToken != "" {
+		if err := resp.SendHeader(metadata.Pairs(NextPageTokenHeader, result.NextPageToken)); err != nil {
+			return err
+		}
 	}
 
-	for _, u := range result {
+	for _, u := range result.Users {
 		usr, _ := mappers.ToGrpcUser(u)
 
-		err := resp.Send(&usr)
+		err := resp.Send(usr)
 		if err != nil {
 			return err
 		}
@@ -94,17 +116,89 @@ func (s UserServer) GetAllUsers(_ *pb.GetAllUsersRequest, resp pb.Users_GetAllUs
 	return nil
 }
 
+//Gets a page of users with the token of the next page and the total count
+func (s UserServer) ListUsers(ctx context.Context, req *pb.GetAllUsersRequest) (*pb.GetAllUsersResponse, error) {
+
+	result, err := s.listUsers(ctx, req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response := &pb.GetAllUsersResponse{
+		Users:         make([]*pb.User, 0, len(result.Users)),
+		NextPageToken: result.NextPageToken,
+		TotalSize:     int32(result.TotalSize),
+	}
+
+	for _, u := range result.Users {
+		usr, _ := mappers.ToGrpcUser(u)
+		response.Users = append(response.Users, usr)
+	}
+
+	return response, nil
+}
+
+func (s UserServer) listUsers(ctx context.Context, req *pb.GetAllUsersRequest) (domain.Page, error) {
+
+	filter, err := domain.ParseFilter(req.GetFilter())
+
+	if err != nil {
+		return domain.Page{}, status.Errorf(codes.InvalidArgument, "Invalid filter: %v", err)
+	}
+
+	result, err := s.appService.List(ctx, domain.ListRequest{
+		PageSize:  int(req.GetPageSize()),
+		PageToken: req.GetPageToken(),
+		Filter:    filter,
+	})
+
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidData:
+			return domain.Page{}, status.Errorf(codes.InvalidArgument, "Invalid page size or token")
+		}
+
+		return domain.Page{}, status.Errorf(codes.Internal, "Internal error")
+	}
+
+	return result, nil
+}
+
 //Updates the user information
 func (s UserServer) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.UpdateResponse, error) {
 
+	if req.GetUser() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid data")
+	}
+
 	usr, _ := mappers.ToDomainUser(*req.GetUser())
 
-	err := s.appService.Update(ctx, usr)
+	version, err := expectedVersion(ctx, req.GetUser().GetVersion())
+
+	if err != nil {
+		return nil, err
+	}
+
+	usr.Version = version
+
+	paths := req.GetUpdateMask().GetPaths()
+	fields := updatableFields(paths)
+
+	if len(paths) > 0 && len(fields) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid data, the update mask has no updatable field")
+	}
+
+	updated, err := s.appService.Update(ctx, usr, fields)
 
 	if err != nil {
 		switch err {
 		case domain.ErrInvalidData:
 			return nil, status.Errorf(codes.InvalidArgument, "Invalid data")
+		case domain.ErrUserAlreadyExists:
+			return nil, status.Errorf(codes.AlreadyExists, "Email already in use")
+		case domain.ErrVersionMismatch:
+			return nil, status.Errorf(codes.Aborted, "User was modified, version mismatch")
 		case domain.ErrNotFound:
 			return nil, status.Errorf(codes.InvalidArgument, "User does not exist")
 		case domain.ErrInternalError:
@@ -114,6 +208,10 @@ func (s UserServer) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.Upda
 		return nil, err
 	}
 
+	if err := grpc.SetHeader(ctx, metadata.Pairs(ETagHeader, formatETag(updated.Version))); err != nil {
+		return nil, err
+	}
+
 	return &pb.UpdateResponse{Code: pb.CodeResult_OK}, nil
 }
 
@@ -122,12 +220,20 @@ func (s UserServer) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.Dele
 
 	id := req.GetId()
 
-	err := s.appService.Delete(ctx, int(id))
+	version, err := expectedVersion(ctx, req.GetVersion())
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.appService.Delete(ctx, int(id), version)
 
 	if err != nil {
 		switch err {
 		case domain.ErrInvalidData:
 			return nil, status.Errorf(codes.InvalidArgument, "invalid id")
+		case domain.ErrVersionMismatch:
+			return nil, status.Errorf(codes.Aborted, "User was modified, version mismatch")
 		case domain.ErrNotFound:
 			return nil, status.Errorf(codes.InvalidArgument, "User does not exist")
 		case domain.ErrInternalError:
@@ -139,3 +245,235 @@ func (s UserServer) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.Dele
 
 	return &pb.DeleteResponse{Code: pb.CodeResult_OK}, nil
 }
+
+//Restores a deleted user
+func (s UserServer) Undelete(ctx context.Context, req *pb.UndeleteRequest) (*pb.UpdateResponse, error) {
+
+	version, err := expectedVersion(ctx, req.GetVersion())
+
+	if err != nil {
+		return nil, err
+	}
+
+	restored, err := s.appService.Undelete(ctx, int(req.GetId()), version)
+
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidData:
+			return nil, status.Errorf(codes.InvalidArgument, "invalid id")
+		case domain.ErrVersionMismatch:
+			return nil, status.Errorf(codes.Aborted, "User was modified, version mismatch")
+		case domain.ErrNotFound:
+			return nil, status.Errorf(codes.NotFound, "User does not exist")
+		case domain.ErrNotDeleted:
+			return nil, status.Errorf(codes.FailedPrecondition, "User is not deleted")
+		case domain.ErrInternalError:
+			return nil, status.Errorf(codes.Internal, "Internal error")
+		}
+
+		return nil, err
+	}
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs(ETagHeader, formatETag(restored.Version))); err != nil {
+		return nil, err
+	}
+
+	return &pb.UpdateResponse{Code: pb.CodeResult_OK}, nil
+}
+
+//Permanently removes a deleted user
+func (s UserServer) Purge(ctx context.Context, req *pb.PurgeRequest) (*pb.DeleteResponse, error) {
+
+	version, err := expectedVersion(ctx, req.GetVersion())
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.appService.Purge(ctx, int(req.GetId()), version)
+
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidData:
+			return nil, status.Errorf(codes.InvalidArgument, "invalid id")
+		case domain.ErrVersionMismatch:
+			return nil, status.Errorf(codes.Aborted, "User was modified, version mismatch")
+		case domain.ErrNotFound:
+			return nil, status.Errorf(codes.NotFound, "User does not exist")
+		case domain.ErrNotDeleted:
+			return nil, status.Errorf(codes.FailedPrecondition, "User must be deleted before being purged")
+		case domain.ErrInternalError:
+			return nil, status.Errorf(codes.Internal, "Internal error")
+		}
+
+		return nil, err
+	}
+
+	return &pb.DeleteResponse{Code: pb.CodeResult_OK}, nil
+}
+
+//Lists the recorded changes of the users
+func (s UserServer) ListAuditEvents(ctx context.Context, req *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+
+	filter := domain.AuditFilter{UserID: int(req.GetUserId())}
+
+	if req.GetStartTime() != nil {
+		filter.From = req.GetStartTime().AsTime()
+	}
+
+	if req.GetEndTime() != nil {
+		filter.To = req.GetEndTime().AsTime()
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid time range, start_time must be before end_time")
+	}
+
+	events, err := s.appService.ListAuditEvents(ctx, filter)
+
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Internal error")
+	}
+
+	response := &pb.ListAuditEventsResponse{Events: make([]*pb.AuditEvent, 0, len(events))}
+
+	for _, e := range events {
+		event, _ := mappers.ToGrpcAuditEvent(e)
+		response.Events = append(response.Events, event)
+	}
+
+	return response, nil
+}
+
+//Streams the changes of the users as they happen
+func (s UserServer) WatchUsers(req *pb.WatchUsersRequest, resp pb.Users_WatchUsersServer) error {
+
+	revision, err := watchRevision(resp.Context(), req.GetRevision())
+
+	if err != nil {
+		return err
+	}
+
+	changes, err := s.appService.Watch(resp.Context(), revision)
+
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidData:
+			return status.Errorf(codes.InvalidArgument, "Unknown revision %d", revision)
+		case domain.ErrRevisionGone:
+			return status.Errorf(codes.OutOfRange, "Revision %d is no longer available, watch from the current one", revision)
+		}
+
+		return status.Errorf(codes.Internal, "Internal error")
+	}
+
+	if err := resp.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for c := range changes {
+		change, _ := mappers.ToGrpcUserChange(c)
+
+		if err := resp.Send(&change); err != nil {
+			return err
+		}
+	}
+
+	if resp.Context().Err() != nil {
+		return nil
+	}
+
+	return status.Errorf(codes.ResourceExhausted, "Watcher fell behind, watch again after the last revision received")
+}
+
+//ignoredMaskFields are the user fields a client cannot update
+var ignoredMaskFields = map[string]bool{
+	"id":          true,
+	"version":     true,
+	"deleted_at":  true,
+	"create_time": true,
+	"update_time": true,
+	"created_by":  true,
+	"updated_by":  true,
+}
+
+//updatableFields drops the fields a client cannot update from the mask, the gateway builds
+//the mask from every field present in the request body, a mask left empty would update every field
+//so the callers reject the masks made only of ignored fields
+func updatableFields(paths []string) []string {
+
+	fields := make([]string, 0, len(paths))
+
+	for _, p := range paths {
+		if !ignoredMaskFields[p] {
+			fields = append(fields, p)
+		}
+	}
+
+	return fields
+}
+
+//expectedVersion returns the version given in the request or else the one in the If-Match metadata,
+//0 means the caller does not expect any version
+func expectedVersion(ctx context.Context, requested int32) (int, error) {
+
+	if requested != 0 {
+		return int(requested), nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(IfMatchHeader)
+
+	if len(values) == 0 || values[0] == "*" {
+		return 0, nil
+	}
+
+	version, err := parseETag(values[0])
+
+	if err != nil {
+		return 0, status.Errorf(codes.InvalidArgument, "Invalid If-Match header %q", values[0])
+	}
+
+	return version, nil
+}
+
+//watchRevision returns the revision given in the request or else the one in the Last-Event-ID metadata
+//sent by a reconnecting EventSource
+func watchRevision(ctx context.Context, requested int64) (int64, error) {
+
+	if requested != 0 {
+		return requested, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(LastEventIDHeader)
+
+	if len(values) == 0 || values[0] == "" {
+		return 0, nil
+	}
+
+	revision, err := strconv.ParseInt(values[0], 10, 64)
+
+	if err != nil || revision < 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "Invalid Last-Event-ID header %q", values[0])
+	}
+
+	return revision, nil
+}
+
+//formatETag builds the strong entity tag of a user version
+func formatETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+//parseETag extracts the version from an entity tag, the quotes are optional
+func parseETag(etag string) (int, error) {
+
+	version, err := strconv.Atoi(strings.Trim(etag, `"`))
+
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid entity tag %q", etag)
+	}
+
+	return version, nil
+}
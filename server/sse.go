@@ -0,0 +1,73 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+
+	pb "github.com/casmelad/bootcamp-gateway/server/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+//EventStreamContentType is the media type a client accepts to receive the streamed responses as Server-Sent Events
+const EventStreamContentType = "text/event-stream"
+
+//eventStreamMarshaler writes every streamed message as a Server-Sent Event whose data is the JSON message,
+//the revision of a user change is the event id so a reconnecting EventSource sends it back in the
+//Last-Event-ID header, a failure is sent as an error event
+type eventStreamMarshaler struct {
+	runtime.JSONPb
+}
+
+func newEventStreamMarshaler() *eventStreamMarshaler {
+	return &eventStreamMarshaler{runtime.JSONPb{
+		MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true},
+		UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+	}}
+}
+
+//ContentType - the media type of the Server-Sent Events
+func (m *eventStreamMarshaler) ContentType(_ interface{}) string {
+	return EventStreamContentType
+}
+
+//Marshal - writes the message, or the result or error wrapped by the gateway, as a complete event
+func (m *eventStreamMarshaler) Marshal(v interface{}) ([]byte, error) {
+
+	var buf bytes.Buffer
+	message := v
+
+	switch chunk := v.(type) {
+	case map[string]interface{}:
+		message = chunk["result"]
+	case map[string]proto.Message:
+		message = chunk["error"]
+		buf.WriteString("event: error\n")
+	}
+
+	if change, ok := message.(*pb.UserChange); ok {
+		fmt.Fprintf(&buf, "id: %d\n", change.GetRevision())
+	}
+
+	data, err := m.JSONPb.Marshal(message)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+//Delimiter - nothing, every event already ends with a blank line
+func (m *eventStreamMarshaler) Delimiter() []byte {
+	return []byte{}
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/casmelad/bootcamp-gateway/server/tracing"
+	domain "github.com/casmelad/bootcamp-gateway/users"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//instrumentationName names the tracers of the gateway and of the service
+const instrumentationName = "github.com/casmelad/bootcamp-gateway/server"
+
+//TracingMiddleware records a server span for every HTTP request, child of the span of the Traceparent header,
+//the paths are left out since they hold the emails of the users
+func TracingMiddleware(provider trace.TracerProvider, next http.Handler) http.Handler {
+
+	tracer := provider.Tracer(instrumentationName)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := tracing.Propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := tracer.Start(ctx, "HTTP "+r.Method, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPMethodKey.String(r.Method)))
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(recorder.status))
+
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+
+		span.End()
+	})
+}
+
+//TracedService is a users Service recording a span for every call to the service it wraps
+type TracedService struct {
+	service domain.Service
+	tracer  trace.Tracer
+}
+
+//NewTracedService returns a TracedService type pointer
+func NewTracedService(service domain.Service, provider trace.TracerProvider) *TracedService {
+	return &TracedService{service: service, tracer: provider.Tracer(instrumentationName)}
+}
+
+//start starts the span of a method of the service
+func (ts *TracedService) start(ctx context.Context, method string) (context.Context, trace.Span) {
+	return ts.tracer.Start(ctx, "UserService."+method, trace.WithSpanKind(trace.SpanKindInternal))
+}
+
+//finishSpan records the result of a method of the service
+func finishSpan(span trace.Span, err error) {
+
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+//Create - creates the user
+func (ts *TracedService) Create(ctx context.Context, usr domain.User) (int, error) {
+
+	ctx, span := ts.start(ctx, "Create")
+	id, err := ts.service.Create(ctx, usr)
+	span.SetAttributes(attribute.Int("user.id", id))
+	finishSpan(span, err)
+
+	return id, err
+}
+
+//GetByEmail - retrieves the user with the email
+func (ts *TracedService) GetByEmail(ctx context.Context, email string) (domain.User, error) {
+
+	ctx, span := ts.start(ctx, "GetByEmail")
+	usr, err := ts.service.GetByEmail(ctx, email)
+	finishSpan(span, err)
+
+	return usr, err
+}
+
+//GetAll - retrieves all the users
+func (ts *TracedService) GetAll(ctx context.Context) ([]domain.User, error) {
+
+	ctx, span := ts.start(ctx, "GetAll")
+	all, err := ts.service.GetAll(ctx)
+	finishSpan(span, err)
+
+	return all, err
+}
+
+//List - retrieves a page of users
+func (ts *TracedService) List(ctx context.Context, req domain.ListRequest) (domain.Page, error) {
+
+	ctx, span := ts.start(ctx, "List")
+	page, err := ts.service.List(ctx, req)
+	finishSpan(span, err)
+
+	return page, err
+}
+
+//Update - updates the fields of the user
+func (ts *TracedService) Update(ctx context.Context, usr domain.User, fields []string) (domain.User, error) {
+
+	ctx, span := ts.start(ctx, "Update")
+	span.SetAttributes(attribute.Int("user.id", usr.ID))
+	updated, err := ts.service.Update(ctx, usr, fields)
+	finishSpan(span, err)
+
+	return updated, err
+}
+
+//Delete - soft deletes the user
+func (ts *TracedService) Delete(ctx context.Context, usrID int, version int) error {
+
+	ctx, span := ts.start(ctx, "Delete")
+	span.SetAttributes(attribute.Int("user.id", usrID))
+	err := ts.service.Delete(ctx, usrID, version)
+	finishSpan(span, err)
+
+	return err
+}
+
+//Undelete - restores the soft deleted user
+func (ts *TracedService) Undelete(ctx context.Context, usrID int, version int) (domain.User, error) {
+
+	ctx, span := ts.start(ctx, "Undelete")
+	span.SetAttributes(attribute.Int("user.id", usrID))
+	usr, err := ts.service.Undelete(ctx, usrID, version)
+	finishSpan(span, err)
+
+	return usr, err
+}
+
+//Purge - permanently removes the soft deleted user
+func (ts *TracedService) Purge(ctx context.Context, usrID int, version int) error {
+
+	ctx, span := ts.start(ctx, "Purge")
+	span.SetAttributes(attribute.Int("user.id", usrID))
+	err := ts.service.Purge(ctx, usrID, version)
+	finishSpan(span, err)
+
+	return err
+}
+
+//ListAuditEvents - retrieves the audit events matching the filter
+func (ts *TracedService) ListAuditEvents(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEvent, error) {
+
+	ctx, span := ts.start(ctx, "ListAuditEvents")
+	events, err := ts.service.ListAuditEvents(ctx, filter)
+	finishSpan(span, err)
+
+	return events, err
+}
+
+//Watch - subscribes to the changes of the users, the span only covers the subscription
+func (ts *TracedService) Watch(ctx context.Context, afterRevision int64) (<-chan domain.Change, error) {
+
+	_, span := ts.start(ctx, "Watch")
+	changes, err := ts.service.Watch(ctx, afterRevision)
+	finishSpan(span, err)
+
+	return changes, err
+}
@@ -0,0 +1,78 @@
+//Package tracing sets up the OpenTelemetry SDK recording the spans of the requests, they are propagated between the
+//gateway and the server with the W3C traceparent header
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+//Propagator is the W3C trace context propagator used by the gateway and the server
+var Propagator propagation.TextMapPropagator = propagation.TraceContext{}
+
+//NewProvider returns the provider batching the spans of the service to the exporter, the status messages of the
+//RPC spans are dropped since they can hold personal data
+func NewProvider(exporter sdktrace.SpanExporter, serviceName string) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(NewRedactingExporter(exporter)),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+}
+
+//RedactingExporter is a span exporter dropping the status message of the RPC spans before exporting them, only
+//their status code is kept
+type RedactingExporter struct {
+	exporter sdktrace.SpanExporter
+}
+
+//NewRedactingExporter returns a RedactingExporter type pointer exporting to exporter
+func NewRedactingExporter(exporter sdktrace.SpanExporter) *RedactingExporter {
+	return &RedactingExporter{exporter: exporter}
+}
+
+//ExportSpans - exports the spans without the status message of the RPC spans
+func (e *RedactingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+
+	redacted := make([]sdktrace.ReadOnlySpan, 0, len(spans))
+
+	for _, span := range spans {
+		if span.Status().Description == "" || !isRPC(span.Attributes()) {
+			redacted = append(redacted, span)
+			continue
+		}
+
+		redacted = append(redacted, redactedSpan{span})
+	}
+
+	return e.exporter.ExportSpans(ctx, redacted)
+}
+
+//Shutdown - shuts the wrapped exporter down
+func (e *RedactingExporter) Shutdown(ctx context.Context) error {
+	return e.exporter.Shutdown(ctx)
+}
+
+//redactedSpan is a span without its status message
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s redactedSpan) Status() sdktrace.Status {
+	return sdktrace.Status{Code: s.ReadOnlySpan.Status().Code}
+}
+
+func isRPC(attributes []attribute.KeyValue) bool {
+
+	for _, kv := range attributes {
+		if kv.Key == semconv.RPCSystemKey {
+			return true
+		}
+	}
+
+	return false
+}
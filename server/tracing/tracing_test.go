@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+func Test_RedactingExporter_DropsTheStatusMessageOfTheRPCSpans(t *testing.T) {
+	//Arrange
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(NewRedactingExporter(exporter)))
+	tracer := provider.Tracer("test")
+	_, rpc := tracer.Start(context.Background(), "users.Users/GetUser")
+	rpc.SetAttributes(semconv.RPCSystemKey.String("grpc"))
+	rpc.SetStatus(codes.Error, "user with Email test@gmail.com could not be found")
+	_, internal := tracer.Start(context.Background(), "UserService.GetByEmail")
+	internal.SetStatus(codes.Error, "user not found")
+	//Act
+	rpc.End()
+	internal.End()
+	//Assert
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Empty(t, spans[0].Status.Description)
+	assert.Equal(t, "user not found", spans[1].Status.Description)
+}
+
+func Test_NewProvider_ReportsTheService(t *testing.T) {
+	//Arrange
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewProvider(exporter, "users")
+	//Act
+	_, span := provider.Tracer("test").Start(context.Background(), "span")
+	span.End()
+	require.NoError(t, provider.ForceFlush(context.Background()))
+	//Assert
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Resource.Attributes(), semconv.ServiceNameKey.String("users"))
+}
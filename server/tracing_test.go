@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/casmelad/bootcamp-gateway/server/proto"
+	"github.com/casmelad/bootcamp-gateway/server/tracing"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+//newTracedGateway serves the gateway and the gRPC server both recording their spans, the way main wires them,
+//the returned function exports the spans recorded so far
+func newTracedGateway(t *testing.T) (*httptest.Server, func() tracetest.SpanStubs) {
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider(exporter, "users")
+	traceOpts := []otelgrpc.Option{otelgrpc.WithTracerProvider(provider), otelgrpc.WithPropagators(tracing.Propagator)}
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor(traceOpts...)))
+	pb.RegisterUsersServer(grpcServer, NewUserServer(NewTracedService(newPopulatedTestService(t), provider)))
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithInsecure(), grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor(traceOpts...)))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	mux := runtime.NewServeMux(GatewayOptions()...)
+	require.NoError(t, pb.RegisterUsersHandler(context.Background(), mux, conn))
+
+	gateway := httptest.NewServer(TracingMiddleware(provider, mux))
+	t.Cleanup(gateway.Close)
+
+	return gateway, func() tracetest.SpanStubs {
+		require.NoError(t, provider.ForceFlush(context.Background()))
+		return exporter.GetSpans()
+	}
+}
+
+//findSpan returns the span with the name and the kind
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string, kind trace.SpanKind) tracetest.SpanStub {
+
+	for _, span := range spans {
+		if span.Name == name && span.SpanKind == kind {
+			return span
+		}
+	}
+
+	require.Failf(t, "span not found", "%s %s", kind, name)
+	return tracetest.SpanStub{}
+}
+
+func Test_Tracing_PropagatesTheTraceFromTheGatewayToTheService(t *testing.T) {
+	//Arrange
+	gateway, spans := newTracedGateway(t)
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	//Act
+	resp := doRequest(t, gateway, http.MethodGet, "/api/v1/users/test@gmail.com", "", "Traceparent", traceparent)
+	resp.Body.Close()
+	//Assert
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	exported := spans()
+	require.Len(t, exported, 4)
+	for _, span := range exported {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String(), span.Name)
+		assert.Contains(t, span.Resource.Attributes(), semconv.ServiceNameKey.String("users"))
+	}
+	httpSpan := findSpan(t, exported, "HTTP GET", trace.SpanKindServer)
+	client := findSpan(t, exported, "users.Users/GetUser", trace.SpanKindClient)
+	rpc := findSpan(t, exported, "users.Users/GetUser", trace.SpanKindServer)
+	service := findSpan(t, exported, "UserService.GetByEmail", trace.SpanKindInternal)
+	assert.Equal(t, "00f067aa0ba902b7", httpSpan.Parent.SpanID().String())
+	assert.True(t, httpSpan.Parent.IsRemote())
+	assert.Equal(t, httpSpan.SpanContext.SpanID(), client.Parent.SpanID())
+	assert.Equal(t, client.SpanContext.SpanID(), rpc.Parent.SpanID())
+	assert.True(t, rpc.Parent.IsRemote())
+	assert.Equal(t, rpc.SpanContext.SpanID(), service.Parent.SpanID())
+}
+
+func Test_Tracing_FailedCall_RecordsOnlyTheStatusCode(t *testing.T) {
+	//Arrange
+	gateway, spans := newTracedGateway(t)
+	//Act
+	resp := doRequest(t, gateway, http.MethodGet, "/api/v1/users/missing@gmail.com", "")
+	resp.Body.Close()
+	//Assert
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	exported := spans()
+	require.Len(t, exported, 4)
+	for _, span := range exported {
+		assert.NotContains(t, span.Status.Description, "@gmail.com", span.Name)
+		for _, kv := range span.Attributes {
+			assert.NotContains(t, kv.Value.Emit(), "@gmail.com", span.Name)
+		}
+	}
+	assert.Equal(t, codes.Error, findSpan(t, exported, "users.Users/GetUser", trace.SpanKindServer).Status.Code)
+	assert.Equal(t, codes.Error, findSpan(t, exported, "users.Users/GetUser", trace.SpanKindClient).Status.Code)
+	assert.Equal(t, codes.Unset, findSpan(t, exported, "HTTP GET", trace.SpanKindServer).Status.Code)
+}
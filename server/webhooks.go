@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+
+	pb "github.com/casmelad/bootcamp-gateway/server/proto"
+	mappers "github.com/casmelad/bootcamp-gateway/users/mappers"
+	"github.com/casmelad/bootcamp-gateway/users/webhooks"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type WebhookServer struct {
+	appService *webhooks.Service
+	pb.WebhooksServer
+}
+
+func NewWebhookServer(s *webhooks.Service) *WebhookServer {
+	return &WebhookServer{
+		appService: s,
+	}
+}
+
+//Registers an endpoint receiving the user events
+func (s WebhookServer) RegisterWebhook(ctx context.Context, req *pb.RegisterWebhookRequest) (*pb.Webhook, error) {
+
+	if req.GetWebhook() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid data")
+	}
+
+	webhook, _ := mappers.ToDomainWebhook(req.GetWebhook())
+
+	result, err := s.appService.Register(ctx, webhook)
+
+	if err != nil {
+		switch err {
+		case webhooks.ErrInvalidData:
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid data, the url must be absolute http or https and the event types known")
+		}
+
+		return nil, status.Errorf(codes.Internal, "Internal error")
+	}
+
+	mapped, _ := mappers.ToGrpcWebhook(result)
+
+	return mapped, nil
+}
+
+//Lists the registered webhooks
+func (s WebhookServer) ListWebhooks(ctx context.Context, req *pb.ListWebhooksRequest) (*pb.ListWebhooksResponse, error) {
+
+	result, err := s.appService.List(ctx)
+
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Internal error")
+	}
+
+	response := &pb.ListWebhooksResponse{Webhooks: make([]*pb.Webhook, 0, len(result))}
+
+	for _, w := range result {
+		mapped, _ := mappers.ToGrpcWebhook(w)
+		response.Webhooks = append(response.Webhooks, mapped)
+	}
+
+	return response, nil
+}
+
+//Removes a webhook
+func (s WebhookServer) DeleteWebhook(ctx context.Context, req *pb.DeleteWebhookRequest) (*pb.DeleteResponse, error) {
+
+	err := s.appService.Delete(ctx, req.GetId())
+
+	if err != nil {
+		switch err {
+		case webhooks.ErrNotFound:
+			return nil, status.Errorf(codes.NotFound, "Webhook does not exist")
+		}
+
+		return nil, status.Errorf(codes.Internal, "Internal error")
+	}
+
+	return &pb.DeleteResponse{Code: pb.CodeResult_OK}, nil
+}
+
+//Lists the deliveries that exhausted their attempts
+func (s WebhookServer) ListDeadLetters(ctx context.Context, req *pb.ListDeadLettersRequest) (*pb.ListDeadLettersResponse, error) {
+
+	result := s.appService.DeadLetters(ctx, req.GetWebhookId())
+
+	response := &pb.ListDeadLettersResponse{DeadLetters: make([]*pb.DeadLetter, 0, len(result))}
+
+	for _, l := range result {
+		mapped, _ := mappers.ToGrpcDeadLetter(l)
+		response.DeadLetters = append(response.DeadLetters, mapped)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,125 @@
+package users
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+const (
+	//AuditCreate - a user was created
+	AuditCreate = "create"
+	//AuditUpdate - the information of a user was changed
+	AuditUpdate = "update"
+	//AuditDelete - a user was soft deleted
+	AuditDelete = "delete"
+	//AuditUndelete - a soft deleted user was restored
+	AuditUndelete = "undelete"
+	//AuditPurge - a soft deleted user was permanently removed
+	AuditPurge = "purge"
+)
+
+//AuditChange - the value of a user field before and after a mutation
+type AuditChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+//AuditEvent - the record of a mutation made to a user
+type AuditEvent struct {
+	Time      time.Time     `json:"time"`
+	Action    string        `json:"action"`
+	UserID    int           `json:"user_id"`
+	Actor     string        `json:"actor"`
+	RequestID string        `json:"request_id"`
+	Changes   []AuditChange `json:"changes"`
+}
+
+//AuditFilter - conditions an audit event must meet to be listed, empty fields are ignored
+type AuditFilter struct {
+	UserID int
+	//From - only events recorded at or after this instant
+	From time.Time
+	//To - only events recorded before this instant
+	To time.Time
+}
+
+//AuditSink - stores the audit events and lists them in the order they were written
+type AuditSink interface {
+	Write(context.Context, AuditEvent) error
+	List(context.Context, AuditFilter) ([]AuditEvent, error)
+}
+
+//Matches - reports whether the event meets every condition of the filter
+func (f AuditFilter) Matches(e AuditEvent) bool {
+
+	if f.UserID != 0 && e.UserID != f.UserID {
+		return false
+	}
+
+	if !f.From.IsZero() && e.Time.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && !e.Time.Before(f.To) {
+		return false
+	}
+
+	return true
+}
+
+//auditedFields - the user fields whose changes are recorded and how their values are written
+var auditedFields = []struct {
+	name  string
+	value func(User) string
+}{
+	{FieldEmail, func(u User) string { return u.Email }},
+	{FieldName, func(u User) string { return u.Name }},
+	{FieldLastName, func(u User) string { return u.LastName }},
+	{"version", func(u User) string { return formatVersion(u.Version) }},
+	{"deleted_at", func(u User) string { return formatAuditTime(u.DeletedAt) }},
+}
+
+//diffUsers lists the audited fields that differ between both versions of a user
+func diffUsers(before, after User) []AuditChange {
+
+	changes := []AuditChange{}
+
+	for _, f := range auditedFields {
+		if b, a := f.value(before), f.value(after); b != a {
+			changes = append(changes, AuditChange{Field: f.name, Before: b, After: a})
+		}
+	}
+
+	return changes
+}
+
+func formatVersion(version int) string {
+
+	if version == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(version)
+}
+
+func formatAuditTime(t time.Time) string {
+
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339Nano)
+}
+
+//nopAuditSink discards the events, used when no sink is configured
+type nopAuditSink struct{}
+
+func (nopAuditSink) Write(context.Context, AuditEvent) error {
+	return nil
+}
+
+func (nopAuditSink) List(context.Context, AuditFilter) ([]AuditEvent, error) {
+	return []AuditEvent{}, nil
+}
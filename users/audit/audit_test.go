@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSink(t *testing.T, sink users.AuditSink) {
+	//Arrange
+	ctx := context.Background()
+	at := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	first := users.AuditEvent{Time: at, Action: users.AuditCreate, UserID: 1, Actor: "admin@gmail.com", RequestID: "req-1",
+		Changes: []users.AuditChange{{Field: users.FieldName, After: "John"}}}
+	second := users.AuditEvent{Time: at.Add(time.Hour), Action: users.AuditUpdate, UserID: 2, Changes: []users.AuditChange{}}
+	third := users.AuditEvent{Time: at.Add(2 * time.Hour), Action: users.AuditDelete, UserID: 1, Changes: []users.AuditChange{}}
+	//Act
+	for _, e := range []users.AuditEvent{first, second, third} {
+		require.NoError(t, sink.Write(ctx, e))
+	}
+	all, err := sink.List(ctx, users.AuditFilter{})
+	byUser, _ := sink.List(ctx, users.AuditFilter{UserID: 1})
+	byTime, _ := sink.List(ctx, users.AuditFilter{From: at.Add(time.Hour), To: at.Add(2 * time.Hour)})
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []users.AuditEvent{first, second, third}, all)
+	assert.Equal(t, []users.AuditEvent{first, third}, byUser)
+	assert.Equal(t, []users.AuditEvent{second}, byTime)
+}
+
+func Test_MemorySink(t *testing.T) {
+	testSink(t, NewMemorySink())
+}
+
+func Test_FileSink(t *testing.T) {
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer sink.Close()
+	testSink(t, sink)
+}
+
+func Test_FileSink_Reopen_KeepsEventsAndDiscardsTornEvent(t *testing.T) {
+	//Arrange
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(path)
+	require.NoError(t, err)
+	event := users.AuditEvent{Time: time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC), Action: users.AuditCreate, UserID: 1, Changes: []users.AuditChange{}}
+	require.NoError(t, sink.Write(ctx, event))
+	require.NoError(t, sink.Close())
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	file.WriteString(`{"time":"2021-12-01T01:00:00Z","act`)
+	file.Close()
+	//Act
+	reopened, err := NewFileSink(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	next := event
+	next.UserID = 2
+	require.NoError(t, reopened.Write(ctx, next))
+	all, err := reopened.List(ctx, users.AuditFilter{})
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []users.AuditEvent{event, next}, all)
+}
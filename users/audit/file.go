@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+)
+
+//FileSink appends the audit events to a file as JSON lines, every event is synced to disk
+//before Write returns
+type FileSink struct {
+	//mu serializes the writes and keeps List from reading a half written event
+	mu   sync.Mutex
+	file *os.File
+}
+
+//NewFileSink opens or creates the file at path and returns a FileSink type pointer,
+//a torn event at the end of the file (a crash in the middle of a write) is discarded
+func NewFileSink(path string) (*FileSink, error) {
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &FileSink{file: file}
+
+	if err := sink.scan(func(users.AuditEvent) {}); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+//Write - appends the event to the file
+func (s *FileSink) Write(ctx context.Context, e users.AuditEvent) error {
+
+	line, err := json.Marshal(e)
+
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return s.file.Sync()
+}
+
+//List - reads the events matching the filter in the order they were written
+func (s *FileSink) List(ctx context.Context, filter users.AuditFilter) ([]users.AuditEvent, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []users.AuditEvent{}
+
+	err := s.scan(func(e users.AuditEvent) {
+		if filter.Matches(e) {
+			result = append(result, e)
+		}
+	})
+
+	return result, err
+}
+
+//Close releases the file
+func (s *FileSink) Close() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+//scan reads every event from the start of the file, a torn last line is truncated
+func (s *FileSink) scan(fn func(users.AuditEvent)) error {
+
+	reader := bufio.NewReader(io.NewSectionReader(s.file, 0, 1<<62))
+	var offset int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				return s.file.Truncate(offset)
+			}
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var e users.AuditEvent
+
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("reading audit log at offset %d: %w", offset, err)
+		}
+
+		fn(e)
+		offset += int64(len(line))
+	}
+}
@@ -0,0 +1,48 @@
+package audit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+)
+
+//MemorySink keeps the audit events in memory, they are lost when the process exits and are never
+//trimmed so it is meant for the tests, it is safe for concurrent use
+type MemorySink struct {
+	mu     sync.RWMutex
+	events []users.AuditEvent
+}
+
+//NewMemorySink returns an empty MemorySink type pointer
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+//Write - stores the event
+func (s *MemorySink) Write(ctx context.Context, e users.AuditEvent) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, e)
+
+	return nil
+}
+
+//List - retrieves the events matching the filter in the order they were written
+func (s *MemorySink) List(ctx context.Context, filter users.AuditFilter) ([]users.AuditEvent, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []users.AuditEvent{}
+
+	for _, e := range s.events {
+		if filter.Matches(e) {
+			result = append(result, e)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,113 @@
+package users
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type recordingSink struct {
+	events []AuditEvent
+}
+
+func (s *recordingSink) Write(ctx context.Context, e AuditEvent) error {
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *recordingSink) List(ctx context.Context, f AuditFilter) ([]AuditEvent, error) {
+	return s.events, nil
+}
+
+func Test_Create_RecordsAuditEvent(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	sink := &recordingSink{}
+	service := NewUserService(&repository, WithAuditSink(sink))
+	ctx := WithRequestID(WithActor(context.Background(), "admin@gmail.com"), "req-1")
+	repository.On("GetByEmail", ctx, "test@gmail.com").Return(User{}, nil)
+	repository.On("Add", ctx, mock.AnythingOfType("User")).Return(7, nil)
+	//Act
+	_, err := service.Create(ctx, User{Email: "test@gmail.com", Name: "John", LastName: "Connor"})
+	//Assert
+	assert.Nil(t, err)
+	assert.Len(t, sink.events, 1)
+	event := sink.events[0]
+	assert.Equal(t, AuditCreate, event.Action)
+	assert.Equal(t, 7, event.UserID)
+	assert.Equal(t, "admin@gmail.com", event.Actor)
+	assert.Equal(t, "req-1", event.RequestID)
+	assert.False(t, event.Time.IsZero())
+	assert.Equal(t, []AuditChange{
+		{Field: FieldEmail, After: "test@gmail.com"},
+		{Field: FieldName, After: "John"},
+		{Field: FieldLastName, After: "Connor"},
+		{Field: "version", After: "1"},
+	}, event.Changes)
+}
+
+func Test_Update_RecordsOnlyChangedFields(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	sink := &recordingSink{}
+	service := NewUserService(&repository, WithAuditSink(sink))
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Email: "test@gmail.com", Name: "John", LastName: "Connor", Version: 1}, nil)
+	repository.On("Update", context.Background(), stamped(User{ID: 1, Email: "test@gmail.com", Name: "Kyle", LastName: "Connor", Version: 1})).Return(nil)
+	//Act
+	_, err := service.Update(context.Background(), User{ID: 1, Name: "Kyle"}, []string{FieldName})
+	//Assert
+	assert.Nil(t, err)
+	assert.Len(t, sink.events, 1)
+	assert.Equal(t, AuditUpdate, sink.events[0].Action)
+	assert.Equal(t, []AuditChange{
+		{Field: FieldName, Before: "John", After: "Kyle"},
+		{Field: "version", Before: "1", After: "2"},
+	}, sink.events[0].Changes)
+}
+
+func Test_Update_Failed_RecordsNothing(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	sink := &recordingSink{}
+	service := NewUserService(&repository, WithAuditSink(sink))
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Email: "test@gmail.com", Name: "John", LastName: "Connor", Version: 1}, nil)
+	repository.On("Update", context.Background(), stamped(User{ID: 1, Email: "test@gmail.com", Name: "Kyle", LastName: "Connor", Version: 1})).Return(ErrVersionMismatch)
+	//Act
+	_, err := service.Update(context.Background(), User{ID: 1, Name: "Kyle"}, []string{FieldName})
+	//Assert
+	assert.Equal(t, ErrVersionMismatch, err)
+	assert.Empty(t, sink.events)
+}
+
+func Test_Purge_RecordsAuditEvent(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	sink := &recordingSink{}
+	service := NewUserService(&repository, WithAuditSink(sink))
+	deletedAt := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Email: "test@gmail.com", Version: 2, DeletedAt: deletedAt}, nil)
+	repository.On("Delete", context.Background(), 1, 2).Return(nil)
+	//Act
+	err := service.Purge(context.Background(), 1, 0)
+	//Assert
+	assert.Nil(t, err)
+	assert.Len(t, sink.events, 1)
+	assert.Equal(t, AuditPurge, sink.events[0].Action)
+	assert.Equal(t, 1, sink.events[0].UserID)
+	assert.Contains(t, sink.events[0].Changes, AuditChange{Field: "deleted_at", Before: "2021-12-01T00:00:00Z"})
+}
+
+func Test_AuditFilter_Matches(t *testing.T) {
+	//Arrange
+	at := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	event := AuditEvent{UserID: 1, Time: at}
+	//Assert
+	assert.True(t, AuditFilter{}.Matches(event))
+	assert.True(t, AuditFilter{UserID: 1, From: at, To: at.Add(time.Second)}.Matches(event))
+	assert.False(t, AuditFilter{UserID: 2}.Matches(event))
+	assert.False(t, AuditFilter{From: at.Add(time.Second)}.Matches(event))
+	assert.False(t, AuditFilter{To: at}.Matches(event))
+}
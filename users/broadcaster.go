@@ -0,0 +1,127 @@
+package users
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	//DefaultWatchHistory is the number of recent changes kept so the watchers can resume
+	DefaultWatchHistory = 1000
+	//watchBuffer is the number of changes a watcher can fall behind before it is disconnected
+	watchBuffer = 100
+)
+
+//Change - a change of a user delivered to the watchers
+type Change struct {
+	//Revision - increases with every change, a watcher resumes after the last revision it received
+	Revision int64
+	Event
+}
+
+//Broadcaster - delivers the changes of the users to the watchers, it keeps the recent ones so a watcher
+//can resume after a disconnection, it is safe for concurrent use
+type Broadcaster struct {
+	mu       sync.Mutex
+	revision int64
+	history  []Change
+	size     int
+	watchers map[chan Change]struct{}
+}
+
+//NewBroadcaster - returns a Broadcaster type pointer keeping the last size changes.
+//The revisions start from the current time in microseconds so the ones received from a previous
+//run are older than any kept change and cannot be confused with the new ones
+func NewBroadcaster(size int) *Broadcaster {
+
+	if size <= 0 {
+		size = DefaultWatchHistory
+	}
+
+	return &Broadcaster{
+		revision: time.Now().UnixNano() / int64(time.Microsecond),
+		size:     size,
+		watchers: map[chan Change]struct{}{},
+	}
+}
+
+//Publish - assigns the next revision to the event and delivers it, the watchers that fell too far behind
+//are disconnected so they can resume after the last change they received
+func (b *Broadcaster) Publish(e Event) Change {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.revision++
+	change := Change{Revision: b.revision, Event: e}
+
+	b.history = append(b.history, change)
+
+	if len(b.history) > b.size {
+		b.history = append(b.history[:0], b.history[len(b.history)-b.size:]...)
+	}
+
+	for w := range b.watchers {
+		select {
+		case w <- change:
+		default:
+			b.remove(w)
+		}
+	}
+
+	return change
+}
+
+//Subscribe - returns a channel receiving the changes after the given revision followed by the new ones,
+//0 receives only the new ones. The channel is closed when the context is done or the watcher falls behind,
+//ErrRevisionGone is returned when the changes after the revision are no longer kept
+func (b *Broadcaster) Subscribe(ctx context.Context, after int64) (<-chan Change, error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Change
+
+	if after != 0 {
+		if after > b.revision {
+			return nil, ErrInvalidData
+		}
+
+		if after < b.revision && (len(b.history) == 0 || after < b.history[0].Revision-1) {
+			return nil, ErrRevisionGone
+		}
+
+		for _, c := range b.history {
+			if c.Revision > after {
+				missed = append(missed, c)
+			}
+		}
+	}
+
+	w := make(chan Change, len(missed)+watchBuffer)
+
+	for _, c := range missed {
+		w <- c
+	}
+
+	b.watchers[w] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(w)
+	}()
+
+	return w, nil
+}
+
+//remove disconnects a watcher, the caller must hold the lock
+func (b *Broadcaster) remove(w chan Change) {
+
+	if _, ok := b.watchers[w]; ok {
+		delete(b.watchers, w)
+		close(w)
+	}
+}
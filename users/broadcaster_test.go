@@ -0,0 +1,186 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+//receive reads the changes already delivered to the watcher
+func receive(w <-chan Change, n int) []Change {
+
+	result := make([]Change, 0, n)
+
+	for i := 0; i < n; i++ {
+		result = append(result, <-w)
+	}
+
+	return result
+}
+
+func Test_Broadcaster_DeliversNewChanges(t *testing.T) {
+	//Arrange
+	broadcaster := NewBroadcaster(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := broadcaster.Subscribe(ctx, 0)
+	require.NoError(t, err)
+	//Act
+	first := broadcaster.Publish(Event{Type: EventUserCreated, UserID: 1})
+	second := broadcaster.Publish(Event{Type: EventUserUpdated, UserID: 1})
+	//Assert
+	assert.Equal(t, []Change{first, second}, receive(w, 2))
+	assert.Equal(t, first.Revision+1, second.Revision)
+}
+
+func Test_Broadcaster_Resume_ReplaysMissedChanges(t *testing.T) {
+	//Arrange
+	broadcaster := NewBroadcaster(10)
+	first := broadcaster.Publish(Event{Type: EventUserCreated, UserID: 1})
+	second := broadcaster.Publish(Event{Type: EventUserUpdated, UserID: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	//Act
+	w, err := broadcaster.Subscribe(ctx, first.Revision)
+	third := broadcaster.Publish(Event{Type: EventUserDeleted, UserID: 1})
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []Change{second, third}, receive(w, 2))
+}
+
+func Test_Broadcaster_Resume_InvalidRevisions(t *testing.T) {
+	//Arrange
+	broadcaster := NewBroadcaster(2)
+	first := broadcaster.Publish(Event{UserID: 1})
+	broadcaster.Publish(Event{UserID: 2})
+	last := broadcaster.Publish(Event{UserID: 3})
+	//Act
+	_, errTrimmed := broadcaster.Subscribe(context.Background(), first.Revision-1)
+	_, errFuture := broadcaster.Subscribe(context.Background(), last.Revision+1)
+	_, errKept := broadcaster.Subscribe(context.Background(), first.Revision)
+	//Assert
+	assert.Equal(t, ErrRevisionGone, errTrimmed)
+	assert.Equal(t, ErrInvalidData, errFuture)
+	assert.Nil(t, errKept)
+}
+
+func Test_Broadcaster_PreviousRun_RevisionGone(t *testing.T) {
+	//Arrange
+	previous := NewBroadcaster(10)
+	old := previous.Publish(Event{UserID: 1})
+	broadcaster := NewBroadcaster(10)
+	broadcaster.Publish(Event{UserID: 1})
+	//Act
+	_, err := broadcaster.Subscribe(context.Background(), old.Revision)
+	//Assert
+	assert.Equal(t, ErrRevisionGone, err)
+}
+
+func Test_Broadcaster_SlowWatcher_IsDisconnected(t *testing.T) {
+	//Arrange
+	broadcaster := NewBroadcaster(10)
+	w, _ := broadcaster.Subscribe(context.Background(), 0)
+	//Act
+	for i := 0; i <= watchBuffer; i++ {
+		broadcaster.Publish(Event{UserID: i})
+	}
+	//Assert
+	received := 0
+	for range w {
+		received++
+	}
+	assert.Equal(t, watchBuffer, received)
+}
+
+func Test_Broadcaster_ContextDone_ClosesChannel(t *testing.T) {
+	//Arrange
+	broadcaster := NewBroadcaster(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	w, _ := broadcaster.Subscribe(ctx, 0)
+	//Act
+	cancel()
+	_, open := <-w
+	//Assert
+	assert.False(t, open)
+}
+
+func Test_Watch_ReceivesStoredChanges(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := service.Watch(ctx, 0)
+	require.NoError(t, err)
+	repository.On("GetByEmail", context.Background(), "test@gmail.com").Return(User{}, nil)
+	repository.On("Add", context.Background(), mock.AnythingOfType("User")).Return(7, nil)
+	repository.On("GetByID", context.Background(), 7).Return(User{ID: 7, Email: "test@gmail.com", Version: 1}, nil)
+	repository.On("Update", context.Background(), mock.AnythingOfType("User")).Return(nil)
+	//Act
+	service.Create(context.Background(), User{Email: "test@gmail.com", Name: "John", LastName: "Connor"})
+	service.Delete(context.Background(), 7, 0)
+	//Assert
+	changes := receive(w, 2)
+	assert.Equal(t, EventUserCreated, changes[0].Type)
+	assert.Equal(t, 7, changes[0].User.ID)
+	assert.Equal(t, EventUserDeleted, changes[1].Type)
+	assert.Equal(t, 2, changes[1].User.Version)
+	assert.Len(t, w, 0)
+}
+
+//sequentialRepository numbers the added users in the order they are added, the rest of the repository is not used
+type sequentialRepository struct {
+	Repository
+	mu   sync.Mutex
+	last int
+}
+
+func (r *sequentialRepository) GetByEmail(ctx context.Context, email string) (User, error) {
+	return User{}, nil
+}
+
+//Add - the later users take less time to be stored so concurrent writes commit out of order unless serialized
+func (r *sequentialRepository) Add(ctx context.Context, u User) (int, error) {
+
+	r.mu.Lock()
+	r.last++
+	id := r.last
+	r.mu.Unlock()
+
+	time.Sleep(time.Duration(100-id) * 10 * time.Microsecond)
+
+	return id, nil
+}
+
+func Test_Watch_ConcurrentWrites_ReceivedInCommitOrder(t *testing.T) {
+	//Arrange
+	service := NewUserService(&sequentialRepository{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := service.Watch(ctx, 0)
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	//Act
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			service.Create(context.Background(), User{Email: fmt.Sprintf("test%d@gmail.com", i), Name: "John", LastName: "Connor"})
+		}(i)
+	}
+	wg.Wait()
+	//Assert
+	changes := receive(w, 50)
+	for i, c := range changes {
+		assert.Equal(t, i+1, c.User.ID)
+		if i > 0 {
+			assert.Greater(t, c.Revision, changes[i-1].Revision)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package users
+
+import "context"
+
+type actorKey struct{}
+
+type requestIDKey struct{}
+
+//WithActor - returns a copy of the context carrying the identity of the caller making the changes
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+//ActorFromContext - returns the identity of the caller making the changes, empty when it is unknown
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+//WithRequestID - returns a copy of the context carrying the id of the request being served
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+//RequestIDFromContext - returns the id of the request being served, empty when it is unknown
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
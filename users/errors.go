@@ -12,6 +12,9 @@ var (
 	ErrInternalError     error = NewDomainError("error")
 	ErrInvalidData       error = NewDomainError("invalid data")
 	ErrUserAlreadyExists error = NewDomainError("already exists")
+	ErrVersionMismatch   error = NewDomainError("version mismatch")
+	ErrNotDeleted        error = NewDomainError("user is not deleted")
+	ErrRevisionGone      error = NewDomainError("revision is no longer available")
 )
 
 func NewDomainError(msg string) UsersDomainError {
@@ -0,0 +1,143 @@
+package users
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	//EventUserCreated - a user was created
+	EventUserCreated = "UserCreated"
+	//EventUserUpdated - the information of a user was changed
+	EventUserUpdated = "UserUpdated"
+	//EventUserDeleted - a user was soft deleted
+	EventUserDeleted = "UserDeleted"
+	//EventUserRestored - a soft deleted user was restored
+	EventUserRestored = "UserRestored"
+	//EventUserPurged - a soft deleted user was permanently removed
+	EventUserPurged = "UserPurged"
+)
+
+//EventTypes - every type of event published for the users
+var EventTypes = []string{EventUserCreated, EventUserUpdated, EventUserDeleted, EventUserRestored, EventUserPurged}
+
+//eventTypes maps the audit actions to the type of the event published for them
+var eventTypes = map[string]string{
+	AuditCreate:   EventUserCreated,
+	AuditUpdate:   EventUserUpdated,
+	AuditDelete:   EventUserDeleted,
+	AuditUndelete: EventUserRestored,
+	AuditPurge:    EventUserPurged,
+}
+
+//Event - a change of a user published to the downstream services, the same event can be delivered
+//more than once so consumers should ignore the IDs they already processed
+type Event struct {
+	//ID - assigned by the outbox, increasing in the order the events were recorded
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	UserID    int       `json:"user_id"`
+	Actor     string    `json:"actor"`
+	RequestID string    `json:"request_id"`
+	//User - the user after the change, or before it when the user was purged
+	User User `json:"user"`
+}
+
+//Outbox - stores the events until they are published
+type Outbox interface {
+	//Append - records the events to be published, their IDs are assigned by the outbox
+	Append(context.Context, ...Event) error
+	//Pending - retrieves up to limit events not yet published in the order they were recorded
+	Pending(context.Context, int) ([]Event, error)
+	//MarkPublished - removes the published events from the outbox
+	MarkPublished(context.Context, ...int64) error
+}
+
+//Transactor - writes users and events atomically
+type Transactor interface {
+	//WithinTransaction - runs fn with a repository and an outbox whose writes are stored together
+	//when fn returns nil and discarded otherwise
+	WithinTransaction(context.Context, func(Repository, Outbox) error) error
+}
+
+//EventPublisher - delivers the events to the downstream services
+type EventPublisher interface {
+	Publish(context.Context, Event) error
+}
+
+//DefaultRelayBatch is the number of events the relay reads from the outbox at once
+const DefaultRelayBatch = 100
+
+//RelayEvents - publishes the pending events in order and removes them from the outbox, it stops at
+//the first failure so the remaining events keep their order, and returns how many were published.
+//An event published right before a crash is published again, delivery is at least once
+func RelayEvents(ctx context.Context, outbox Outbox, publisher EventPublisher) (int, error) {
+
+	published := 0
+
+	for {
+		events, err := outbox.Pending(ctx, DefaultRelayBatch)
+
+		if err != nil || len(events) == 0 {
+			return published, err
+		}
+
+		for _, e := range events {
+			if err := publisher.Publish(ctx, e); err != nil {
+				return published, err
+			}
+
+			if err := outbox.MarkPublished(ctx, e.ID); err != nil {
+				return published, err
+			}
+
+			published++
+		}
+
+		if len(events) < DefaultRelayBatch {
+			return published, nil
+		}
+	}
+}
+
+//RunRelay - publishes the pending events right away and then every interval, until the context is done,
+//the failures are reported to the logger
+func RunRelay(ctx context.Context, outbox Outbox, publisher EventPublisher, interval time.Duration, logger Logger) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := RelayEvents(ctx, outbox, publisher); err != nil {
+			logger.Error("publishing user events failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//nopOutbox drops the events, used when the service has no transactor
+type nopOutbox struct{}
+
+func (nopOutbox) Append(context.Context, ...Event) error { return nil }
+
+func (nopOutbox) Pending(context.Context, int) ([]Event, error) { return nil, nil }
+
+func (nopOutbox) MarkPublished(context.Context, ...int64) error { return nil }
+
+//newEvent builds the event published for an audited action
+func newEvent(ctx context.Context, action string, usr User) Event {
+	return Event{
+		Type:      eventTypes[action],
+		Time:      now(),
+		UserID:    usr.ID,
+		Actor:     ActorFromContext(ctx),
+		RequestID: RequestIDFromContext(ctx),
+		User:      usr,
+	}
+}
@@ -0,0 +1,34 @@
+//Package events provides the users.EventPublisher implementations the outbox relay delivers to
+package events
+
+import (
+	"context"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+)
+
+//ChannelPublisher delivers the events to in-process consumers through a channel
+type ChannelPublisher struct {
+	events chan users.Event
+}
+
+//NewChannelPublisher returns a ChannelPublisher type pointer whose channel buffers up to size events
+func NewChannelPublisher(size int) *ChannelPublisher {
+	return &ChannelPublisher{events: make(chan users.Event, size)}
+}
+
+//Events - the channel the published events are received from
+func (p *ChannelPublisher) Events() <-chan users.Event {
+	return p.events
+}
+
+//Publish - sends the event, waiting while the channel is full until the context is done
+func (p *ChannelPublisher) Publish(ctx context.Context, e users.Event) error {
+
+	select {
+	case p.events <- e:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
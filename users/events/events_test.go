@@ -0,0 +1,206 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvent() users.Event {
+	return users.Event{ID: 3, Type: users.EventUserCreated, UserID: 7, User: users.User{ID: 7, Email: "test@gmail.com"}}
+}
+
+func Test_ChannelPublisher_DeliversEvents(t *testing.T) {
+	//Arrange
+	publisher := NewChannelPublisher(1)
+	//Act
+	err := publisher.Publish(context.Background(), testEvent())
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, testEvent(), <-publisher.Events())
+}
+
+func Test_ChannelPublisher_Full_ReturnsContextError(t *testing.T) {
+	//Arrange
+	publisher := NewChannelPublisher(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	//Act
+	err := publisher.Publish(ctx, testEvent())
+	//Assert
+	assert.Equal(t, context.Canceled, err)
+}
+
+//natsStandIn is a local stand-in of a NATS server, it acknowledges the messages and sends them to
+//received, rejecting the ones published to a subject named reject
+func natsStandIn(t *testing.T, received chan<- string) string {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveNATS(conn, received)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func serveNATS(conn net.Conn, received chan<- string) {
+
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"stand-in\"}\r\n")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) > 0 && fields[0] == "CONNECT":
+			fmt.Fprintf(conn, "PING\r\n+OK\r\n")
+		case len(fields) == 3 && fields[0] == "PUB":
+			size, _ := strconv.Atoi(fields[2])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			if fields[1] == "reject" {
+				fmt.Fprintf(conn, "-ERR 'Permissions Violation'\r\n")
+				continue
+			}
+			received <- fields[1] + " " + string(payload[:size])
+			fmt.Fprintf(conn, "+OK\r\n")
+		}
+	}
+}
+
+func Test_NATSPublisher_PublishesJSON(t *testing.T) {
+	//Arrange
+	received := make(chan string, 2)
+	publisher := NewNATSPublisher(natsStandIn(t, received), "users.events")
+	defer publisher.Close()
+	//Act
+	first := publisher.Publish(context.Background(), testEvent())
+	second := publisher.Publish(context.Background(), testEvent())
+	//Assert
+	assert.Nil(t, first)
+	assert.Nil(t, second)
+	message := <-received
+	assert.True(t, strings.HasPrefix(message, "users.events "))
+	var event users.Event
+	assert.Nil(t, json.Unmarshal([]byte(strings.TrimPrefix(message, "users.events ")), &event))
+	assert.Equal(t, testEvent(), event)
+	assert.Len(t, received, 1)
+}
+
+func Test_NATSPublisher_Rejected_ReturnsError(t *testing.T) {
+	//Arrange
+	publisher := NewNATSPublisher(natsStandIn(t, make(chan string, 1)), "reject")
+	defer publisher.Close()
+	//Act
+	err := publisher.Publish(context.Background(), testEvent())
+	//Assert
+	assert.EqualError(t, err, "nats: 'Permissions Violation'")
+}
+
+func Test_NATSPublisher_Unreachable_ReturnsError(t *testing.T) {
+	//Arrange
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+	publisher := NewNATSPublisher(addr, "users.events")
+	//Act
+	err = publisher.Publish(context.Background(), testEvent())
+	//Assert
+	assert.NotNil(t, err)
+}
+
+func Test_KafkaPublisher_ProducesKeyedRecord(t *testing.T) {
+	//Arrange
+	var path, contentType string
+	var body struct {
+		Records []struct {
+			Key   string      `json:"key"`
+			Value users.Event `json:"value"`
+		} `json:"records"`
+	}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":12,"error_code":null,"error":null}]}`))
+	}))
+	defer proxy.Close()
+	publisher := NewKafkaPublisher(proxy.URL+"/", "users-events", proxy.Client())
+	//Act
+	err := publisher.Publish(context.Background(), testEvent())
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "/topics/users-events", path)
+	assert.Equal(t, kafkaJSONContentType, contentType)
+	require.Len(t, body.Records, 1)
+	assert.Equal(t, "7", body.Records[0].Key)
+	assert.Equal(t, testEvent(), body.Records[0].Value)
+}
+
+func Test_KafkaPublisher_Failures_ReturnError(t *testing.T) {
+	responses := map[string]func(http.ResponseWriter){
+		"status": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40401,"message":"Topic not found"}`))
+		},
+		"record": func(w http.ResponseWriter) {
+			w.Write([]byte(`{"offsets":[{"partition":null,"offset":null,"error_code":50001,"error":"leader not available"}]}`))
+		},
+	}
+
+	for name, respond := range responses {
+		//Arrange
+		respond := respond
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { respond(w) }))
+		publisher := NewKafkaPublisher(proxy.URL, "users-events", proxy.Client())
+		//Act
+		err := publisher.Publish(context.Background(), testEvent())
+		proxy.Close()
+		//Assert
+		assert.NotNil(t, err, name)
+	}
+}
+
+func Test_KafkaPublisher_HangingProxy_TimesOut(t *testing.T) {
+	//Arrange
+	hang := make(chan struct{})
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-hang }))
+	t.Cleanup(proxy.Close)
+	t.Cleanup(func() { close(hang) })
+	publisher := NewKafkaPublisher(proxy.URL, "users-events", &http.Client{Timeout: 20 * time.Millisecond})
+	start := time.Now()
+	//Act
+	err := publisher.Publish(context.Background(), testEvent())
+	//Assert
+	assert.NotNil(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, DefaultKafkaTimeout, NewKafkaPublisher(proxy.URL, "users-events", nil).client.Timeout)
+}
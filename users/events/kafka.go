@@ -0,0 +1,103 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+)
+
+const (
+	//kafkaJSONContentType is the media type of the JSON records accepted by the Kafka REST proxy
+	kafkaJSONContentType = "application/vnd.kafka.json.v2+json"
+	//DefaultKafkaTimeout bounds every produce request, the relay retries the events of a proxy that does not
+	//answer in time
+	DefaultKafkaTimeout = 10 * time.Second
+)
+
+//KafkaPublisher produces the events to a Kafka topic through a Kafka REST proxy, the records are keyed
+//by user id so the events of a user keep their order within a partition
+type KafkaPublisher struct {
+	url    string
+	client *http.Client
+}
+
+//kafkaRecord is a record of a produce request
+type kafkaRecord struct {
+	Key   string      `json:"key"`
+	Value users.Event `json:"value"`
+}
+
+//kafkaProduceResponse is the answer of the proxy, a record can fail even when the request succeeds
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+//NewKafkaPublisher returns a KafkaPublisher type pointer producing to topic through the REST proxy at baseURL,
+//a client timing out after DefaultKafkaTimeout is used when client is nil
+func NewKafkaPublisher(baseURL, topic string, client *http.Client) *KafkaPublisher {
+
+	if client == nil {
+		client = &http.Client{Timeout: DefaultKafkaTimeout}
+	}
+
+	return &KafkaPublisher{url: strings.TrimRight(baseURL, "/") + "/topics/" + topic, client: client}
+}
+
+//Publish - produces the event and waits for the proxy to acknowledge it
+func (p *KafkaPublisher) Publish(ctx context.Context, e users.Event) error {
+
+	body, err := json.Marshal(struct {
+		Records []kafkaRecord `json:"records"`
+	}{Records: []kafkaRecord{{Key: strconv.Itoa(e.UserID), Value: e}}})
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", kafkaJSONContentType)
+
+	resp, err := p.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("kafka: producing event %d: %s: %s", e.ID, resp.Status, bytes.TrimSpace(message))
+	}
+
+	var produced kafkaProduceResponse
+
+	if err := json.NewDecoder(resp.Body).Decode(&produced); err != nil {
+		return fmt.Errorf("kafka: producing event %d: %w", e.ID, err)
+	}
+
+	for _, o := range produced.Offsets {
+		if o.ErrorCode != nil {
+			return fmt.Errorf("kafka: producing event %d: error %d: %s", e.ID, *o.ErrorCode, o.Error)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+)
+
+//natsTimeout bounds the connection and every acknowledgement wait
+const natsTimeout = 5 * time.Second
+
+//NATSPublisher publishes the events as JSON to a NATS subject, the connection runs in verbose mode
+//so every message is acknowledged by the server before Publish returns
+type NATSPublisher struct {
+	addr    string
+	subject string
+
+	//mu serializes the messages so each acknowledgement is matched with its message
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+//NewNATSPublisher returns a NATSPublisher type pointer publishing to subject on the server at addr (host:port),
+//the connection is opened on the first Publish and opened again after a failure
+func NewNATSPublisher(addr, subject string) *NATSPublisher {
+	return &NATSPublisher{addr: addr, subject: subject}
+}
+
+//Publish - sends the event and waits for the server acknowledgement
+func (p *NATSPublisher) Publish(ctx context.Context, e users.Event) error {
+
+	payload, err := json.Marshal(e)
+
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		if err := p.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := p.publish(payload); err != nil {
+		p.close()
+		return err
+	}
+
+	return nil
+}
+
+//Close - closes the connection to the server
+func (p *NATSPublisher) Close() error {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.close()
+}
+
+//connect opens the connection, reads the server INFO and sends the client CONNECT
+func (p *NATSPublisher) connect(ctx context.Context) error {
+
+	dialer := net.Dialer{Timeout: natsTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+
+	if err != nil {
+		return err
+	}
+
+	p.conn = conn
+	p.reader = bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(natsTimeout))
+
+	line, err := p.readLine()
+
+	if err == nil && !strings.HasPrefix(line, "INFO ") {
+		err = fmt.Errorf("nats: unexpected greeting %q", line)
+	}
+
+	if err == nil {
+		_, err = fmt.Fprintf(conn, "CONNECT {\"verbose\":true,\"pedantic\":false,\"name\":\"users\"}\r\n")
+	}
+
+	if err == nil {
+		err = p.waitAck()
+	}
+
+	if err != nil {
+		p.close()
+		return err
+	}
+
+	return nil
+}
+
+func (p *NATSPublisher) publish(payload []byte) error {
+
+	p.conn.SetDeadline(time.Now().Add(natsTimeout))
+
+	if _, err := fmt.Fprintf(p.conn, "PUB %s %d\r\n%s\r\n", p.subject, len(payload), payload); err != nil {
+		return err
+	}
+
+	return p.waitAck()
+}
+
+//waitAck reads until the server acknowledges or rejects the last command, answering its pings
+func (p *NATSPublisher) waitAck() error {
+
+	for {
+		line, err := p.readLine()
+
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case line == "+OK":
+			return nil
+		case line == "PING":
+			if _, err := fmt.Fprintf(p.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("nats: " + strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+func (p *NATSPublisher) readLine() (string, error) {
+
+	line, err := p.reader.ReadString('\n')
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (p *NATSPublisher) close() error {
+
+	if p.conn == nil {
+		return nil
+	}
+
+	err := p.conn.Close()
+	p.conn = nil
+	p.reader = nil
+
+	return err
+}
@@ -0,0 +1,180 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+//fakeOutbox keeps the events in memory and runs the transactions on the wrapped repository,
+//the events of a failed transaction are discarded
+type fakeOutbox struct {
+	repository Repository
+	events     []Event
+	lastID     int64
+}
+
+func (o *fakeOutbox) WithinTransaction(ctx context.Context, fn func(Repository, Outbox) error) error {
+
+	committed := len(o.events)
+
+	if err := fn(o.repository, o); err != nil {
+		o.events = o.events[:committed]
+		return err
+	}
+
+	return nil
+}
+
+func (o *fakeOutbox) Append(ctx context.Context, events ...Event) error {
+	for _, e := range events {
+		o.lastID++
+		e.ID = o.lastID
+		o.events = append(o.events, e)
+	}
+	return nil
+}
+
+func (o *fakeOutbox) Pending(ctx context.Context, limit int) ([]Event, error) {
+	if limit > len(o.events) {
+		limit = len(o.events)
+	}
+	return append([]Event{}, o.events[:limit]...), nil
+}
+
+func (o *fakeOutbox) MarkPublished(ctx context.Context, ids ...int64) error {
+	for _, id := range ids {
+		for i, e := range o.events {
+			if e.ID == id {
+				o.events = append(o.events[:i], o.events[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+type publisherFunc func(context.Context, Event) error
+
+func (f publisherFunc) Publish(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+func Test_Create_WithOutbox_AppendsUserCreated(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	outbox := &fakeOutbox{repository: &repository}
+	service := NewUserService(&repository, WithOutbox(outbox))
+	ctx := WithRequestID(WithActor(context.Background(), "admin@gmail.com"), "req-1")
+	repository.On("GetByEmail", ctx, "test@gmail.com").Return(User{}, nil)
+	repository.On("Add", ctx, mock.AnythingOfType("User")).Return(7, nil)
+	//Act
+	_, err := service.Create(ctx, User{Email: "test@gmail.com", Name: "John", LastName: "Connor"})
+	//Assert
+	assert.Nil(t, err)
+	assert.Len(t, outbox.events, 1)
+	event := outbox.events[0]
+	assert.Equal(t, EventUserCreated, event.Type)
+	assert.Equal(t, 7, event.UserID)
+	assert.Equal(t, "admin@gmail.com", event.Actor)
+	assert.Equal(t, "req-1", event.RequestID)
+	assert.Equal(t, "John", event.User.Name)
+	assert.Equal(t, 1, event.User.Version)
+}
+
+func Test_Delete_WithOutbox_AppendsUserDeletedWithNewVersion(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	outbox := &fakeOutbox{repository: &repository}
+	service := NewUserService(&repository, WithOutbox(outbox))
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Email: "test@gmail.com", Version: 3}, nil)
+	repository.On("Update", context.Background(), mock.AnythingOfType("User")).Return(nil)
+	//Act
+	err := service.Delete(context.Background(), 1, 0)
+	//Assert
+	assert.Nil(t, err)
+	assert.Len(t, outbox.events, 1)
+	assert.Equal(t, EventUserDeleted, outbox.events[0].Type)
+	assert.Equal(t, 4, outbox.events[0].User.Version)
+	assert.True(t, outbox.events[0].User.IsDeleted())
+}
+
+func Test_Update_WithOutbox_FailedWrite_AppendsNothing(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	outbox := &fakeOutbox{repository: &repository}
+	service := NewUserService(&repository, WithOutbox(outbox))
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Email: "test@gmail.com", Name: "John", LastName: "Connor", Version: 1}, nil)
+	repository.On("Update", context.Background(), mock.AnythingOfType("User")).Return(ErrVersionMismatch)
+	//Act
+	_, err := service.Update(context.Background(), User{ID: 1, Name: "Kyle"}, []string{FieldName})
+	//Assert
+	assert.Equal(t, ErrVersionMismatch, err)
+	assert.Empty(t, outbox.events)
+}
+
+func Test_RelayEvents_PublishesInOrderAndMarksPublished(t *testing.T) {
+	//Arrange
+	outbox := &fakeOutbox{}
+	outbox.Append(context.Background(), Event{Type: EventUserCreated, UserID: 1}, Event{Type: EventUserUpdated, UserID: 1})
+	var published []Event
+	publisher := publisherFunc(func(ctx context.Context, e Event) error {
+		published = append(published, e)
+		return nil
+	})
+	//Act
+	count, err := RelayEvents(context.Background(), outbox, publisher)
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []string{EventUserCreated, EventUserUpdated}, []string{published[0].Type, published[1].Type})
+	assert.Empty(t, outbox.events)
+}
+
+func Test_RelayEvents_PublishFails_KeepsRemainingEvents(t *testing.T) {
+	//Arrange
+	outbox := &fakeOutbox{}
+	outbox.Append(context.Background(), Event{Type: EventUserCreated, UserID: 1}, Event{Type: EventUserUpdated, UserID: 1})
+	errUnavailable := errors.New("unavailable")
+	publisher := publisherFunc(func(ctx context.Context, e Event) error {
+		if e.Type == EventUserUpdated {
+			return errUnavailable
+		}
+		return nil
+	})
+	//Act
+	count, err := RelayEvents(context.Background(), outbox, publisher)
+	//Assert
+	assert.Equal(t, errUnavailable, err)
+	assert.Equal(t, 1, count)
+	assert.Len(t, outbox.events, 1)
+	assert.Equal(t, EventUserUpdated, outbox.events[0].Type)
+}
+
+func Test_RunRelay_RetriesUntilPublished(t *testing.T) {
+	//Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	outbox := &fakeOutbox{}
+	outbox.Append(ctx, Event{Type: EventUserCreated, UserID: 1})
+	attempts := 0
+	publisher := publisherFunc(func(ctx context.Context, e Event) error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("unavailable")
+		}
+		cancel()
+		return nil
+	})
+	logger := &recordingLogger{}
+	//Act
+	RunRelay(ctx, outbox, publisher, time.Millisecond, logger)
+	//Assert
+	assert.Equal(t, 2, attempts)
+	assert.Empty(t, outbox.events)
+	assert.Len(t, logger.entries, 1)
+	assert.Equal(t, "error", logger.entries[0].level)
+}
@@ -0,0 +1,27 @@
+package users
+
+const (
+	//FieldEmail - API name of the user email address
+	FieldEmail = "email"
+	//FieldName - API name of the user name
+	FieldName = "name"
+	//FieldLastName - API name of the user last name
+	FieldLastName = "last_name"
+)
+
+//updatableField - a user field a client can change
+type updatableField struct {
+	//structField - name of the User field, used to validate it on its own
+	structField string
+	copy        func(dst *User, src User)
+}
+
+//updatableFields - the fields a client can change keyed by their API name
+var updatableFields = map[string]updatableField{
+	FieldEmail:    {"Email", func(dst *User, src User) { dst.Email = src.Email }},
+	FieldName:     {"Name", func(dst *User, src User) { dst.Name = src.Name }},
+	FieldLastName: {"LastName", func(dst *User, src User) { dst.LastName = src.LastName }},
+}
+
+//allUpdatableFields - the fields changed when an update does not list them
+var allUpdatableFields = []string{FieldEmail, FieldName, FieldLastName}
@@ -0,0 +1,189 @@
+package users
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	//DefaultPageSize - page size used when the caller does not ask for one
+	DefaultPageSize = 100
+	//MaxPageSize - largest page size a caller can ask for
+	MaxPageSize = 1000
+
+	pageTokenPrefix = "after:"
+)
+
+//Filter - conditions a user must meet to be listed, empty fields are ignored
+type Filter struct {
+	//EmailPrefix - case insensitive prefix of the email address
+	EmailPrefix string
+	//NameContains - case insensitive text contained in the name or last name
+	NameContains string
+	//CreatedAfter - only users created after this instant
+	CreatedAfter time.Time
+	//ShowDeleted - soft deleted users are listed too
+	ShowDeleted bool
+	//DeletedBefore - only users soft deleted before this instant, requires ShowDeleted
+	DeletedBefore time.Time
+}
+
+//ListOptions - paging and filtering options for the repository, users are always ordered by id
+type ListOptions struct {
+	//AfterID - only users with a greater id are returned
+	AfterID int
+	//Limit - maximum number of users returned
+	Limit  int
+	Filter Filter
+}
+
+//ListRequest - a request for a page of users
+type ListRequest struct {
+	PageSize  int
+	PageToken string
+	Filter    Filter
+}
+
+//Page - a page of users and the token to retrieve the following one
+type Page struct {
+	Users []User
+	//NextPageToken - empty when there are no more users
+	NextPageToken string
+	//TotalSize - number of users matching the filter across all the pages
+	TotalSize int
+}
+
+//Matches - reports whether the user meets every condition of the filter
+func (f Filter) Matches(u User) bool {
+
+	if f.EmailPrefix != "" && !strings.HasPrefix(strings.ToLower(u.Email), strings.ToLower(f.EmailPrefix)) {
+		return false
+	}
+
+	if f.NameContains != "" && !strings.Contains(strings.ToLower(u.Name+" "+u.LastName), strings.ToLower(f.NameContains)) {
+		return false
+	}
+
+	if !f.CreatedAfter.IsZero() && !u.CreatedAt.After(f.CreatedAfter) {
+		return false
+	}
+
+	if !f.ShowDeleted && u.IsDeleted() {
+		return false
+	}
+
+	if !f.DeletedBefore.IsZero() && (!u.IsDeleted() || !u.DeletedAt.Before(f.DeletedBefore)) {
+		return false
+	}
+
+	return true
+}
+
+//ParseFilter - parses a filter expression made of space separated key=value terms, values containing
+//spaces are double quoted, e.g. email_prefix=john name_contains="van der" created_after=2021-12-01T00:00:00Z show_deleted=true
+func ParseFilter(expr string) (Filter, error) {
+
+	var f Filter
+
+	terms, err := splitTerms(expr)
+
+	if err != nil {
+		return Filter{}, err
+	}
+
+	for _, term := range terms {
+		parts := strings.SplitN(term, "=", 2)
+
+		if len(parts) != 2 || parts[1] == "" {
+			return Filter{}, fmt.Errorf("invalid filter term %q", term)
+		}
+
+		key, value := parts[0], strings.Trim(parts[1], `"`)
+
+		switch key {
+		case "email_prefix":
+			f.EmailPrefix = value
+		case "name_contains":
+			f.NameContains = value
+		case "created_after":
+			createdAfter, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return Filter{}, fmt.Errorf("invalid created_after %q: %w", value, err)
+			}
+			f.CreatedAfter = createdAfter.UTC()
+		case "show_deleted":
+			showDeleted, err := strconv.ParseBool(value)
+			if err != nil {
+				return Filter{}, fmt.Errorf("invalid show_deleted %q: %w", value, err)
+			}
+			f.ShowDeleted = showDeleted
+		default:
+			return Filter{}, fmt.Errorf("unknown filter field %q", key)
+		}
+	}
+
+	return f, nil
+}
+
+//splitTerms splits on spaces outside of double quotes
+func splitTerms(expr string) ([]string, error) {
+
+	var terms []string
+	var current strings.Builder
+	quoted := false
+
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case r == ' ' && !quoted:
+			if current.Len() > 0 {
+				terms = append(terms, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in filter %q", expr)
+	}
+
+	if current.Len() > 0 {
+		terms = append(terms, current.String())
+	}
+
+	return terms, nil
+}
+
+//encodePageToken - builds the opaque token pointing after the given user id
+func encodePageToken(afterID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pageTokenPrefix + strconv.Itoa(afterID)))
+}
+
+//decodePageToken - extracts the user id a page token points after, an empty token starts from the beginning
+func decodePageToken(token string) (int, error) {
+
+	if token == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+
+	if err != nil || !strings.HasPrefix(string(raw), pageTokenPrefix) {
+		return 0, ErrInvalidData
+	}
+
+	afterID, err := strconv.Atoi(strings.TrimPrefix(string(raw), pageTokenPrefix))
+
+	if err != nil || afterID < 0 {
+		return 0, ErrInvalidData
+	}
+
+	return afterID, nil
+}
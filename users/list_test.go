@@ -0,0 +1,71 @@
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseFilter_ValidExpression_ReturnsFilter(t *testing.T) {
+	//Arrange
+	expr := `email_prefix=john name_contains="van der" created_after=2021-12-01T05:00:00-05:00 show_deleted=true`
+	//Act
+	result, err := ParseFilter(expr)
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, Filter{
+		EmailPrefix:  "john",
+		NameContains: "van der",
+		CreatedAfter: time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC),
+		ShowDeleted:  true,
+	}, result)
+}
+
+func Test_ParseFilter_Empty_ReturnsEmptyFilter(t *testing.T) {
+	//Act
+	result, err := ParseFilter("")
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, Filter{}, result)
+}
+
+func Test_ParseFilter_InvalidExpression_ReturnsError(t *testing.T) {
+	for _, expr := range []string{"email_prefix", "unknown=1", "created_after=yesterday", `name_contains="open`, "email_prefix=", "show_deleted=maybe"} {
+		_, err := ParseFilter(expr)
+		assert.NotNil(t, err, expr)
+	}
+}
+
+func Test_Filter_Matches(t *testing.T) {
+	//Arrange
+	usr := User{Email: "John@gmail.com", Name: "John", LastName: "Van Der Berg", CreatedAt: time.Date(2021, 12, 2, 0, 0, 0, 0, time.UTC)}
+	//Assert
+	assert.True(t, Filter{}.Matches(usr))
+	assert.True(t, Filter{EmailPrefix: "john@"}.Matches(usr))
+	assert.True(t, Filter{NameContains: "john van"}.Matches(usr))
+	assert.True(t, Filter{CreatedAfter: usr.CreatedAt.Add(-time.Second)}.Matches(usr))
+	assert.False(t, Filter{EmailPrefix: "jane"}.Matches(usr))
+	assert.False(t, Filter{NameContains: "smith"}.Matches(usr))
+	assert.False(t, Filter{CreatedAfter: usr.CreatedAt}.Matches(usr))
+}
+
+func Test_Filter_Matches_SoftDeleted(t *testing.T) {
+	//Arrange
+	deletedAt := time.Date(2021, 12, 2, 0, 0, 0, 0, time.UTC)
+	usr := User{Email: "john@gmail.com", DeletedAt: deletedAt}
+	//Assert
+	assert.False(t, Filter{}.Matches(usr))
+	assert.True(t, Filter{ShowDeleted: true}.Matches(usr))
+	assert.True(t, Filter{ShowDeleted: true, DeletedBefore: deletedAt.Add(time.Second)}.Matches(usr))
+	assert.False(t, Filter{ShowDeleted: true, DeletedBefore: deletedAt}.Matches(usr))
+	assert.False(t, Filter{ShowDeleted: true, DeletedBefore: deletedAt}.Matches(User{Email: "jane@gmail.com"}))
+}
+
+func Test_PageToken_RoundTrip(t *testing.T) {
+	//Act
+	result, err := decodePageToken(encodePageToken(42))
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, 42, result)
+}
@@ -0,0 +1,21 @@
+package users
+
+//Logger - writes structured entries, the message followed by key value pairs, for the failures that can only be
+//reported, the logger of the server implements it
+type Logger interface {
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+//NopLogger - a Logger discarding every entry
+type NopLogger struct{}
+
+//Info - discards the entry
+func (NopLogger) Info(string, ...interface{}) {}
+
+//Warn - discards the entry
+func (NopLogger) Warn(string, ...interface{}) {}
+
+//Error - discards the entry
+func (NopLogger) Error(string, ...interface{}) {}
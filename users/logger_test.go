@@ -0,0 +1,65 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+//logEntry - an entry written to a recordingLogger
+type logEntry struct {
+	level   string
+	msg     string
+	keyvals []interface{}
+}
+
+//recordingLogger keeps the entries written to it
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) log(level, msg string, keyvals []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, keyvals: keyvals})
+}
+
+func (l *recordingLogger) Info(msg string, keyvals ...interface{}) { l.log("info", msg, keyvals) }
+
+func (l *recordingLogger) Warn(msg string, keyvals ...interface{}) { l.log("warn", msg, keyvals) }
+
+func (l *recordingLogger) Error(msg string, keyvals ...interface{}) { l.log("error", msg, keyvals) }
+
+//failingSink fails to write every audit event
+type failingSink struct{}
+
+func (failingSink) Write(ctx context.Context, e AuditEvent) error {
+	return errors.New("disk full")
+}
+
+func (failingSink) List(ctx context.Context, f AuditFilter) ([]AuditEvent, error) {
+	return nil, nil
+}
+
+func Test_Create_AuditFails_LogsWithoutPersonalData(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	logger := &recordingLogger{}
+	service := NewUserService(&repository, WithAuditSink(failingSink{}), WithLogger(logger))
+	repository.On("GetByEmail", context.Background(), "test@gmail.com").Return(User{}, nil)
+	repository.On("Add", context.Background(), mock.AnythingOfType("User")).Return(7, nil)
+	//Act
+	id, err := service.Create(context.Background(), User{Email: "test@gmail.com", Name: "John", LastName: "Connor"})
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, 7, id)
+	require.Len(t, logger.entries, 1)
+	assert.Equal(t, "error", logger.entries[0].level)
+	assert.Equal(t, []interface{}{"action", AuditCreate, "user_id", 7, "error", errors.New("disk full")}, logger.entries[0].keyvals)
+}
@@ -0,0 +1,27 @@
+package mappers
+
+import (
+	"github.com/casmelad/bootcamp-gateway/server/auth"
+	proto "github.com/casmelad/bootcamp-gateway/server/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//ToGrpcApiKey maps a domain api key to a grpc api key, the hash is never returned
+func ToGrpcApiKey(keyToMap auth.APIKey) (*proto.ApiKey, error) {
+
+	mapped := &proto.ApiKey{
+		Id:     keyToMap.ID,
+		Name:   keyToMap.Name,
+		Scopes: keyToMap.Scopes,
+	}
+
+	if !keyToMap.CreatedAt.IsZero() {
+		mapped.CreateTime = timestamppb.New(keyToMap.CreatedAt)
+	}
+
+	if keyToMap.Revoked() {
+		mapped.RevokeTime = timestamppb.New(keyToMap.RevokedAt)
+	}
+
+	return mapped, nil
+}
@@ -0,0 +1,41 @@
+package mappers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/server/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ToGrpcApiKey_ResultOk(t *testing.T) {
+
+	//Arrange
+	createdAt := time.Date(2021, 12, 1, 10, 30, 0, 0, time.UTC)
+	toMap := auth.APIKey{ID: "abc", Name: "batch", Hash: "secret-hash", Scopes: []string{"admin"}, CreatedAt: createdAt}
+
+	//Act
+	result, err := ToGrpcApiKey(toMap)
+
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", result.GetId())
+	assert.Equal(t, "batch", result.GetName())
+	assert.Equal(t, []string{"admin"}, result.GetScopes())
+	assert.Equal(t, createdAt, result.GetCreateTime().AsTime())
+	assert.Nil(t, result.GetRevokeTime())
+}
+
+func Test_ToGrpcApiKey_Revoked_ResultOk(t *testing.T) {
+
+	//Arrange
+	revokedAt := time.Date(2021, 12, 2, 10, 30, 0, 0, time.UTC)
+	toMap := auth.APIKey{ID: "abc", Name: "batch", RevokedAt: revokedAt}
+
+	//Act
+	result, err := ToGrpcApiKey(toMap)
+
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, revokedAt, result.GetRevokeTime().AsTime())
+}
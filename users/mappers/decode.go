@@ -3,6 +3,7 @@ package mappers
 import (
 	proto "github.com/casmelad/bootcamp-gateway/server/proto"
 	domain "github.com/casmelad/bootcamp-gateway/users"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 //ToDomainUser maps a grpc user to domain user
@@ -12,16 +13,68 @@ func ToDomainUser(userToMap proto.User) (domain.User, error) {
 		Email:    userToMap.Email,
 		Name:     userToMap.Name,
 		LastName: userToMap.LastName,
+		Version:  int(userToMap.Version),
 	}, nil
 }
 
 //ToGrpcUser maps a domain user to a grpc user
-func ToGrpcUser(userToMap domain.User) (proto.User, error) {
-	return proto.User{
-		Id:       int32(userToMap.ID),
-		Email:    userToMap.Email,
-		Name:     userToMap.Name,
-		LastName: userToMap.LastName,
-	}, nil
+func ToGrpcUser(userToMap domain.User) (*proto.User, error) {
+
+	mapped := &proto.User{
+		Id:        int32(userToMap.ID),
+		Email:     userToMap.Email,
+		Name:      userToMap.Name,
+		LastName:  userToMap.LastName,
+		Version:   int32(userToMap.Version),
+		CreatedBy: userToMap.CreatedBy,
+		UpdatedBy: userToMap.UpdatedBy,
+	}
 
+	if !userToMap.CreatedAt.IsZero() {
+		mapped.CreateTime = timestamppb.New(userToMap.CreatedAt)
+	}
+
+	if !userToMap.UpdatedAt.IsZero() {
+		mapped.UpdateTime = timestamppb.New(userToMap.UpdatedAt)
+	}
+
+	if userToMap.IsDeleted() {
+		mapped.DeletedAt = timestamppb.New(userToMap.DeletedAt)
+	}
+
+	return mapped, nil
+
+}
+
+//ToGrpcAuditEvent maps a domain audit event to a grpc audit event
+func ToGrpcAuditEvent(eventToMap domain.AuditEvent) (*proto.AuditEvent, error) {
+
+	mapped := &proto.AuditEvent{
+		Time:      timestamppb.New(eventToMap.Time),
+		Action:    eventToMap.Action,
+		UserId:    int32(eventToMap.UserID),
+		Actor:     eventToMap.Actor,
+		RequestId: eventToMap.RequestID,
+		Changes:   make([]*proto.FieldChange, 0, len(eventToMap.Changes)),
+	}
+
+	for _, c := range eventToMap.Changes {
+		mapped.Changes = append(mapped.Changes, &proto.FieldChange{Field: c.Field, Before: c.Before, After: c.After})
+	}
+
+	return mapped, nil
+}
+
+//ToGrpcUserChange maps a domain user change to a grpc user change
+func ToGrpcUserChange(changeToMap domain.Change) (proto.UserChange, error) {
+
+	usr, _ := ToGrpcUser(changeToMap.User)
+
+	return proto.UserChange{
+		Revision: changeToMap.Revision,
+		Type:     changeToMap.Type,
+		Time:     timestamppb.New(changeToMap.Time),
+		User:     usr,
+		Actor:    changeToMap.Actor,
+	}, nil
 }
@@ -2,6 +2,7 @@ package mappers
 
 import (
 	"testing"
+	"time"
 
 	proto "github.com/casmelad/bootcamp-gateway/server/proto"
 	domain "github.com/casmelad/bootcamp-gateway/users"
@@ -25,7 +26,7 @@ func Test_ToGrpcUser_ResultOk(t *testing.T) {
 
 	//Arrange
 	toMap := domain.User{ID: 999999}
-	expectedResult := proto.User{Id: 999999}
+	expectedResult := &proto.User{Id: 999999}
 
 	//Act
 	result, err := ToGrpcUser(toMap)
@@ -34,3 +35,63 @@ func Test_ToGrpcUser_ResultOk(t *testing.T) {
 	assert.Equal(t, expectedResult, result)
 	assert.Nil(t, err)
 }
+
+func Test_ToGrpcUser_MapsAuditFields(t *testing.T) {
+
+	//Arrange
+	createdAt := time.Date(2021, 12, 1, 10, 30, 0, 0, time.UTC)
+	updatedAt := createdAt.Add(time.Hour)
+	toMap := domain.User{ID: 1, CreatedAt: createdAt, UpdatedAt: updatedAt, CreatedBy: "owner@gmail.com", UpdatedBy: "admin@gmail.com"}
+
+	//Act
+	result, err := ToGrpcUser(toMap)
+
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, createdAt, result.GetCreateTime().AsTime())
+	assert.Equal(t, updatedAt, result.GetUpdateTime().AsTime())
+	assert.Equal(t, "owner@gmail.com", result.GetCreatedBy())
+	assert.Equal(t, "admin@gmail.com", result.GetUpdatedBy())
+	assert.Nil(t, result.GetDeletedAt())
+}
+
+func Test_ToGrpcAuditEvent_ResultOk(t *testing.T) {
+
+	//Arrange
+	at := time.Date(2021, 12, 1, 10, 30, 0, 0, time.UTC)
+	toMap := domain.AuditEvent{Time: at, Action: domain.AuditUpdate, UserID: 1, Actor: "admin@gmail.com", RequestID: "req-1",
+		Changes: []domain.AuditChange{{Field: domain.FieldName, Before: "John", After: "Jane"}}}
+
+	//Act
+	result, err := ToGrpcAuditEvent(toMap)
+
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, at, result.GetTime().AsTime())
+	assert.Equal(t, "update", result.GetAction())
+	assert.Equal(t, int32(1), result.GetUserId())
+	assert.Equal(t, "admin@gmail.com", result.GetActor())
+	assert.Equal(t, "req-1", result.GetRequestId())
+	assert.Len(t, result.GetChanges(), 1)
+	assert.Equal(t, "Jane", result.GetChanges()[0].GetAfter())
+}
+
+func Test_ToGrpcUserChange_ResultOk(t *testing.T) {
+
+	//Arrange
+	at := time.Date(2021, 12, 1, 10, 30, 0, 0, time.UTC)
+	toMap := domain.Change{Revision: 12, Event: domain.Event{Type: domain.EventUserUpdated, Time: at, UserID: 1, Actor: "admin@gmail.com",
+		User: domain.User{ID: 1, Email: "test@gmail.com", Version: 2}}}
+
+	//Act
+	result, err := ToGrpcUserChange(toMap)
+
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12), result.GetRevision())
+	assert.Equal(t, "UserUpdated", result.GetType())
+	assert.Equal(t, at, result.GetTime().AsTime())
+	assert.Equal(t, "test@gmail.com", result.GetUser().GetEmail())
+	assert.Equal(t, int32(2), result.GetUser().GetVersion())
+	assert.Equal(t, "admin@gmail.com", result.GetActor())
+}
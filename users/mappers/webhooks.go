@@ -0,0 +1,55 @@
+package mappers
+
+import (
+	proto "github.com/casmelad/bootcamp-gateway/server/proto"
+	"github.com/casmelad/bootcamp-gateway/users/webhooks"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//ToDomainWebhook maps a grpc webhook to a domain webhook
+func ToDomainWebhook(webhookToMap *proto.Webhook) (webhooks.Webhook, error) {
+	return webhooks.Webhook{
+		URL:        webhookToMap.Url,
+		EventTypes: webhookToMap.EventTypes,
+		Secret:     webhookToMap.Secret,
+	}, nil
+}
+
+//ToGrpcWebhook maps a domain webhook to a grpc webhook
+func ToGrpcWebhook(webhookToMap webhooks.Webhook) (*proto.Webhook, error) {
+
+	mapped := &proto.Webhook{
+		Id:         webhookToMap.ID,
+		Url:        webhookToMap.URL,
+		EventTypes: webhookToMap.EventTypes,
+		Secret:     webhookToMap.Secret,
+	}
+
+	if !webhookToMap.CreatedAt.IsZero() {
+		mapped.CreateTime = timestamppb.New(webhookToMap.CreatedAt)
+	}
+
+	return mapped, nil
+}
+
+//ToGrpcDeadLetter maps a failed domain delivery to a grpc dead letter
+func ToGrpcDeadLetter(letterToMap webhooks.DeadLetter) (*proto.DeadLetter, error) {
+
+	usr, _ := ToGrpcUser(letterToMap.Payload.User)
+
+	return &proto.DeadLetter{
+		DeliveryId: letterToMap.DeliveryID,
+		WebhookId:  letterToMap.WebhookID,
+		Url:        letterToMap.URL,
+		Change: &proto.UserChange{
+			Revision: letterToMap.Payload.Revision,
+			Type:     letterToMap.Payload.Type,
+			Time:     timestamppb.New(letterToMap.Payload.Time),
+			User:     usr,
+			Actor:    letterToMap.Payload.Actor,
+		},
+		Attempts:  int32(letterToMap.Attempts),
+		LastError: letterToMap.LastError,
+		FailTime:  timestamppb.New(letterToMap.FailedAt),
+	}, nil
+}
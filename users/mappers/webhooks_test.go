@@ -0,0 +1,61 @@
+package mappers
+
+import (
+	"testing"
+	"time"
+
+	proto "github.com/casmelad/bootcamp-gateway/server/proto"
+	domain "github.com/casmelad/bootcamp-gateway/users"
+	"github.com/casmelad/bootcamp-gateway/users/webhooks"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ToDomainWebhook_ResultOk(t *testing.T) {
+
+	//Arrange
+	toMap := &proto.Webhook{Id: "ignored", Url: "https://partner.example.com", EventTypes: []string{"UserCreated"}, Secret: "s3cr3t"}
+	expectedResult := webhooks.Webhook{URL: "https://partner.example.com", EventTypes: []string{"UserCreated"}, Secret: "s3cr3t"}
+
+	//Act
+	result, err := ToDomainWebhook(toMap)
+
+	//Assert
+	assert.Equal(t, expectedResult, result)
+	assert.Nil(t, err)
+}
+
+func Test_ToGrpcWebhook_ResultOk(t *testing.T) {
+
+	//Arrange
+	createdAt := time.Date(2021, 12, 1, 10, 30, 0, 0, time.UTC)
+	toMap := webhooks.Webhook{ID: "abc", URL: "https://partner.example.com", CreatedAt: createdAt}
+
+	//Act
+	result, err := ToGrpcWebhook(toMap)
+
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", result.GetId())
+	assert.Equal(t, "https://partner.example.com", result.GetUrl())
+	assert.Equal(t, createdAt, result.GetCreateTime().AsTime())
+}
+
+func Test_ToGrpcDeadLetter_ResultOk(t *testing.T) {
+
+	//Arrange
+	failedAt := time.Date(2021, 12, 1, 10, 30, 0, 0, time.UTC)
+	toMap := webhooks.DeadLetter{DeliveryID: "42-abc", WebhookID: "abc", URL: "https://partner.example.com", Attempts: 5,
+		LastError: "webhook answered 503", FailedAt: failedAt,
+		Payload: webhooks.Payload{Revision: 42, Type: domain.EventUserCreated, User: domain.User{ID: 7}}}
+
+	//Act
+	result, err := ToGrpcDeadLetter(toMap)
+
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "42-abc", result.GetDeliveryId())
+	assert.Equal(t, int32(5), result.GetAttempts())
+	assert.Equal(t, int64(42), result.GetChange().GetRevision())
+	assert.Equal(t, int32(7), result.GetChange().GetUser().GetId())
+	assert.Equal(t, failedAt, result.GetFailTime().AsTime())
+}
@@ -4,7 +4,7 @@ import "context"
 
 //Repository - repository interface for users
 type Repository interface {
-	//Add - adds a user to the repository
+	//Add - adds a user to the repository with version 1
 	Add(context.Context, User) (int, error)
 	//GetByID - retrieves a user from the repository based on the integer id
 	GetByID(context.Context, int) (User, error)
@@ -12,8 +12,19 @@ type Repository interface {
 	GetByEmail(context.Context, string) (User, error)
 	//GetAll - retrieves all the users from the repository
 	GetAll(context.Context) ([]User, error)
-	//Update -  updates the information of a user
+	//List - retrieves the users matching the options ordered by id
+	List(context.Context, ListOptions) ([]User, error)
+	//Count - counts the users matching the filter
+	Count(context.Context, Filter) (int, error)
+	//Update -  updates the information of a user, including its soft deletion, and increments its version,
+	//ErrVersionMismatch is returned when the stored version differs from the given one unless it is 0
 	Update(context.Context, User) error
-	//Delete - deletes a user from the repository
-	Delete(context.Context, int) error
+	//Delete - permanently deletes a user from the repository, ErrVersionMismatch is returned when the
+	//stored version differs from the given one unless it is 0
+	Delete(context.Context, int, int) error
+}
+
+//Pinger - checks that the storage of a repository can be reached
+type Pinger interface {
+	Ping(context.Context) error
 }
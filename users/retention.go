@@ -0,0 +1,35 @@
+package users
+
+import (
+	"context"
+	"time"
+)
+
+//Purger - permanently removes the users soft deleted before an instant
+type Purger interface {
+	PurgeDeleted(context.Context, time.Time) (int, error)
+}
+
+//RunRetention - purges the users soft deleted longer than retention ago, right away and then
+//every interval, until the context is done, the purges and their failures are reported to the logger
+func RunRetention(ctx context.Context, purger Purger, retention, interval time.Duration, logger Logger) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := purger.PurgeDeleted(ctx, time.Now().Add(-retention))
+
+		if err != nil {
+			logger.Error("purging deleted users failed", "error", err)
+		} else if purged > 0 {
+			logger.Info("purged deleted users", "purged", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package users
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type purgerFunc func(context.Context, time.Time) (int, error)
+
+func (f purgerFunc) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	return f(ctx, before)
+}
+
+func Test_RunRetention_PurgesOlderThanRetention(t *testing.T) {
+	//Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	retention := 24 * time.Hour
+	var cutoffs []time.Time
+	purger := purgerFunc(func(ctx context.Context, before time.Time) (int, error) {
+		cutoffs = append(cutoffs, before)
+		if len(cutoffs) == 2 {
+			cancel()
+		}
+		return 1, nil
+	})
+	start := time.Now()
+	logger := &recordingLogger{}
+	//Act
+	RunRetention(ctx, purger, retention, time.Millisecond, logger)
+	//Assert
+	assert.Len(t, cutoffs, 2)
+	assert.Len(t, logger.entries, 2)
+	assert.Equal(t, []interface{}{"purged", 1}, logger.entries[0].keyvals)
+	assert.WithinDuration(t, start.Add(-retention), cutoffs[0], time.Second)
+}
@@ -2,7 +2,8 @@ package users
 
 import (
 	"context"
-	"fmt"
+	"sync"
+	"time"
 
 	"gopkg.in/go-playground/validator.v9"
 )
@@ -12,18 +13,64 @@ type Service interface {
 	Create(context.Context, User) (int, error)
 	GetByEmail(context.Context, string) (User, error)
 	GetAll(context.Context) ([]User, error)
-	Update(context.Context, User) error
-	Delete(context.Context, int) error
+	List(context.Context, ListRequest) (Page, error)
+	Update(context.Context, User, []string) (User, error)
+	Delete(context.Context, int, int) error
+	Undelete(context.Context, int, int) (User, error)
+	Purge(context.Context, int, int) error
+	ListAuditEvents(context.Context, AuditFilter) ([]AuditEvent, error)
+	Watch(context.Context, int64) (<-chan Change, error)
 }
 
 //UserService - the implementation for the users logic
 type UserService struct {
 	repository Repository
+	auditSink  AuditSink
+	//transactor stores the events along with the user writes, nil when no events are published
+	transactor Transactor
+	//broadcaster delivers the changes to the watchers once they are stored
+	broadcaster *Broadcaster
+	//writes serializes the writes and the publishing of their changes so the revisions follow the commits
+	writes sync.Mutex
+	logger Logger
+}
+
+//Option - configures optional behavior of a UserService
+type Option func(*UserService)
+
+//WithAuditSink - records every change made to the users in the sink
+func WithAuditSink(sink AuditSink) Option {
+	return func(us *UserService) {
+		us.auditSink = sink
+	}
+}
+
+//WithOutbox - records an event for every change in the outbox of the transactor, in the same
+//transaction as the change, the transactor is usually the repository itself
+func WithOutbox(transactor Transactor) Option {
+	return func(us *UserService) {
+		us.transactor = transactor
+	}
+}
+
+//WithLogger - reports the audit events that could not be written to the logger
+func WithLogger(logger Logger) Option {
+	return func(us *UserService) {
+		us.logger = logger
+	}
 }
 
 //NewUserService - returns a UserService type pointer
-func NewUserService(repo Repository) *UserService {
-	return &UserService{repository: repo}
+func NewUserService(repo Repository, opts ...Option) *UserService {
+
+	us := &UserService{repository: repo, auditSink: nopAuditSink{}, broadcaster: NewBroadcaster(DefaultWatchHistory),
+		logger: NopLogger{}}
+
+	for _, opt := range opts {
+		opt(us)
+	}
+
+	return us
 }
 
 //Create - validates business rules and sends a user to the repository
@@ -46,17 +93,49 @@ func (us *UserService) Create(ctx context.Context, usr User) (int, error) {
 		return 0, ErrUserAlreadyExists
 	}
 
-	newID, errAdd := us.repository.Add(ctx, usr)
+	usr.CreatedAt = now()
+	usr.CreatedBy = ActorFromContext(ctx)
+	usr.UpdatedAt = usr.CreatedAt
+	usr.UpdatedBy = usr.CreatedBy
+
+	created, errAdd := us.commit(ctx, func(repo Repository, outbox Outbox) (Event, error) {
+		id, err := repo.Add(ctx, usr)
+
+		if err != nil {
+			return Event{}, err
+		}
+
+		//the email was taken by a concurrent create after it was checked
+		if id == 0 {
+			return Event{}, ErrUserAlreadyExists
+		}
+
+		stored := usr
+		stored.ID = id
+		stored.Version = 1
+		event := newEvent(ctx, AuditCreate, stored)
+
+		return event, outbox.Append(ctx, event)
+	})
 
 	if errAdd != nil {
+		if errAdd == ErrUserAlreadyExists {
+			return 0, errAdd
+		}
 		return 0, ErrInternalError
 	}
 
+	newID := created.UserID
+
+	usr.ID = newID
+	usr.Version = 1
+	us.audit(ctx, AuditCreate, User{}, usr)
+
 	return newID, nil
 
 }
 
-//GetByEmail - retrieves the information of a user based on the email address
+//GetByEmail - retrieves the information of a user based on the email address, soft deleted users are not found
 func (us *UserService) GetByEmail(ctx context.Context, email string) (User, error) {
 
 	dbUser, err := us.repository.GetByEmail(ctx, email)
@@ -65,7 +144,7 @@ func (us *UserService) GetByEmail(ctx context.Context, email string) (User, erro
 		return User{}, err
 	}
 
-	if dbUser.ID == 0 {
+	if dbUser.ID == 0 || dbUser.IsDeleted() {
 		return User{}, ErrNotFound
 	}
 
@@ -73,7 +152,7 @@ func (us *UserService) GetByEmail(ctx context.Context, email string) (User, erro
 
 }
 
-//GetAll -  gets all the existing users
+//GetAll -  gets all the existing users but the soft deleted ones
 func (us *UserService) GetAll(ctx context.Context) ([]User, error) {
 
 	users, err := us.repository.GetAll(ctx)
@@ -82,58 +161,368 @@ func (us *UserService) GetAll(ctx context.Context) ([]User, error) {
 		return []User{}, ErrInternalError
 	}
 
-	return users, nil
+	active := make([]User, 0, len(users))
+
+	for _, u := range users {
+		if !u.IsDeleted() {
+			active = append(active, u)
+		}
+	}
+
+	return active, nil
 }
 
-//Update - validates the data and updates the user information
-func (us *UserService) Update(ctx context.Context, usr User) error {
+//List - retrieves a page of the users matching the filter, ordered by id
+func (us *UserService) List(ctx context.Context, req ListRequest) (Page, error) {
 
-	/* v := validator.New()
+	if req.PageSize < 0 {
+		return Page{}, ErrInvalidData
+	}
 
-	if errVal := v.Struct(usr); errVal != nil {
-		return ErrInvalidData
-	} */
-	fmt.Println(usr)
+	pageSize := req.PageSize
+
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+
+	afterID, err := decodePageToken(req.PageToken)
+
+	if err != nil {
+		return Page{}, err
+	}
+
+	//one extra user tells whether there is a next page
+	users, err := us.repository.List(ctx, ListOptions{AfterID: afterID, Limit: pageSize + 1, Filter: req.Filter})
+
+	if err != nil {
+		return Page{}, ErrInternalError
+	}
+
+	total, err := us.repository.Count(ctx, req.Filter)
+
+	if err != nil {
+		return Page{}, ErrInternalError
+	}
+
+	if len(users) <= pageSize {
+		return Page{Users: users, TotalSize: total}, nil
+	}
+
+	users = users[:pageSize]
+
+	return Page{Users: users, NextPageToken: encodePageToken(users[pageSize-1].ID), TotalSize: total}, nil
+}
+
+//Update - validates the given fields and updates them in the user information,
+//every updatable field is changed when no fields are given, changing the email to
+//one used by another user returns ErrUserAlreadyExists.
+//The version of the given user is the one the caller expects to change, 0 skips the check
+func (us *UserService) Update(ctx context.Context, usr User, fields []string) (User, error) {
+
+	if len(fields) == 0 {
+		fields = allUpdatableFields
+	}
+
+	structFields := make([]string, 0, len(fields))
+
+	for _, f := range fields {
+		field, ok := updatableFields[f]
+		if !ok {
+			return User{}, ErrInvalidData
+		}
+		structFields = append(structFields, field.structField)
+	}
+
+	v := validator.New()
+
+	if errVal := v.StructPartial(usr, structFields...); errVal != nil {
+		return User{}, ErrInvalidData
+	}
 
 	usrToUpdate, errU := us.repository.GetByID(ctx, usr.ID)
 
 	if errU != nil {
-		return errU
+		return User{}, errU
+	}
+
+	if usrToUpdate.ID == 0 || usrToUpdate.IsDeleted() {
+		return User{}, ErrNotFound
+	}
+
+	if usr.Version != 0 && usr.Version != usrToUpdate.Version {
+		return User{}, ErrVersionMismatch
+	}
+
+	before := usrToUpdate
+
+	for _, f := range fields {
+		updatableFields[f].copy(&usrToUpdate, usr)
+	}
+
+	stampUpdate(ctx, &usrToUpdate)
+
+	return us.save(ctx, AuditUpdate, before, usrToUpdate)
+}
+
+//Delete - soft deletes a user, it is hidden until restored with Undelete or removed with Purge.
+//The version is the one the caller expects to delete, 0 skips the check
+func (us *UserService) Delete(ctx context.Context, usrID int, version int) error {
+
+	usrToDelete, err := us.getForChange(ctx, usrID, version)
+
+	if err != nil {
+		return err
 	}
 
-	if usrToUpdate.ID == 0 {
+	if usrToDelete.IsDeleted() {
 		return ErrNotFound
 	}
 
-	usr.ID = usrToUpdate.ID
+	before := usrToDelete
+	usrToDelete.DeletedAt = now()
+	stampUpdate(ctx, &usrToDelete)
 
-	if err := us.repository.Update(ctx, usr); err != nil {
-		return ErrInternalError
+	_, err = us.save(ctx, AuditDelete, before, usrToDelete)
+
+	return err
+}
+
+//Undelete - restores a soft deleted user, the version is the one the caller expects to restore, 0 skips the check
+func (us *UserService) Undelete(ctx context.Context, usrID int, version int) (User, error) {
+
+	usrToRestore, err := us.getForChange(ctx, usrID, version)
+
+	if err != nil {
+		return User{}, err
 	}
 
-	return nil
+	if !usrToRestore.IsDeleted() {
+		return User{}, ErrNotDeleted
+	}
+
+	before := usrToRestore
+	usrToRestore.DeletedAt = time.Time{}
+	stampUpdate(ctx, &usrToRestore)
+
+	return us.save(ctx, AuditUndelete, before, usrToRestore)
+}
+
+//Purge - permanently removes a soft deleted user, the version is the one the caller expects to remove, 0 skips the check
+func (us *UserService) Purge(ctx context.Context, usrID int, version int) error {
+
+	usrToPurge, err := us.getForChange(ctx, usrID, version)
+
+	if err != nil {
+		return err
+	}
+
+	if !usrToPurge.IsDeleted() {
+		return ErrNotDeleted
+	}
+
+	return us.purge(ctx, usrToPurge)
+}
+
+//PurgeDeleted - permanently removes the users soft deleted before the given instant and returns how many,
+//users restored in the meantime are kept
+func (us *UserService) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+
+	filter := Filter{ShowDeleted: true, DeletedBefore: before}
+	purged, afterID := 0, 0
+
+	for {
+		users, err := us.repository.List(ctx, ListOptions{AfterID: afterID, Limit: DefaultPageSize, Filter: filter})
+
+		if err != nil {
+			return purged, ErrInternalError
+		}
+
+		for _, u := range users {
+			err := us.purge(ctx, u)
+
+			if err == ErrVersionMismatch {
+				continue
+			}
+
+			if err != nil {
+				return purged, err
+			}
+
+			purged++
+		}
+
+		if len(users) < DefaultPageSize {
+			return purged, nil
+		}
+
+		afterID = users[len(users)-1].ID
+	}
 }
 
-//Delete - removes a user
-func (us *UserService) Delete(ctx context.Context, usrID int) error {
+//getForChange retrieves the user about to be changed and checks the version the caller expects
+func (us *UserService) getForChange(ctx context.Context, usrID int, version int) (User, error) {
 
 	if usrID < 1 {
-		return ErrInvalidData
+		return User{}, ErrInvalidData
 	}
 
-	usrToUpdate, err := us.repository.GetByID(ctx, usrID)
+	usr, err := us.repository.GetByID(ctx, usrID)
 
 	if err != nil {
-		return err
+		return User{}, err
 	}
 
-	if usrToUpdate.ID == 0 {
-		return ErrNotFound
+	if usr.ID == 0 {
+		return User{}, ErrNotFound
+	}
+
+	if version != 0 && version != usr.Version {
+		return User{}, ErrVersionMismatch
+	}
+
+	return usr, nil
+}
+
+//ListAuditEvents - retrieves the recorded changes matching the filter in the order they were made
+func (us *UserService) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+
+	events, err := us.auditSink.List(ctx, filter)
+
+	if err != nil {
+		return nil, ErrInternalError
+	}
+
+	return events, nil
+}
+
+//Watch - returns a channel receiving the changes made after the given revision followed by the new ones,
+//0 receives only the new ones. The channel is closed when the context is done or the watcher falls behind,
+//in which case it can watch again after the last revision received
+func (us *UserService) Watch(ctx context.Context, afterRevision int64) (<-chan Change, error) {
+	return us.broadcaster.Subscribe(ctx, afterRevision)
+}
+
+//save writes the changed user guarded by the version it was read with, records the change
+//and returns the user with its new version
+func (us *UserService) save(ctx context.Context, action string, before, after User) (User, error) {
+
+	saved := after
+	saved.Version++
+	event := newEvent(ctx, action, saved)
+
+	_, err := us.commit(ctx, func(repo Repository, outbox Outbox) (Event, error) {
+		if err := repo.Update(ctx, after); err != nil {
+			return Event{}, err
+		}
+		return event, outbox.Append(ctx, event)
+	})
+
+	if err != nil {
+		if err == ErrUserAlreadyExists || err == ErrVersionMismatch {
+			return User{}, err
+		}
+		return User{}, ErrInternalError
 	}
 
-	if errD := us.repository.Delete(ctx, usrID); errD != nil {
+	after = saved
+	us.audit(ctx, action, before, after)
+
+	return after, nil
+}
+
+//purge permanently removes the user guarded by the version it was read with and records it
+func (us *UserService) purge(ctx context.Context, usr User) error {
+
+	event := newEvent(ctx, AuditPurge, usr)
+
+	_, err := us.commit(ctx, func(repo Repository, outbox Outbox) (Event, error) {
+		if err := repo.Delete(ctx, usr.ID, usr.Version); err != nil {
+			return Event{}, err
+		}
+		return event, outbox.Append(ctx, event)
+	})
+
+	if err != nil {
+		if err == ErrVersionMismatch {
+			return err
+		}
 		return ErrInternalError
 	}
 
+	us.audit(ctx, AuditPurge, usr, User{})
+
 	return nil
 }
+
+//commit runs fn in a transaction and publishes the event it stored once committed, the writes are serialized
+//so the watchers receive the changes in the order they were committed
+func (us *UserService) commit(ctx context.Context, fn func(Repository, Outbox) (Event, error)) (Event, error) {
+
+	us.writes.Lock()
+	defer us.writes.Unlock()
+
+	var event Event
+
+	err := us.transact(ctx, func(repo Repository, outbox Outbox) error {
+		var err error
+		event, err = fn(repo, outbox)
+		return err
+	})
+
+	if err != nil {
+		return Event{}, err
+	}
+
+	us.broadcaster.Publish(event)
+
+	return event, nil
+}
+
+//transact runs fn in a transaction of the transactor, without one fn writes straight to the repository
+//and its events are dropped
+func (us *UserService) transact(ctx context.Context, fn func(Repository, Outbox) error) error {
+
+	if us.transactor == nil {
+		return fn(us.repository, nopOutbox{})
+	}
+
+	return us.transactor.WithinTransaction(ctx, fn)
+}
+
+//audit records a change already stored, a failure to write it is logged since it cannot be undone
+func (us *UserService) audit(ctx context.Context, action string, before, after User) {
+
+	userID := after.ID
+
+	if userID == 0 {
+		userID = before.ID
+	}
+
+	event := AuditEvent{
+		Time:      now(),
+		Action:    action,
+		UserID:    userID,
+		Actor:     ActorFromContext(ctx),
+		RequestID: RequestIDFromContext(ctx),
+		Changes:   diffUsers(before, after),
+	}
+
+	if err := us.auditSink.Write(ctx, event); err != nil {
+		us.logger.Error("writing audit event failed", "action", action, "user_id", userID, "error", err)
+	}
+}
+
+//stampUpdate records when and by whom the user is being changed
+func stampUpdate(ctx context.Context, usr *User) {
+	usr.UpdatedAt = now()
+	usr.UpdatedBy = ActorFromContext(ctx)
+}
+
+//now returns the current time with the precision every repository can store
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
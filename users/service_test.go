@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -23,8 +24,8 @@ func (r *repositoryMock) Update(ctx context.Context, u User) error {
 	return args.Error(0)
 }
 
-func (r *repositoryMock) Delete(ctx context.Context, uid int) error {
-	args := r.Called(ctx, uid)
+func (r *repositoryMock) Delete(ctx context.Context, uid int, version int) error {
+	args := r.Called(ctx, uid, version)
 	return args.Error(0)
 }
 
@@ -43,12 +44,36 @@ func (r *repositoryMock) GetAll(ctx context.Context) ([]User, error) {
 	return args.Get(0).([]User), args.Error(1)
 }
 
+func (r *repositoryMock) List(ctx context.Context, opts ListOptions) ([]User, error) {
+	args := r.Called(ctx, opts)
+	return args.Get(0).([]User), args.Error(1)
+}
+
+func (r *repositoryMock) Count(ctx context.Context, f Filter) (int, error) {
+	args := r.Called(ctx, f)
+	return args.Int(0), args.Error(1)
+}
+
+//stamped matches the user once the service recorded the change time
+func stamped(expected User) interface{} {
+	return mock.MatchedBy(func(u User) bool {
+		if u.UpdatedAt.IsZero() {
+			return false
+		}
+		u.UpdatedAt = time.Time{}
+		return u == expected
+	})
+}
+
 func Test_Create_ValidData_OkResult(t *testing.T) {
 	//Arrange
 	repository := repositoryMock{}
 	service := NewUserService(&repository)
 	userToAdd := User{Email: "test@gmail.com", Name: "John", LastName: "Connor"}
-	repository.On("Add", context.Background(), userToAdd).Return(1, nil)
+	withCreationTime := mock.MatchedBy(func(u User) bool {
+		return u.Email == userToAdd.Email && !u.CreatedAt.IsZero() && u.UpdatedAt == u.CreatedAt
+	})
+	repository.On("Add", context.Background(), withCreationTime).Return(1, nil)
 	repository.On("GetByEmail", context.Background(), userToAdd.Email).Return(User{}, nil)
 	//Act
 	result, err := service.Create(context.Background(), userToAdd)
@@ -60,6 +85,23 @@ func Test_Create_ValidData_OkResult(t *testing.T) {
 	repository.AssertNumberOfCalls(t, "GetByEmail", 1)
 }
 
+func Test_Create_RecordsCaller(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	ctx := WithActor(context.Background(), "admin@gmail.com")
+	byCaller := mock.MatchedBy(func(u User) bool {
+		return u.CreatedBy == "admin@gmail.com" && u.UpdatedBy == "admin@gmail.com"
+	})
+	repository.On("GetByEmail", ctx, "test@gmail.com").Return(User{}, nil)
+	repository.On("Add", ctx, byCaller).Return(1, nil)
+	//Act
+	_, err := service.Create(ctx, User{Email: "test@gmail.com", Name: "John", LastName: "Connor", CreatedBy: "someone@gmail.com"})
+	//Assert
+	assert.Nil(t, err)
+	repository.AssertExpectations(t)
+}
+
 func Test_Create_DuplicatedData_ReturnsAlreadyExistsError(t *testing.T) {
 	//Arrange
 	repository := repositoryMock{}
@@ -75,6 +117,26 @@ func Test_Create_DuplicatedData_ReturnsAlreadyExistsError(t *testing.T) {
 	repository.AssertNumberOfCalls(t, "GetByEmail", 1)
 }
 
+func Test_Create_ConcurrentDuplicate_ReturnsAlreadyExistsError(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	sink := &recordingSink{}
+	service := NewUserService(&repository, WithAuditSink(sink))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, _ := service.Watch(ctx, 0)
+	repository.On("GetByEmail", context.Background(), "test@gmail.com").Return(User{}, nil)
+	repository.On("Add", context.Background(), mock.AnythingOfType("User")).Return(0, nil)
+	//Act
+	result, err := service.Create(context.Background(), User{Email: "test@gmail.com", Name: "John", LastName: "Connor"})
+	//Assert
+	assert.Equal(t, 0, result)
+	assert.Equal(t, ErrUserAlreadyExists, err)
+	assert.Empty(t, sink.events)
+	assert.Len(t, w, 0)
+	repository.AssertExpectations(t)
+}
+
 func Test_Create_InvalidData_ReturnsError(t *testing.T) {
 	//Arrange
 	repository := repositoryMock{}
@@ -92,26 +154,47 @@ func Test_Update_ValidData_OkResult(t *testing.T) {
 	repository := repositoryMock{}
 	service := NewUserService(&repository)
 	userToUpdate := User{ID: 1, Email: "test@gmail.com", Name: "John", LastName: "Connor"}
-	repository.On("Update", context.Background(), userToUpdate).Return(nil)
-	repository.On("GetByEmail", context.Background(), userToUpdate.Email).Return(userToUpdate, nil)
+	repository.On("GetByID", context.Background(), userToUpdate.ID).Return(User{ID: 1, Email: "test@gmail.com", Name: "Sarah", Version: 1}, nil)
+	repository.On("Update", context.Background(), stamped(User{ID: 1, Email: "test@gmail.com", Name: "John", LastName: "Connor", Version: 1})).Return(nil)
 	//Act
-	err := service.Update(context.Background(), userToUpdate)
+	result, err := service.Update(context.Background(), userToUpdate, nil)
 	//Assert
 	assert.Nil(t, err)
+	assert.Equal(t, 2, result.Version)
 	repository.AssertExpectations(t)
-	repository.AssertNumberOfCalls(t, "GetByEmail", 1)
+	repository.AssertNumberOfCalls(t, "GetByID", 1)
 	repository.AssertNumberOfCalls(t, "Update", 1)
 }
 
+func Test_Update_RecordsCaller(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	ctx := WithActor(context.Background(), "admin@gmail.com")
+	createdAt := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	stored := User{ID: 1, Email: "test@gmail.com", Name: "John", LastName: "Connor", Version: 1, CreatedAt: createdAt, UpdatedAt: createdAt, CreatedBy: "owner@gmail.com"}
+	byCaller := mock.MatchedBy(func(u User) bool {
+		return u.UpdatedBy == "admin@gmail.com" && u.UpdatedAt.After(createdAt) && u.CreatedBy == "owner@gmail.com" && u.CreatedAt == createdAt
+	})
+	repository.On("GetByID", ctx, 1).Return(stored, nil)
+	repository.On("Update", ctx, byCaller).Return(nil)
+	//Act
+	result, err := service.Update(ctx, User{ID: 1, Name: "Kyle", UpdatedBy: "someone@gmail.com"}, []string{FieldName})
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "admin@gmail.com", result.UpdatedBy)
+	repository.AssertExpectations(t)
+}
+
 func Test_Update_InvalidData_ReturnsError(t *testing.T) {
 	//Arrange
 	repository := repositoryMock{}
 	service := NewUserService(&repository)
 	userToUpdate := User{ID: 1, Email: "test@gmail.com", Name: "", LastName: "Connor"}
 	//Act
-	err := service.Update(context.Background(), userToUpdate)
+	_, err := service.Update(context.Background(), userToUpdate, nil)
 	//Assert
-	assert.NotNil(t, err)
+	assert.Equal(t, ErrInvalidData, err)
 }
 
 func Test_Update_InvalidUser_ReturnsError(t *testing.T) {
@@ -119,28 +202,155 @@ func Test_Update_InvalidUser_ReturnsError(t *testing.T) {
 	repository := repositoryMock{}
 	service := NewUserService(&repository)
 	userToUpdate := User{ID: 1, Email: "test@gmail.com", Name: "John", LastName: "Connor"}
-	repository.On("GetByEmail", context.Background(), userToUpdate.Email).Return(User{}, errors.New(""))
+	repository.On("GetByID", context.Background(), userToUpdate.ID).Return(User{}, nil)
 	//Act
-	err := service.Update(context.Background(), userToUpdate)
+	_, err := service.Update(context.Background(), userToUpdate, nil)
 	//Assert
-	assert.NotNil(t, err)
+	assert.Equal(t, ErrNotFound, err)
 	repository.AssertExpectations(t)
-	repository.AssertNumberOfCalls(t, "GetByEmail", 1)
+	repository.AssertNumberOfCalls(t, "Update", 0)
+}
+
+func Test_Update_FieldMask_KeepsOtherFields(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	stored := User{ID: 1, Email: "test@gmail.com", Name: "John", LastName: "Connor"}
+	expected := User{ID: 1, Email: "test@gmail.com", Name: "Kyle", LastName: "Connor"}
+	repository.On("GetByID", context.Background(), 1).Return(stored, nil)
+	repository.On("Update", context.Background(), stamped(expected)).Return(nil)
+	//Act
+	_, err := service.Update(context.Background(), User{ID: 1, Name: "Kyle"}, []string{FieldName})
+	//Assert
+	assert.Nil(t, err)
+	repository.AssertExpectations(t)
+}
+
+func Test_Update_EmailInUse_ReturnsAlreadyExists(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	changes := User{ID: 1, Email: "taken@gmail.com"}
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Email: "test@gmail.com", Name: "John", LastName: "Connor"}, nil)
+	repository.On("Update", context.Background(), stamped(User{ID: 1, Email: "taken@gmail.com", Name: "John", LastName: "Connor"})).Return(ErrUserAlreadyExists)
+	//Act
+	_, err := service.Update(context.Background(), changes, []string{FieldEmail})
+	//Assert
+	assert.Equal(t, ErrUserAlreadyExists, err)
+	repository.AssertExpectations(t)
+}
+
+func Test_Update_FieldMask_InvalidEmail_ReturnsError(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	//Act
+	_, err := service.Update(context.Background(), User{ID: 1, Email: "not-an-email"}, []string{FieldEmail})
+	//Assert
+	assert.Equal(t, ErrInvalidData, err)
+	repository.AssertNumberOfCalls(t, "GetByID", 0)
+}
+
+func Test_Update_StaleVersion_ReturnsVersionMismatch(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Email: "test@gmail.com", Name: "John", LastName: "Connor", Version: 3}, nil)
+	//Act
+	_, err := service.Update(context.Background(), User{ID: 1, Name: "Kyle", Version: 2}, []string{FieldName})
+	//Assert
+	assert.Equal(t, ErrVersionMismatch, err)
+	repository.AssertNumberOfCalls(t, "Update", 0)
+}
+
+func Test_Update_ConcurrentChange_ReturnsVersionMismatch(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Email: "test@gmail.com", Name: "John", LastName: "Connor", Version: 3}, nil)
+	repository.On("Update", context.Background(), stamped(User{ID: 1, Email: "test@gmail.com", Name: "Kyle", LastName: "Connor", Version: 3})).Return(ErrVersionMismatch)
+	//Act
+	_, err := service.Update(context.Background(), User{ID: 1, Name: "Kyle", Version: 3}, []string{FieldName})
+	//Assert
+	assert.Equal(t, ErrVersionMismatch, err)
+	repository.AssertExpectations(t)
+}
+
+func Test_Update_FieldMask_UnknownField_ReturnsError(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	//Act
+	_, err := service.Update(context.Background(), User{ID: 1, Name: "Kyle"}, []string{"created_at"})
+	//Assert
+	assert.Equal(t, ErrInvalidData, err)
+	repository.AssertNumberOfCalls(t, "GetByID", 0)
+}
+
+func Test_Update_FieldMask_InvalidMaskedField_ReturnsError(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	//Act
+	_, err := service.Update(context.Background(), User{ID: 1, Name: "Kyle", LastName: ""}, []string{FieldLastName})
+	//Assert
+	assert.Equal(t, ErrInvalidData, err)
+	repository.AssertNumberOfCalls(t, "GetByID", 0)
 }
 
-func Test_Delete_ValidId_DeletesUser(t *testing.T) {
+func Test_Delete_ValidId_SoftDeletesUser(t *testing.T) {
 	//Arrange
 	repository := repositoryMock{}
 	service := NewUserService(&repository)
-	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1}, nil)
-	repository.On("Delete", context.Background(), 1).Return(nil)
+	softDeleted := mock.MatchedBy(func(u User) bool {
+		return u.ID == 1 && u.Version == 3 && u.IsDeleted()
+	})
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Version: 3}, nil)
+	repository.On("Update", context.Background(), softDeleted).Return(nil)
 	//Act
-	result := service.Delete(context.Background(), 1)
+	result := service.Delete(context.Background(), 1, 0)
 	//Assert
 	assert.Nil(t, result)
 	repository.AssertExpectations(t)
 	repository.AssertNumberOfCalls(t, "GetByID", 1)
-	repository.AssertNumberOfCalls(t, "Delete", 1)
+	repository.AssertNumberOfCalls(t, "Delete", 0)
+}
+
+func Test_Delete_AlreadyDeleted_ReturnsNotFound(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Version: 3, DeletedAt: time.Now()}, nil)
+	//Act
+	result := service.Delete(context.Background(), 1, 0)
+	//Assert
+	assert.Equal(t, ErrNotFound, result)
+	repository.AssertNumberOfCalls(t, "Update", 0)
+}
+
+func Test_Delete_StaleVersion_ReturnsVersionMismatch(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Version: 3}, nil)
+	//Act
+	result := service.Delete(context.Background(), 1, 2)
+	//Assert
+	assert.Equal(t, ErrVersionMismatch, result)
+	repository.AssertNumberOfCalls(t, "Delete", 0)
+}
+
+func Test_Delete_ConcurrentChange_ReturnsVersionMismatch(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Version: 3}, nil)
+	repository.On("Update", context.Background(), mock.AnythingOfType("User")).Return(ErrVersionMismatch)
+	//Act
+	result := service.Delete(context.Background(), 1, 3)
+	//Assert
+	assert.Equal(t, ErrVersionMismatch, result)
+	repository.AssertExpectations(t)
 }
 
 func Test_Delete_InvalidId_ReturnsError(t *testing.T) {
@@ -148,10 +358,10 @@ func Test_Delete_InvalidId_ReturnsError(t *testing.T) {
 	repository := repositoryMock{}
 	service := NewUserService(&repository)
 	//Act
-	result := service.Delete(context.Background(), 0)
+	result := service.Delete(context.Background(), 0, 0)
 	//Assert
 	assert.NotNil(t, result)
-	assert.Equal(t, "invalid id", result.Error())
+	assert.Equal(t, ErrInvalidData, result)
 }
 
 func Test_Delete_InvalidId_ReturnsNotFoundError(t *testing.T) {
@@ -160,7 +370,7 @@ func Test_Delete_InvalidId_ReturnsNotFoundError(t *testing.T) {
 	service := NewUserService(&repository)
 	repository.On("GetByID", context.Background(), 999).Return(User{}, nil)
 	//Act
-	result := service.Delete(context.Background(), 999)
+	result := service.Delete(context.Background(), 999, 0)
 	//Assert
 	assert.NotNil(t, result)
 	assert.Equal(t, "user not found", result.Error())
@@ -168,6 +378,98 @@ func Test_Delete_InvalidId_ReturnsNotFoundError(t *testing.T) {
 	repository.AssertNumberOfCalls(t, "GetByID", 1)
 }
 
+func Test_Undelete_DeletedUser_RestoresUser(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Version: 3, DeletedAt: time.Now()}, nil)
+	repository.On("Update", context.Background(), stamped(User{ID: 1, Version: 3})).Return(nil)
+	//Act
+	result, err := service.Undelete(context.Background(), 1, 3)
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, 4, result.Version)
+	assert.False(t, result.IsDeleted())
+	repository.AssertExpectations(t)
+}
+
+func Test_Undelete_ActiveUser_ReturnsNotDeleted(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Version: 3}, nil)
+	//Act
+	_, err := service.Undelete(context.Background(), 1, 0)
+	//Assert
+	assert.Equal(t, ErrNotDeleted, err)
+	repository.AssertNumberOfCalls(t, "Update", 0)
+}
+
+func Test_Purge_DeletedUser_RemovesUser(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Version: 3, DeletedAt: time.Now()}, nil)
+	repository.On("Delete", context.Background(), 1, 3).Return(nil)
+	//Act
+	err := service.Purge(context.Background(), 1, 0)
+	//Assert
+	assert.Nil(t, err)
+	repository.AssertExpectations(t)
+}
+
+func Test_Purge_ActiveUser_ReturnsNotDeleted(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	repository.On("GetByID", context.Background(), 1).Return(User{ID: 1, Version: 3}, nil)
+	//Act
+	err := service.Purge(context.Background(), 1, 0)
+	//Assert
+	assert.Equal(t, ErrNotDeleted, err)
+	repository.AssertNumberOfCalls(t, "Delete", 0)
+}
+
+func Test_PurgeDeleted_SkipsRestoredUsers(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	before := time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)
+	expired := []User{{ID: 1, Version: 2}, {ID: 2, Version: 5}}
+	repository.On("List", context.Background(), ListOptions{Limit: DefaultPageSize, Filter: Filter{ShowDeleted: true, DeletedBefore: before}}).Return(expired, nil)
+	repository.On("Delete", context.Background(), 1, 2).Return(nil)
+	repository.On("Delete", context.Background(), 2, 5).Return(ErrVersionMismatch)
+	//Act
+	purged, err := service.PurgeDeleted(context.Background(), before)
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, 1, purged)
+	repository.AssertExpectations(t)
+}
+
+func Test_GetByEmail_SoftDeleted_ReturnsErrorNotFound(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	repository.On("GetByEmail", context.Background(), "test@gmail.com").Return(User{ID: 1, Email: "test@gmail.com", DeletedAt: time.Now()}, nil)
+	//Act
+	_, err := service.GetByEmail(context.Background(), "test@gmail.com")
+	//Assert
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func Test_GetAll_HidesSoftDeleted(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	repository.On("GetAll", context.Background()).Return([]User{{ID: 1}, {ID: 2, DeletedAt: time.Now()}}, nil)
+	//Act
+	result, err := service.GetAll(context.Background())
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []User{{ID: 1}}, result)
+}
+
 func Test_GetByEmail_ValidId_ReturnsData(t *testing.T) {
 	//Arrange
 	repository := repositoryMock{}
@@ -210,6 +512,71 @@ func Test_GetAll_ReturnsNoError(t *testing.T) {
 	repository.AssertNumberOfCalls(t, "GetAll", 1)
 }
 
+func Test_List_DefaultPageSize_ReturnsNextPageToken(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	stored := make([]User, DefaultPageSize+1)
+	for i := range stored {
+		stored[i] = User{ID: i + 1}
+	}
+	repository.On("List", context.Background(), ListOptions{Limit: DefaultPageSize + 1}).Return(stored, nil)
+	repository.On("Count", context.Background(), Filter{}).Return(250, nil)
+	//Act
+	result, err := service.List(context.Background(), ListRequest{})
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, stored[:DefaultPageSize], result.Users)
+	assert.NotEmpty(t, result.NextPageToken)
+	assert.Equal(t, 250, result.TotalSize)
+	repository.AssertExpectations(t)
+}
+
+func Test_List_WithPageToken_ContinuesAfterLastUser(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	filter := Filter{EmailPrefix: "test"}
+	repository.On("List", context.Background(), ListOptions{Limit: 3, Filter: filter}).Return([]User{{ID: 1}, {ID: 2}, {ID: 3}}, nil).Once()
+	repository.On("List", context.Background(), ListOptions{AfterID: 2, Limit: 3, Filter: filter}).Return([]User{{ID: 3}}, nil).Once()
+	repository.On("Count", context.Background(), filter).Return(3, nil)
+	first, _ := service.List(context.Background(), ListRequest{PageSize: 2, Filter: filter})
+	//Act
+	result, err := service.List(context.Background(), ListRequest{PageSize: 2, PageToken: first.NextPageToken, Filter: filter})
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []User{{ID: 3}}, result.Users)
+	assert.Empty(t, result.NextPageToken)
+	assert.Equal(t, 3, result.TotalSize)
+	repository.AssertExpectations(t)
+}
+
+func Test_List_PageSizeAboveMax_IsCapped(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	repository.On("List", context.Background(), ListOptions{Limit: MaxPageSize + 1}).Return([]User{}, nil)
+	repository.On("Count", context.Background(), Filter{}).Return(0, nil)
+	//Act
+	_, err := service.List(context.Background(), ListRequest{PageSize: MaxPageSize * 10})
+	//Assert
+	assert.Nil(t, err)
+	repository.AssertExpectations(t)
+}
+
+func Test_List_InvalidRequest_ReturnsInvalidDataError(t *testing.T) {
+	//Arrange
+	repository := repositoryMock{}
+	service := NewUserService(&repository)
+	//Act
+	_, errSize := service.List(context.Background(), ListRequest{PageSize: -1})
+	_, errToken := service.List(context.Background(), ListRequest{PageToken: "not-a-token"})
+	//Assert
+	assert.Equal(t, ErrInvalidData, errSize)
+	assert.Equal(t, ErrInvalidData, errToken)
+	repository.AssertNotCalled(t, "List")
+}
+
 func Test_GetByEmail(t *testing.T) {
 
 	repository := repositoryMock{}
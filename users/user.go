@@ -1,9 +1,27 @@
 package users
 
+import "time"
+
 //User - represents a user
 type User struct {
-	ID       int    `json:"id"`
-	Email    string `json:"email" validate:"required,email"`
-	Name     string `json:"name" validate:"required"`
-	LastName string `json:"lastname" validate:"required"`
+	ID        int       `json:"id"`
+	Email     string    `json:"email" validate:"required,email"`
+	Name      string    `json:"name" validate:"required"`
+	LastName  string    `json:"lastname" validate:"required"`
+	CreatedAt time.Time `json:"created_at"`
+	//UpdatedAt - when the user was last changed, maintained by the service
+	UpdatedAt time.Time `json:"updated_at"`
+	//CreatedBy - identity of the caller that created the user, maintained by the service
+	CreatedBy string `json:"created_by"`
+	//UpdatedBy - identity of the caller that last changed the user, maintained by the service
+	UpdatedBy string `json:"updated_by"`
+	//Version - incremented by the repository on every write, used to detect concurrent changes
+	Version int `json:"version"`
+	//DeletedAt - when the user was soft deleted, zero while the user is active
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+//IsDeleted - reports whether the user was soft deleted
+func (u User) IsDeleted() bool {
+	return !u.DeletedAt.IsZero()
 }
@@ -0,0 +1,360 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+)
+
+const (
+	//SignatureHeader carries the HMAC-SHA256 of the body keyed with the webhook secret, as sha256=<hex>
+	SignatureHeader = "X-Webhook-Signature"
+	//EventHeader carries the type of the delivered event
+	EventHeader = "X-Webhook-Event"
+	//DeliveryHeader carries the id of the delivery, the same on every attempt
+	DeliveryHeader = "X-Webhook-Delivery"
+
+	//DefaultMaxAttempts is the number of times a delivery is tried before it is dead-lettered
+	DefaultMaxAttempts = 5
+	//DefaultBackoff is the wait before the first retry, it doubles on every retry
+	DefaultBackoff = time.Second
+	//DefaultMaxBackoff caps the wait between retries
+	DefaultMaxBackoff = time.Minute
+	//DefaultDeadLetters is the number of failed deliveries kept
+	DefaultDeadLetters = 1000
+	//DefaultTimeout bounds every delivery attempt, an endpoint that does not answer in time is retried
+	DefaultTimeout = 10 * time.Second
+	//DefaultConcurrency is the number of deliveries, retries included, in progress at once
+	DefaultConcurrency = 16
+)
+
+//Payload - the JSON body POSTed to the webhooks
+type Payload struct {
+	//Revision - the revision of the change, increasing in the order the changes were made
+	Revision  int64      `json:"revision"`
+	Type      string     `json:"type"`
+	Time      time.Time  `json:"time"`
+	UserID    int        `json:"user_id"`
+	Actor     string     `json:"actor"`
+	RequestID string     `json:"request_id"`
+	User      users.User `json:"user"`
+}
+
+//DeadLetter - a delivery that exhausted its attempts
+type DeadLetter struct {
+	DeliveryID string
+	WebhookID  string
+	URL        string
+	Payload    Payload
+	Attempts   int
+	LastError  string
+	FailedAt   time.Time
+}
+
+//Option - configures optional behavior of a Dispatcher
+type Option func(*Dispatcher)
+
+//WithHTTPClient - sends the deliveries with the client instead of one timing out after DefaultTimeout
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+//WithLogger - reports the failed deliveries and the lost changes to the logger
+func WithLogger(logger users.Logger) Option {
+	return func(d *Dispatcher) {
+		d.logger = logger
+	}
+}
+
+//WithConcurrency - runs up to n deliveries at once, the changes wait for a delivery to end beyond that
+func WithConcurrency(n int) Option {
+	return func(d *Dispatcher) {
+		d.concurrency = n
+	}
+}
+
+//WithRetries - tries every delivery up to maxAttempts times, waiting backoff before the first retry and
+//twice as long before each of the following ones, up to maxBackoff
+func WithRetries(maxAttempts int, backoff, maxBackoff time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = maxAttempts
+		d.backoff = backoff
+		d.maxBackoff = maxBackoff
+	}
+}
+
+//Dispatcher - POSTs the changes of the users to the webhooks that want them, each delivery is signed and
+//retried with exponential backoff, the ones that exhaust their attempts are kept as dead letters
+type Dispatcher struct {
+	store       Store
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	concurrency int
+	logger      users.Logger
+
+	//slots holds a token per delivery in progress, so at most concurrency of them run at once
+	slots       chan struct{}
+	mu          sync.Mutex
+	deadLetters []DeadLetter
+	inFlight    sync.WaitGroup
+}
+
+//NewDispatcher - returns a Dispatcher type pointer delivering to the webhooks of the store
+func NewDispatcher(store Store, opts ...Option) *Dispatcher {
+
+	d := &Dispatcher{
+		store:       store,
+		client:      &http.Client{Timeout: DefaultTimeout},
+		maxAttempts: DefaultMaxAttempts,
+		backoff:     DefaultBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+		concurrency: DefaultConcurrency,
+		logger:      users.NopLogger{},
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	if d.concurrency < 1 {
+		d.concurrency = 1
+	}
+
+	d.slots = make(chan struct{}, d.concurrency)
+
+	return d
+}
+
+//Run - delivers the changes received from watch until the context is done, a watch that falls behind is
+//resumed after the last change received, or from the current one when that change is no longer kept
+func (d *Dispatcher) Run(ctx context.Context, watch func(context.Context, int64) (<-chan users.Change, error)) {
+
+	var revision int64
+
+	for ctx.Err() == nil {
+		changes, err := watch(ctx, revision)
+
+		if err == users.ErrRevisionGone {
+			d.logger.Warn("webhook deliveries were lost", "after_revision", revision)
+			revision = 0
+			continue
+		}
+
+		if err != nil {
+			d.logger.Error("watching users for webhooks failed", "error", err)
+			sleep(ctx, d.backoff)
+			continue
+		}
+
+		for c := range changes {
+			d.Dispatch(ctx, c)
+			revision = c.Revision
+		}
+	}
+
+	d.inFlight.Wait()
+}
+
+//Dispatch - starts the delivery of the change to every webhook that wants it, it blocks while the maximum
+//number of deliveries are in progress and gives up on the remaining webhooks when the context is done
+func (d *Dispatcher) Dispatch(ctx context.Context, c users.Change) {
+
+	webhooks, err := d.store.List(ctx)
+
+	if err != nil {
+		d.logger.Error("listing webhooks failed", "revision", c.Revision, "error", err)
+		return
+	}
+
+	payload := Payload{
+		Revision:  c.Revision,
+		Type:      c.Type,
+		Time:      c.Time,
+		UserID:    c.UserID,
+		Actor:     c.Actor,
+		RequestID: c.RequestID,
+		User:      c.User,
+	}
+
+	for _, w := range webhooks {
+		if !w.Wants(c.Type) {
+			continue
+		}
+
+		select {
+		case d.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		d.inFlight.Add(1)
+		go func(w Webhook) {
+			defer func() {
+				<-d.slots
+				d.inFlight.Done()
+			}()
+			d.deliver(ctx, w, payload)
+		}(w)
+	}
+}
+
+//Wait - waits for the deliveries in progress to succeed or be dead-lettered
+func (d *Dispatcher) Wait() {
+	d.inFlight.Wait()
+}
+
+//DeadLetters - retrieves the failed deliveries, oldest first, of every webhook when the id is empty
+func (d *Dispatcher) DeadLetters(webhookID string) []DeadLetter {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := []DeadLetter{}
+
+	for _, l := range d.deadLetters {
+		if webhookID == "" || l.WebhookID == webhookID {
+			result = append(result, l)
+		}
+	}
+
+	return result
+}
+
+//deliver tries the delivery until it succeeds, fails permanently or runs out of attempts
+func (d *Dispatcher) deliver(ctx context.Context, w Webhook, payload Payload) {
+
+	body, err := json.Marshal(payload)
+
+	if err != nil {
+		d.logger.Error("encoding webhook payload failed", "revision", payload.Revision, "error", err)
+		return
+	}
+
+	deliveryID := fmt.Sprintf("%d-%s", payload.Revision, w.ID)
+	wait := d.backoff
+	attempts := 0
+
+	for {
+		attempts++
+		retry, err := d.post(ctx, w, deliveryID, payload.Type, body)
+
+		if err == nil {
+			return
+		}
+
+		if !retry || attempts >= d.maxAttempts || !sleep(ctx, wait) {
+			if ctx.Err() != nil {
+				//shutting down, the delivery did not fail
+				return
+			}
+			d.deadLetter(DeadLetter{
+				DeliveryID: deliveryID,
+				WebhookID:  w.ID,
+				URL:        w.URL,
+				Payload:    payload,
+				Attempts:   attempts,
+				LastError:  err.Error(),
+				FailedAt:   time.Now().UTC(),
+			})
+			return
+		}
+
+		wait *= 2
+
+		if wait > d.maxBackoff {
+			wait = d.maxBackoff
+		}
+	}
+}
+
+//post sends one attempt of the delivery and tells whether a failure is worth retrying, client errors
+//other than timeouts and throttling are not
+func (d *Dispatcher) post(ctx context.Context, w Webhook, deliveryID, eventType string, body []byte) (bool, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+
+	resp, err := d.client.Do(req)
+
+	if err != nil {
+		return true, err
+	}
+
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("webhook answered %s", resp.Status)
+
+	if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return false, err
+	}
+
+	return true, err
+}
+
+//deadLetter keeps the failed delivery, the oldest ones are dropped once DefaultDeadLetters are kept
+func (d *Dispatcher) deadLetter(l DeadLetter) {
+
+	d.logger.Warn("webhook delivery failed", "delivery_id", l.DeliveryID, "webhook_id", l.WebhookID, "url", l.URL,
+		"attempts", l.Attempts, "error", l.LastError)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.deadLetters = append(d.deadLetters, l)
+
+	if len(d.deadLetters) > DefaultDeadLetters {
+		d.deadLetters = append(d.deadLetters[:0], d.deadLetters[len(d.deadLetters)-DefaultDeadLetters:]...)
+	}
+}
+
+//Sign - returns the signature of the body sent in SignatureHeader, the endpoints compute it with their
+//copy of the secret and compare it in constant time
+func Sign(secret string, body []byte) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+//sleep waits for the duration and reports false when the context is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
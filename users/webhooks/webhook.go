@@ -0,0 +1,206 @@
+//Package webhooks delivers the changes of the users to the HTTP endpoints registered by the partners
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+)
+
+var (
+	ErrNotFound    error = users.NewDomainError("webhook not found")
+	ErrInvalidData error = users.NewDomainError("invalid webhook")
+)
+
+//Webhook - an endpoint receiving the user events of the given types
+type Webhook struct {
+	ID  string
+	URL string
+	//EventTypes - the types of the events delivered, every type when empty
+	EventTypes []string
+	//Secret - signs the deliveries so the endpoint can verify them, only returned on registration
+	Secret    string
+	CreatedAt time.Time
+}
+
+//Wants - reports whether the events of the type are delivered to the webhook
+func (w Webhook) Wants(eventType string) bool {
+
+	if len(w.EventTypes) == 0 {
+		return true
+	}
+
+	for _, t := range w.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Store - stores the registered webhooks
+type Store interface {
+	Add(context.Context, Webhook) error
+	List(context.Context) ([]Webhook, error)
+	//Delete - removes the webhook, ErrNotFound is returned when there is no webhook with the id
+	Delete(context.Context, string) error
+}
+
+//MemoryStore keeps the webhooks in memory, it is safe for concurrent use
+type MemoryStore struct {
+	mu       sync.RWMutex
+	webhooks map[string]Webhook
+}
+
+//NewMemoryStore returns an empty MemoryStore type pointer
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{webhooks: map[string]Webhook{}}
+}
+
+//Add - stores the webhook
+func (s *MemoryStore) Add(ctx context.Context, w Webhook) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.webhooks[w.ID] = w
+
+	return nil
+}
+
+//List - retrieves every webhook ordered by creation time
+func (s *MemoryStore) List(ctx context.Context) ([]Webhook, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Webhook, 0, len(s.webhooks))
+
+	for _, w := range s.webhooks {
+		result = append(result, w)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+//Delete - removes the webhook
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhooks[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(s.webhooks, id)
+
+	return nil
+}
+
+//Service - registers the webhooks and lists the deliveries that failed
+type Service struct {
+	store      Store
+	dispatcher *Dispatcher
+}
+
+//NewService - returns a Service type pointer
+func NewService(store Store, dispatcher *Dispatcher) *Service {
+	return &Service{store: store, dispatcher: dispatcher}
+}
+
+//Register - validates and stores the webhook, a secret is generated when none is given
+func (s *Service) Register(ctx context.Context, w Webhook) (Webhook, error) {
+
+	target, err := url.Parse(w.URL)
+
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return Webhook{}, ErrInvalidData
+	}
+
+	for _, t := range w.EventTypes {
+		if !isEventType(t) {
+			return Webhook{}, ErrInvalidData
+		}
+	}
+
+	w.ID = randomHex(8)
+	w.CreatedAt = time.Now().UTC()
+
+	if w.Secret == "" {
+		w.Secret = randomHex(32)
+	}
+
+	if err := s.store.Add(ctx, w); err != nil {
+		return Webhook{}, users.ErrInternalError
+	}
+
+	return w, nil
+}
+
+//List - retrieves the registered webhooks without their secrets
+func (s *Service) List(ctx context.Context) ([]Webhook, error) {
+
+	webhooks, err := s.store.List(ctx)
+
+	if err != nil {
+		return nil, users.ErrInternalError
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+//Delete - removes the webhook, the deliveries in progress are completed
+func (s *Service) Delete(ctx context.Context, id string) error {
+
+	err := s.store.Delete(ctx, id)
+
+	if err != nil && err != ErrNotFound {
+		return users.ErrInternalError
+	}
+
+	return err
+}
+
+//DeadLetters - retrieves the deliveries that exhausted their attempts, of every webhook when the id is empty
+func (s *Service) DeadLetters(ctx context.Context, webhookID string) []DeadLetter {
+	return s.dispatcher.DeadLetters(webhookID)
+}
+
+func isEventType(eventType string) bool {
+
+	for _, t := range users.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+//randomHex returns n random bytes hex encoded
+func randomHex(n int) string {
+
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,367 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/casmelad/bootcamp-gateway/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//receiver is an httptest endpoint answering the deliveries with the given status codes in order,
+//the last one is repeated
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		status := r.statuses[0]
+		if len(r.statuses) > 1 {
+			r.statuses = r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *receiver) received() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func newTestDispatcher(store Store, opts ...Option) *Dispatcher {
+	return NewDispatcher(store, append([]Option{WithRetries(3, time.Millisecond, 4*time.Millisecond)}, opts...)...)
+}
+
+//recordingLogger keeps the messages of the warnings and errors written to it
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) Info(msg string, keyvals ...interface{}) {}
+
+func (l *recordingLogger) Warn(msg string, keyvals ...interface{}) { l.record(msg) }
+
+func (l *recordingLogger) Error(msg string, keyvals ...interface{}) { l.record(msg) }
+
+func (l *recordingLogger) record(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, msg)
+}
+
+func testChange(eventType string) users.Change {
+	return users.Change{Revision: 42, Event: users.Event{Type: eventType, Time: time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC),
+		UserID: 7, Actor: "admin@gmail.com", User: users.User{ID: 7, Email: "test@gmail.com", Version: 2}}}
+}
+
+func register(t *testing.T, store Store, w Webhook) Webhook {
+	registered, err := NewService(store, nil).Register(context.Background(), w)
+	require.NoError(t, err)
+	return registered
+}
+
+func Test_Dispatch_PostsSignedPayload(t *testing.T) {
+	//Arrange
+	store := NewMemoryStore()
+	endpoint := newReceiver(t, http.StatusNoContent)
+	webhook := register(t, store, Webhook{URL: endpoint.URL, Secret: "s3cr3t"})
+	dispatcher := newTestDispatcher(store)
+	//Act
+	dispatcher.Dispatch(context.Background(), testChange(users.EventUserUpdated))
+	dispatcher.Wait()
+	//Assert
+	require.Equal(t, 1, endpoint.received())
+	req, body := endpoint.requests[0], endpoint.bodies[0]
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, users.EventUserUpdated, req.Header.Get(EventHeader))
+	assert.Equal(t, "42-"+webhook.ID, req.Header.Get(DeliveryHeader))
+	assert.Equal(t, Sign("s3cr3t", body), req.Header.Get(SignatureHeader))
+	var payload Payload
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, int64(42), payload.Revision)
+	assert.Equal(t, users.EventUserUpdated, payload.Type)
+	assert.Equal(t, "admin@gmail.com", payload.Actor)
+	assert.Equal(t, 2, payload.User.Version)
+	assert.Empty(t, dispatcher.DeadLetters(""))
+}
+
+func Test_Sign_KnownVector(t *testing.T) {
+	//Act
+	result := Sign("key", []byte("The quick brown fox jumps over the lazy dog"))
+	//Assert
+	assert.Equal(t, "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8", result)
+}
+
+func Test_Dispatch_OnlyWantedEventTypes(t *testing.T) {
+	//Arrange
+	store := NewMemoryStore()
+	deletions := newReceiver(t, http.StatusOK)
+	everything := newReceiver(t, http.StatusOK)
+	register(t, store, Webhook{URL: deletions.URL, EventTypes: []string{users.EventUserDeleted}})
+	register(t, store, Webhook{URL: everything.URL})
+	dispatcher := newTestDispatcher(store)
+	//Act
+	dispatcher.Dispatch(context.Background(), testChange(users.EventUserCreated))
+	dispatcher.Dispatch(context.Background(), testChange(users.EventUserDeleted))
+	dispatcher.Wait()
+	//Assert
+	assert.Equal(t, 1, deletions.received())
+	assert.Equal(t, 2, everything.received())
+}
+
+func Test_Dispatch_ServerErrors_RetriedUntilDelivered(t *testing.T) {
+	//Arrange
+	store := NewMemoryStore()
+	endpoint := newReceiver(t, http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusOK)
+	register(t, store, Webhook{URL: endpoint.URL})
+	dispatcher := newTestDispatcher(store)
+	//Act
+	dispatcher.Dispatch(context.Background(), testChange(users.EventUserCreated))
+	dispatcher.Wait()
+	//Assert
+	assert.Equal(t, 3, endpoint.received())
+	assert.Equal(t, endpoint.requests[0].Header.Get(DeliveryHeader), endpoint.requests[2].Header.Get(DeliveryHeader))
+	assert.Empty(t, dispatcher.DeadLetters(""))
+}
+
+func Test_Dispatch_AttemptsExhausted_DeadLettered(t *testing.T) {
+	//Arrange
+	store := NewMemoryStore()
+	endpoint := newReceiver(t, http.StatusServiceUnavailable)
+	webhook := register(t, store, Webhook{URL: endpoint.URL})
+	logger := &recordingLogger{}
+	dispatcher := newTestDispatcher(store, WithLogger(logger))
+	//Act
+	dispatcher.Dispatch(context.Background(), testChange(users.EventUserCreated))
+	dispatcher.Wait()
+	//Assert
+	assert.Equal(t, 3, endpoint.received())
+	assert.Equal(t, []string{"webhook delivery failed"}, logger.messages)
+	letters := dispatcher.DeadLetters(webhook.ID)
+	require.Len(t, letters, 1)
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.Equal(t, endpoint.URL, letters[0].URL)
+	assert.Equal(t, int64(42), letters[0].Payload.Revision)
+	assert.Contains(t, letters[0].LastError, "503")
+	assert.Empty(t, dispatcher.DeadLetters("another"))
+}
+
+func Test_Dispatch_ClientError_NotRetried(t *testing.T) {
+	//Arrange
+	store := NewMemoryStore()
+	endpoint := newReceiver(t, http.StatusGone)
+	register(t, store, Webhook{URL: endpoint.URL})
+	dispatcher := newTestDispatcher(store)
+	//Act
+	dispatcher.Dispatch(context.Background(), testChange(users.EventUserCreated))
+	dispatcher.Wait()
+	//Assert
+	assert.Equal(t, 1, endpoint.received())
+	assert.Len(t, dispatcher.DeadLetters(""), 1)
+}
+
+func Test_Dispatch_HangingEndpoint_TimesOut(t *testing.T) {
+	//Arrange
+	store := NewMemoryStore()
+	hang := make(chan struct{})
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-hang }))
+	t.Cleanup(endpoint.Close)
+	t.Cleanup(func() { close(hang) })
+	register(t, store, Webhook{URL: endpoint.URL})
+	dispatcher := NewDispatcher(store, WithRetries(2, time.Millisecond, time.Millisecond),
+		WithHTTPClient(&http.Client{Timeout: 20 * time.Millisecond}))
+	//Act
+	dispatcher.Dispatch(context.Background(), testChange(users.EventUserCreated))
+	dispatcher.Wait()
+	//Assert
+	letters := dispatcher.DeadLetters("")
+	require.Len(t, letters, 1)
+	assert.Equal(t, 2, letters[0].Attempts)
+	assert.Equal(t, DefaultTimeout, NewDispatcher(store).client.Timeout)
+}
+
+func Test_Run_DeliversWatchedChanges(t *testing.T) {
+	//Arrange
+	store := NewMemoryStore()
+	endpoint := newReceiver(t, http.StatusOK)
+	register(t, store, Webhook{URL: endpoint.URL})
+	dispatcher := newTestDispatcher(store)
+	broadcaster := users.NewBroadcaster(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	watching := make(chan struct{})
+	watch := func(ctx context.Context, after int64) (<-chan users.Change, error) {
+		changes, err := broadcaster.Subscribe(ctx, after)
+		close(watching)
+		return changes, err
+	}
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx, watch)
+		close(done)
+	}()
+	<-watching
+	//Act
+	broadcaster.Publish(users.Event{Type: users.EventUserCreated, UserID: 1})
+	broadcaster.Publish(users.Event{Type: users.EventUserUpdated, UserID: 1})
+	for endpoint.received() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	//Assert
+	assert.Equal(t, 2, endpoint.received())
+}
+
+func Test_Register_ValidatesAndGeneratesSecret(t *testing.T) {
+	//Arrange
+	service := NewService(NewMemoryStore(), NewDispatcher(nil))
+	ctx := context.Background()
+	//Act
+	registered, err := service.Register(ctx, Webhook{URL: "https://partner.example.com/hooks", EventTypes: []string{users.EventUserCreated}})
+	_, errURL := service.Register(ctx, Webhook{URL: "ftp://partner.example.com"})
+	_, errRelative := service.Register(ctx, Webhook{URL: "/hooks"})
+	_, errType := service.Register(ctx, Webhook{URL: "https://partner.example.com", EventTypes: []string{"UserRenamed"}})
+	listed, _ := service.List(ctx)
+	//Assert
+	assert.Nil(t, err)
+	assert.NotEmpty(t, registered.ID)
+	assert.Len(t, registered.Secret, 64)
+	assert.False(t, registered.CreatedAt.IsZero())
+	assert.Equal(t, ErrInvalidData, errURL)
+	assert.Equal(t, ErrInvalidData, errRelative)
+	assert.Equal(t, ErrInvalidData, errType)
+	require.Len(t, listed, 1)
+	assert.Equal(t, registered.ID, listed[0].ID)
+	assert.Empty(t, listed[0].Secret)
+}
+
+func Test_Delete_RemovesWebhook(t *testing.T) {
+	//Arrange
+	service := NewService(NewMemoryStore(), NewDispatcher(nil))
+	ctx := context.Background()
+	registered, _ := service.Register(ctx, Webhook{URL: "https://partner.example.com/hooks"})
+	//Act
+	err := service.Delete(ctx, registered.ID)
+	errMissing := service.Delete(ctx, registered.ID)
+	listed, _ := service.List(ctx)
+	//Assert
+	assert.Nil(t, err)
+	assert.Equal(t, ErrNotFound, errMissing)
+	assert.Empty(t, listed)
+}
+
+//slowReceiver is an httptest endpoint holding every delivery until it is released, it records how many
+//deliveries it held at once
+type slowReceiver struct {
+	*httptest.Server
+	release chan struct{}
+	mu      sync.Mutex
+	held    int
+	maxHeld int
+	total   int
+}
+
+func newSlowReceiver(t *testing.T) *slowReceiver {
+
+	r := &slowReceiver{release: make(chan struct{})}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		r.held++
+		r.total++
+		if r.held > r.maxHeld {
+			r.maxHeld = r.held
+		}
+		r.mu.Unlock()
+		<-r.release
+		r.mu.Lock()
+		r.held--
+		r.mu.Unlock()
+	}))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *slowReceiver) counts() (held, maxHeld, total int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.held, r.maxHeld, r.total
+}
+
+func Test_Dispatch_SlowReceiver_BoundsTheDeliveriesInProgress(t *testing.T) {
+	//Arrange
+	store := NewMemoryStore()
+	endpoint := newSlowReceiver(t)
+	register(t, store, Webhook{URL: endpoint.URL})
+	dispatcher := newTestDispatcher(store, WithConcurrency(2))
+	dispatched := make(chan struct{})
+	//Act
+	go func() {
+		for i := 0; i < 10; i++ {
+			dispatcher.Dispatch(context.Background(), testChange(users.EventUserUpdated))
+		}
+		close(dispatched)
+	}()
+	require.Eventually(t, func() bool { held, _, _ := endpoint.counts(); return held == 2 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	_, maxHeldWhileBlocked, _ := endpoint.counts()
+	close(endpoint.release)
+	<-dispatched
+	dispatcher.Wait()
+	//Assert
+	_, maxHeld, total := endpoint.counts()
+	assert.Equal(t, 2, maxHeldWhileBlocked)
+	assert.Equal(t, 2, maxHeld)
+	assert.Equal(t, 10, total)
+	assert.Empty(t, dispatcher.DeadLetters(""))
+}
+
+func Test_Dispatch_ContextDone_StopsWaitingForTheSlowReceiver(t *testing.T) {
+	//Arrange
+	store := NewMemoryStore()
+	endpoint := newSlowReceiver(t)
+	register(t, store, Webhook{URL: endpoint.URL})
+	dispatcher := newTestDispatcher(store, WithConcurrency(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	dispatcher.Dispatch(ctx, testChange(users.EventUserUpdated))
+	require.Eventually(t, func() bool { held, _, _ := endpoint.counts(); return held == 1 }, time.Second, time.Millisecond)
+	blocked := make(chan struct{})
+	go func() {
+		dispatcher.Dispatch(ctx, testChange(users.EventUserUpdated))
+		close(blocked)
+	}()
+	//Act
+	cancel()
+	<-blocked
+	dispatcher.Wait()
+	close(endpoint.release)
+	//Assert
+	_, _, total := endpoint.counts()
+	assert.Equal(t, 1, total)
+	assert.Empty(t, dispatcher.DeadLetters(""))
+}